@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"argos/shared"
+)
+
+const cliUsage = `Usage: agent [command] [flags]
+
+Commands:
+  run                   Start the agent and push metrics periodically (default)
+  check [target]        Run every configured probe (or one target) once and print the results
+  validate              Parse and validate the config file
+
+Flags:
+  -config path          Config file (default: $CONFIG_PATH or config.yaml)
+  -format table|json    Output format for check (default: table)
+`
+
+// CheckResult é o resultado de uma execução avulsa de um target.
+type CheckResult struct {
+	Target     string          `json:"target"`
+	Type       string          `json:"type"`
+	DurationMS float64         `json:"duration_ms"`
+	Metrics    []shared.Metric `json:"metrics"`
+	Errors     []string        `json:"errors,omitempty"`
+}
+
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	command := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, cliUsage) }
+	configPath := fs.String("config", defaultConfigPath(), "config file")
+	format := fs.String("format", "table", "output format for check (table|json)")
+
+	// O FlagSet para no primeiro argumento posicional; as flags podem vir
+	// antes ou depois do target, então o resto é analisado de novo
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if n := len(args) - fs.NArg(); n > 0 && args[n-1] == "--" {
+			positional = append(positional, fs.Args()...)
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	// Só check aceita um argumento posicional, o target
+	target := ""
+	if command == "check" && len(positional) > 0 {
+		target, positional = positional[0], positional[1:]
+	}
+	if len(positional) > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %s\n\n%s", strings.Join(positional, " "), cliUsage)
+		return 2
+	}
+
+	switch command {
+	case "run":
+		runAgent(*configPath)
+		return 0
+	case "validate":
+		return validateCommand(*configPath, stdout, stderr)
+	case "check":
+		return checkCommand(*configPath, target, *format, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, cliUsage)
+		return 2
+	}
+}
+
+func validateCommand(configPath string, stdout, stderr io.Writer) int {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", configPath, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%s: OK (%d targets)\n", configPath, len(cfg.Targets))
+	return 0
+}
+
+func checkCommand(configPath, only, format string, stdout, stderr io.Writer) int {
+	if format != "table" && format != "json" {
+		fmt.Fprintf(stderr, "invalid format %q: must be table or json\n", format)
+		return 2
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", configPath, err)
+		return 1
+	}
+
+	var targets []*Target
+	for i := range cfg.Targets {
+		if only == "" || cfg.Targets[i].Name == only {
+			targets = append(targets, &cfg.Targets[i])
+		}
+	}
+
+	if len(targets) == 0 {
+		if only != "" {
+			fmt.Fprintf(stderr, "target %q not found in %s\n", only, configPath)
+		} else {
+			fmt.Fprintf(stderr, "no targets configured in %s\n", configPath)
+		}
+		return 1
+	}
+
+	results := runChecks(context.Background(), targets, cfg.ProbeTimeout)
+
+	pipeline := newLabelPipeline(cfg)
+	for i := range results {
+		results[i].Metrics = pipeline.Process(results[i].Metrics)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		printCheckTable(stdout, results)
+	}
+
+	for _, r := range results {
+		if len(r.Errors) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runChecks roda cada target com o mesmo deadline da coleta normal (o
+// timeout do target ou probe_timeout), para que um probe travado não
+// prenda o comando
+func runChecks(ctx context.Context, targets []*Target, probeTimeout time.Duration) []CheckResult {
+	results := make([]CheckResult, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *Target) {
+			defer wg.Done()
+
+			probe := t.Probe()
+			scheduled := newScheduledProbe(probe, t.Service(), t.Name, t.Labels, t.Timeout(), probeTimeout)
+			start := time.Now()
+			metrics := scheduled.collect(ctx)
+
+			// Com labels no target o probe vem embrulhado em labeledProbe
+			var output string
+			inner := probe
+			if lp, ok := inner.(*labeledProbe); ok {
+				inner = lp.Probe
+			}
+			if o, ok := inner.(interface{ LastOutput() string }); ok {
+				output = o.LastOutput()
+			}
+
+			results[i] = CheckResult{
+				Target:     t.Name,
+				Type:       t.Type,
+				DurationMS: time.Since(start).Seconds() * 1000,
+				Metrics:    metrics,
+				Errors:     metricErrors(metrics, output),
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// metricErrors aponta falhas a partir das métricas retornadas: séries *_up
+// zeradas, probe_timeout ou exec_status diferente de OK, com a saída do
+// plugin.
+func metricErrors(metrics []shared.Metric, output string) []string {
+	if len(metrics) == 0 {
+		return []string{"probe returned no metrics"}
+	}
+
+	var errs []string
+	for _, m := range metrics {
+		switch {
+		case strings.HasSuffix(m.Name, "_up") && m.Value == 0:
+			errs = append(errs, m.Name+" is 0")
+		case m.Name == "probe_timeout" && m.Value != 0:
+			errs = append(errs, "probe timed out")
+		case m.Name == "exec_status" && m.Value != 0:
+			errs = append(errs, fmt.Sprintf("exec_status is %s: %s", m.Labels["state"], output))
+		}
+	}
+	return errs
+}
+
+func printCheckTable(w io.Writer, results []CheckResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tTYPE\tMETRIC\tVALUE\tLABELS")
+
+	for _, r := range results {
+		for _, m := range r.Metrics {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%g\t%s\n", r.Target, r.Type, m.Name, m.Value, formatLabels(m.Labels))
+		}
+		for _, e := range r.Errors {
+			fmt.Fprintf(tw, "%s\t%s\tERROR\t-\t%s\n", r.Target, r.Type, e)
+		}
+	}
+
+	tw.Flush()
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+	return strings.Join(parts, ",")
+}
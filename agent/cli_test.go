@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestValidateCommand(t *testing.T) {
+	good := writeConfig(t, "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets: []\n")
+	bad := writeConfig(t, "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets:\n  - type: nope\n    name: x\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"validate", "-config", good}, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected exit 0 for valid config, got %d (%s)", code, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := runCLI([]string{"validate", "-config", bad}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit 1 for invalid config, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "unknown probe type") {
+		t.Errorf("Expected validation error in stderr, got %q", stderr.String())
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	path := writeConfig(t, `
+agent_id: a
+push_endpoint: http://localhost/ingest
+targets:
+  - type: http
+    name: healthy
+    url: `+up.URL+`
+  - type: http
+    name: broken
+    url: http://127.0.0.1:1/
+    timeout: 1s
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"check", "-config", path, "healthy"}, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected exit 0 for healthy target, got %d (%s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "http_up") {
+		t.Errorf("Expected table output with http_up, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	code := runCLI([]string{"check", "-config", path, "-format", "json"}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("Expected exit 1 when a target fails, got %d", code)
+	}
+
+	var results []CheckResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if len(results[0].Errors) != 0 || len(results[1].Errors) == 0 {
+		t.Errorf("Expected only 'broken' to report errors: %+v", results)
+	}
+}
+
+func TestCheckCommandFlagsAfterTarget(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	path := writeConfig(t, "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets:\n  - type: http\n    name: web\n    url: "+up.URL+"\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"check", "web", "-config", path, "-format", "json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit 0, got %d (%s)", code, stderr.String())
+	}
+	var results []CheckResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("Expected JSON output for flags after the target: %v", err)
+	}
+	if len(results) != 1 || results[0].Target != "web" {
+		t.Errorf("Expected only target web, got %+v", results)
+	}
+
+	if code := runCLI([]string{"check", "web", "extra", "-config", path}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit 2 for extra arguments, got %d", code)
+	}
+}
+
+func TestCheckCommandUnknownTarget(t *testing.T) {
+	path := writeConfig(t, "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets: []\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"check", "-config", path, "missing"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit 1 for unknown target, got %d", code)
+	}
+}
+
+func TestCheckCommandExecOutputWithLabels(t *testing.T) {
+	path := writeConfig(t, `
+agent_id: a
+push_endpoint: http://localhost/ingest
+targets:
+  - type: exec
+    name: disk
+    command: sh
+    args: ["-c", "echo DISK CRITICAL - / is full; exit 2"]
+    labels:
+      team: ops
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"check", "-config", path, "-format", "json"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit 1 for a critical plugin, got %d", code)
+	}
+
+	var results []CheckResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	want := "exec_status is CRITICAL: DISK CRITICAL - / is full"
+	if len(results) != 1 || len(results[0].Errors) != 1 || results[0].Errors[0] != want {
+		t.Errorf("Expected error %q, got %+v", want, results)
+	}
+}
+
+func TestCLIRejectsUnexpectedArguments(t *testing.T) {
+	path := writeConfig(t, "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets: []\n")
+
+	for _, args := range [][]string{
+		{"validate", "-config", path, "extra"},
+		{"run", "extra", "-config", path},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: expected exit 2, got %d", args, code)
+		}
+		if !strings.Contains(stderr.String(), "unexpected arguments: extra") {
+			t.Errorf("%v: expected unexpected arguments error, got %q", args, stderr.String())
+		}
+	}
+}
+
+func TestRunChecksTimesOutHungProbe(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	// O probe ignora o contexto: só o deadline do check o abandona
+	hung := &Target{Type: "test", Name: "hung", loaded: &loadedTarget{
+		build: func() Probe {
+			return funcProbe(func(ctx context.Context) []shared.Metric {
+				<-release
+				return nil
+			})
+		},
+	}}
+
+	start := time.Now()
+	results := runChecks(context.Background(), []*Target{hung}, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected check to give up on the hung probe, took %s", elapsed)
+	}
+	if len(results) != 1 || len(results[0].Errors) != 1 || results[0].Errors[0] != "probe timed out" {
+		t.Errorf("Expected a timeout error, got %+v", results)
+	}
+}
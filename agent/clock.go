@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"argos/agent/probes"
+	"argos/shared"
+)
+
+// clockProbe mede o offset do relógio do próprio agente. O TS das métricas
+// vem desse relógio, então um agente com offset alto grava dados deslocados.
+type clockProbe struct {
+	agentID string
+	server  string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	offset *float64
+}
+
+// newClockProbe retorna nil se não houver servidor NTP configurado
+func newClockProbe(cfg *Config) *clockProbe {
+	server := cfg.ClockServer
+	if server == "" {
+		for _, t := range cfg.Targets {
+			if ntp, ok := t.Config().(*NTPTarget); ok {
+				server = ntp.Servers[0]
+				break
+			}
+		}
+	}
+	if server == "" {
+		return nil
+	}
+	return &clockProbe{agentID: cfg.AgentID, server: server, timeout: 2 * time.Second}
+}
+
+func (p *clockProbe) Collect(ctx context.Context) []shared.Metric {
+	result, err := probes.QueryNTP(ctx, p.server, p.timeout)
+	ts := time.Now()
+	labels := map[string]string{"server": p.server}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.offset = nil
+		return []shared.Metric{
+			{Service: "agent", Target: p.agentID, Name: "agent_clock_sync_up", Value: 0, Labels: labels, TS: ts},
+		}
+	}
+	offset := result.Offset.Seconds() * 1000
+	p.offset = &offset
+	return []shared.Metric{
+		{Service: "agent", Target: p.agentID, Name: "agent_clock_sync_up", Value: 1, Labels: labels, TS: ts},
+		{Service: "agent", Target: p.agentID, Name: "agent_clock_offset_ms", Value: offset, Labels: labels, TS: ts},
+	}
+}
+
+// Offset retorna o último offset medido em ms, ou nil se a última consulta falhou
+func (p *clockProbe) Offset() *float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.offset
+}
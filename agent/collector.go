@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"argos/shared"
+)
+
+// Folga sobre o timeout do target antes de abandonar a coleta, para que o
+// próprio probe tenha a chance de reportar a falha
+const probeDeadlineGrace = time.Second
+
+// scheduledProbe é um probe com o deadline de coleta já resolvido. running
+// impede que uma coleta travada acumule goroutines a cada ciclo.
+type scheduledProbe struct {
+	Probe
+	service  string
+	target   string
+	labels   map[string]string
+	deadline time.Duration
+
+	running atomic.Bool
+}
+
+func newScheduledProbe(p Probe, service, target string, labels map[string]string, timeout, fallback time.Duration) *scheduledProbe {
+	deadline := fallback
+	if timeout > 0 {
+		deadline = timeout + probeDeadlineGrace
+	}
+	return &scheduledProbe{
+		Probe:    p,
+		service:  service,
+		target:   target,
+		labels:   labels,
+		deadline: deadline,
+	}
+}
+
+// collect roda o probe até o deadline. Se ele não terminar a tempo, o
+// resultado tardio é descartado e só probe_timeout=1 é reportado; enquanto
+// a coleta antiga não retornar, as próximas também contam como timeout.
+func (p *scheduledProbe) collect(ctx context.Context) []shared.Metric {
+	if !p.running.CompareAndSwap(false, true) {
+		return []shared.Metric{p.timeoutMetric(1)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.deadline)
+	defer cancel()
+
+	done := make(chan []shared.Metric, 1)
+	go func() {
+		defer p.running.Store(false)
+		done <- p.Probe.Collect(ctx)
+	}()
+
+	select {
+	case metrics := <-done:
+		return append(metrics, p.timeoutMetric(0))
+	case <-ctx.Done():
+		select {
+		case metrics := <-done:
+			return append(metrics, p.timeoutMetric(0))
+		default:
+			return []shared.Metric{p.timeoutMetric(1)}
+		}
+	}
+}
+
+func (p *scheduledProbe) timeoutMetric(value float64) shared.Metric {
+	labels := make(map[string]string, len(p.labels))
+	for k, v := range p.labels {
+		labels[k] = v
+	}
+	return shared.Metric{
+		Service: p.service,
+		Target:  p.target,
+		Name:    "probe_timeout",
+		Value:   value,
+		Labels:  labels,
+		TS:      time.Now(),
+	}
+}
+
+// collectAllMetrics coleta no máximo concurrency probes ao mesmo tempo. Cada
+// probe tem seu próprio deadline, então um probe travado não segura o lote.
+func collectAllMetrics(ctx context.Context, probeList []*scheduledProbe, concurrency int) []shared.Metric {
+	if concurrency <= 0 || concurrency > len(probeList) {
+		concurrency = len(probeList)
+	}
+
+	var wg sync.WaitGroup
+	metricsChan := make(chan []shared.Metric, len(probeList))
+	work := make(chan *scheduledProbe)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				metricsChan <- p.collect(ctx)
+			}
+		}()
+	}
+
+	for _, p := range probeList {
+		work <- p
+	}
+	close(work)
+
+	wg.Wait()
+	close(metricsChan)
+
+	var allMetrics []shared.Metric
+	for metrics := range metricsChan {
+		allMetrics = append(allMetrics, metrics...)
+	}
+
+	return allMetrics
+}
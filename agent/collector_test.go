@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+type funcProbe func(ctx context.Context) []shared.Metric
+
+func (f funcProbe) Collect(ctx context.Context) []shared.Metric { return f(ctx) }
+
+func upMetric(target string) []shared.Metric {
+	return []shared.Metric{{Service: "test", Target: target, Name: "test_up", Value: 1}}
+}
+
+func timeouts(metrics []shared.Metric) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range metrics {
+		if m.Name == "probe_timeout" {
+			values[m.Target] = m.Value
+		}
+	}
+	return values
+}
+
+func TestCollectAllMetricsDropsHungProbe(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	hung := newScheduledProbe(funcProbe(func(ctx context.Context) []shared.Metric {
+		<-release
+		return upMetric("hung")
+	}), "test", "hung", map[string]string{"team": "sre"}, 0, 100*time.Millisecond)
+	fast := newScheduledProbe(funcProbe(func(ctx context.Context) []shared.Metric {
+		return upMetric("fast")
+	}), "test", "fast", nil, 0, time.Second)
+
+	start := time.Now()
+	metrics := collectAllMetrics(context.Background(), []*scheduledProbe{hung, fast}, 2)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Collection took %s, expected the hung probe to be abandoned", elapsed)
+	}
+
+	values := timeouts(metrics)
+	if values["hung"] != 1 || values["fast"] != 0 {
+		t.Errorf("Expected probe_timeout hung=1 fast=0, got %v", values)
+	}
+	for _, m := range metrics {
+		if m.Name == "test_up" && m.Target == "hung" {
+			t.Error("Late result from hung probe should be dropped")
+		}
+		if m.Name == "probe_timeout" && m.Target == "hung" && m.Labels["team"] != "sre" {
+			t.Errorf("Expected target labels on probe_timeout, got %v", m.Labels)
+		}
+	}
+
+	// Enquanto a coleta travada não retorna, o probe não é executado de novo
+	metrics = collectAllMetrics(context.Background(), []*scheduledProbe{hung}, 1)
+	if len(metrics) != 1 || metrics[0].Value != 1 {
+		t.Errorf("Expected only probe_timeout=1 while previous run is in flight, got %+v", metrics)
+	}
+}
+
+func TestCollectAllMetricsConcurrencyLimit(t *testing.T) {
+	var active, peak atomic.Int32
+	probe := funcProbe(func(ctx context.Context) []shared.Metric {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		active.Add(-1)
+		return upMetric("p")
+	})
+
+	var probeList []*scheduledProbe
+	for i := 0; i < 10; i++ {
+		probeList = append(probeList, newScheduledProbe(probe, "test", "p", nil, 0, time.Second))
+	}
+
+	metrics := collectAllMetrics(context.Background(), probeList, 3)
+	if len(metrics) != 20 {
+		t.Errorf("Expected 20 metrics, got %d", len(metrics))
+	}
+	if peak.Load() > 3 {
+		t.Errorf("Expected at most 3 concurrent probes, got %d", peak.Load())
+	}
+}
+
+func TestScheduledProbeDeadlineFromTimeout(t *testing.T) {
+	p := newScheduledProbe(nil, "http", "site", nil, 5*time.Second, 10*time.Second)
+	if p.deadline != 5*time.Second+probeDeadlineGrace {
+		t.Errorf("Expected deadline timeout+grace, got %s", p.deadline)
+	}
+
+	p = newScheduledProbe(nil, "postgres", "db", nil, 0, 10*time.Second)
+	if p.deadline != 10*time.Second {
+		t.Errorf("Expected probe_timeout fallback, got %s", p.deadline)
+	}
+}
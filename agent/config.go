@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -9,51 +13,58 @@ import (
 
 type Config struct {
 	AgentID      string        `yaml:"agent_id"`
-	PushEndpoint string        `yaml:"push_endpoint"`
+	PushEndpoint EndpointList  `yaml:"push_endpoint"`
 	PushInterval time.Duration `yaml:"push_interval"`
-	Targets      Targets       `yaml:"targets"`
+	// failover (padrão): primeiro endpoint saudável; replicate: todos
+	PushStrategy  string `yaml:"push_strategy"`
+	PushQueueSize int    `yaml:"push_queue_size"`
+	// Token de ingestão emitido pela API; push_token_file tem precedência
+	PushToken     string     `yaml:"push_token"`
+	PushTokenFile string     `yaml:"push_token_file"`
+	PushTLS       *TLSConfig `yaml:"push_tls"`
+	// Máximo de probes coletando ao mesmo tempo
+	ProbeConcurrency int `yaml:"probe_concurrency"`
+	// Deadline de coleta para probes sem timeout próprio; os demais usam o
+	// timeout do target mais uma folga. Padrão: push_interval.
+	ProbeTimeout time.Duration `yaml:"probe_timeout"`
+	// Endereço (host:port) de um listener /metrics no formato do Prometheus.
+	// Vazio desativa.
+	MetricsListen string `yaml:"metrics_listen"`
+	// Servidor NTP usado para medir o offset do relógio do próprio agente.
+	// Sem ele, usa o primeiro servidor do primeiro target ntp, se houver.
+	ClockServer string `yaml:"clock_server"`
+	// Diretório onde o agente guarda estado entre reinícios (as chaves SSH
+	// vistas pelo TOFU). Vazio mantém tudo em memória.
+	StateDir string `yaml:"state_dir"`
+	// Labels adicionados a todas as métricas (sem sobrescrever os existentes)
+	ExternalLabels map[string]string `yaml:"external_labels"`
+	RelabelConfigs []RelabelConfig   `yaml:"relabel_configs"`
+	Targets        TargetList        `yaml:"targets"`
 }
 
-type Targets struct {
-	HTTP     []HTTPTarget     `yaml:"http"`
-	DNS      []DNSTarget      `yaml:"dns"`
-	SMTP     []SMTPTarget     `yaml:"smtp"`
-	ICMP     []ICMPTarget     `yaml:"icmp"`
-	Postgres []PostgresTarget `yaml:"postgres"`
-}
-
-type HTTPTarget struct {
-	Name    string        `yaml:"name"`
-	URL     string        `yaml:"url"`
-	Method  string        `yaml:"method"`
-	Timeout time.Duration `yaml:"timeout"`
-}
-
-type DNSTarget struct {
-	Name   string `yaml:"name"`
-	FQDN   string `yaml:"fqdn"`
-	Server string `yaml:"server"`
-}
+// EndpointList aceita um único endpoint ou uma lista em ordem de prioridade
+type EndpointList []string
 
-type SMTPTarget struct {
-	Name     string        `yaml:"name"`
-	Host     string        `yaml:"host"`
-	Port     int           `yaml:"port"`
-	StartTLS bool          `yaml:"starttls"`
-	Timeout  time.Duration `yaml:"timeout"`
-}
+func (l *EndpointList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = EndpointList{node.Value}
+		return nil
+	}
 
-type ICMPTarget struct {
-	Name    string        `yaml:"name"`
-	Host    string        `yaml:"host"`
-	Timeout time.Duration `yaml:"timeout"`
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
 }
 
-type PostgresTarget struct {
-	Name    string `yaml:"name"`
-	DSN     string `yaml:"dsn"`
-	SlowMS  int    `yaml:"slow_ms"`
-	PingSQL string `yaml:"ping_sql"`
+// TLSConfig configura mTLS com a API: certificado de cliente e CA fixado
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -62,6 +73,10 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	return ParseConfig(data)
+}
+
+func ParseConfig(data []byte) (*Config, error) {
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
@@ -70,36 +85,85 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.PushInterval == 0 {
 		cfg.PushInterval = 10 * time.Second
 	}
+	if cfg.PushStrategy == "" {
+		cfg.PushStrategy = StrategyFailover
+	}
+	if cfg.PushQueueSize == 0 {
+		cfg.PushQueueSize = 100
+	}
+	if cfg.ProbeConcurrency == 0 {
+		cfg.ProbeConcurrency = 16
+	}
+	if cfg.ProbeTimeout == 0 {
+		cfg.ProbeTimeout = cfg.PushInterval
+	}
 
-	for i := range cfg.Targets.HTTP {
-		if cfg.Targets.HTTP[i].Method == "" {
-			cfg.Targets.HTTP[i].Method = "GET"
-		}
-		if cfg.Targets.HTTP[i].Timeout == 0 {
-			cfg.Targets.HTTP[i].Timeout = 5 * time.Second
-		}
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 
-	for i := range cfg.Targets.SMTP {
-		if cfg.Targets.SMTP[i].Timeout == 0 {
-			cfg.Targets.SMTP[i].Timeout = 5 * time.Second
+	if cfg.PushTokenFile != "" {
+		token, err := os.ReadFile(cfg.PushTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("push_token_file: %w", err)
 		}
+		cfg.PushToken = strings.TrimSpace(string(token))
+	}
+	if cfg.PushToken == "" {
+		cfg.PushToken = os.Getenv("ARGOS_AGENT_TOKEN")
 	}
 
-	for i := range cfg.Targets.ICMP {
-		if cfg.Targets.ICMP[i].Timeout == 0 {
-			cfg.Targets.ICMP[i].Timeout = 2 * time.Second
+	if err := compileRelabelConfigs(cfg.RelabelConfigs); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Targets.resolve(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.AgentID == "" {
+		return errors.New("agent_id is required")
+	}
+	if t := c.PushTLS; t != nil && (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("push_tls: cert_file and key_file must be set together")
+	}
+	if c.PushInterval < 0 {
+		return fmt.Errorf("push_interval %s: must be positive", c.PushInterval)
+	}
+	if c.PushStrategy != StrategyFailover && c.PushStrategy != StrategyReplicate {
+		return fmt.Errorf("push_strategy %q: must be %s or %s", c.PushStrategy, StrategyFailover, StrategyReplicate)
+	}
+	if c.PushQueueSize < 0 {
+		return fmt.Errorf("push_queue_size %d: must be positive", c.PushQueueSize)
+	}
+	if c.ProbeConcurrency < 0 {
+		return fmt.Errorf("probe_concurrency %d: must be positive", c.ProbeConcurrency)
+	}
+	if c.MetricsListen != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsListen); err != nil {
+			return fmt.Errorf("metrics_listen %q: %v", c.MetricsListen, err)
 		}
 	}
+	if c.ProbeTimeout < 0 {
+		return fmt.Errorf("probe_timeout %s: must be positive", c.ProbeTimeout)
+	}
 
-	for i := range cfg.Targets.Postgres {
-		if cfg.Targets.Postgres[i].PingSQL == "" {
-			cfg.Targets.Postgres[i].PingSQL = "SELECT 1"
+	if len(c.PushEndpoint) == 0 && c.MetricsListen == "" {
+		return errors.New("push_endpoint is required unless metrics_listen is set")
+	}
+	seen := map[string]bool{}
+	for i, endpoint := range c.PushEndpoint {
+		if err := validateURL(fmt.Sprintf("push_endpoint[%d]", i), endpoint, "http", "https"); err != nil {
+			return err
 		}
-		if cfg.Targets.Postgres[i].SlowMS == 0 {
-			cfg.Targets.Postgres[i].SlowMS = 100
+		if seen[endpoint] {
+			return fmt.Errorf("push_endpoint[%d] %q: duplicate endpoint", i, endpoint)
 		}
+		seen[endpoint] = true
 	}
-
-	return &cfg, nil
+	return nil
 }
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigTypedTargets(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://localhost:8081/ingest
+targets:
+  - type: http
+    name: site
+    url: https://example.com
+  - type: dns
+    name: resolver
+    fqdn: example.com
+    server: 1.1.1.1:53
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	if cfg.PushInterval != 10*time.Second {
+		t.Errorf("Expected default push_interval 10s, got %s", cfg.PushInterval)
+	}
+
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(cfg.Targets))
+	}
+
+	httpCfg, ok := cfg.Targets[0].Config().(*HTTPTarget)
+	if !ok {
+		t.Fatalf("Expected *HTTPTarget, got %T", cfg.Targets[0].Config())
+	}
+	if httpCfg.Method != "GET" || httpCfg.Timeout != 5*time.Second {
+		t.Errorf("HTTP defaults not applied: %+v", httpCfg)
+	}
+
+	probes := createProbes(cfg)
+	if len(probes) != 2 {
+		t.Fatal("Expected 2 probes to be created")
+	}
+
+	// probe_timeout usa o service das métricas do probe, não o tipo
+	if probes[0].service != "web" || probes[1].service != "dns" {
+		t.Errorf("Expected services web and dns, got %s and %s", probes[0].service, probes[1].service)
+	}
+}
+
+func TestParseConfigLegacyTargets(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://localhost:8081/ingest
+targets:
+  http:
+    - name: site
+      url: https://example.com
+  icmp:
+    - name: gateway
+      host: 192.168.1.1
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(cfg.Targets))
+	}
+	if cfg.Targets[1].Type != "icmp" {
+		t.Errorf("Expected type icmp, got %s", cfg.Targets[1].Type)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets string
+		want    string
+	}{
+		{"unknown type", "  - type: carrier-pigeon\n    name: x\n", `unknown probe type "carrier-pigeon"`},
+		{"missing type", "  - name: x\n    url: http://a\n", "type is required"},
+		{"missing name", "  - type: icmp\n    host: a\n", "name is required"},
+		{"duplicate name", "  - type: icmp\n    name: x\n    host: a\n  - type: icmp\n    name: x\n    host: b\n", "duplicate target name"},
+		{"bad url", "  - type: http\n    name: x\n    url: example.com/health\n", "missing host"},
+		{"bad scheme", "  - type: http\n    name: x\n    url: ftp://example.com\n", "scheme must be one of"},
+		{"missing field", "  - type: postgres\n    name: x\n", "dsn is required"},
+		{"bad server", "  - type: dns\n    name: x\n    fqdn: a.com\n    server: 8.8.8.8\n", "server"},
+		{"two secret sources", "  - type: http\n    name: x\n    url: http://a\n    bearer_token: t\n    bearer_token_env: T\n", "set only one of"},
+		{"missing secret env", "  - type: http\n    name: x\n    url: http://a\n    bearer_token_env: ARGOS_TEST_UNSET\n", "is not set"},
+		{"bad ip_version", "  - type: http\n    name: x\n    url: http://a\n    ip_version: 5\n", "ip_version"},
+		{"websocket scheme", "  - type: websocket\n    name: x\n    url: http://a\n", "scheme must be one of"},
+		{"websocket expect without send", "  - type: websocket\n    name: x\n    url: ws://a\n    expect: pong\n", "expect requires send"},
+		{"grpc address", "  - type: grpc\n    name: x\n    address: orders\n", "address"},
+		{"ssh fingerprint", "  - type: ssh\n    name: x\n    host: a\n    fingerprints: [md5:aa]\n", "expected SHA256"},
+		{"unknown field", "  - type: http\n    name: x\n    url: http://a\n    tiemout: 5s\n", `line 7: unknown field "tiemout"`},
+		{"unknown nested field", "  - type: http\n    name: x\n    url: https://a\n    tls:\n      cert: c.pem\n", `line 8: unknown field "cert"`},
+		{"missing client key", "  - type: http\n    name: x\n    url: https://a\n    tls:\n      cert_file: c.pem\n", "must be set together"},
+	}
+
+	for _, tt := range tests {
+		data := "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets:\n" + tt.targets
+		_, err := ParseConfig([]byte(data))
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %q", tt.name, tt.want, err)
+		}
+		if !strings.Contains(err.Error(), "line ") {
+			t.Errorf("%s: expected line number in error, got %q", tt.name, err)
+		}
+	}
+}
+
+func TestParseConfigHTTPOptions(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	os.WriteFile(passwordFile, []byte("hunter2\n"), 0o600)
+	t.Setenv("ARGOS_TEST_TOKEN", "from-env")
+
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://localhost:8081/ingest
+targets:
+  - type: http
+    name: api
+    url: https://api.example.com/health
+    method: POST
+    body: '{"ping":true}'
+    headers:
+      X-Env: prod
+    bearer_token_env: ARGOS_TEST_TOKEN
+    follow_redirects: false
+    ip_version: 6
+    proxy_url: http://proxy:3128
+    tls:
+      insecure_skip_verify: true
+  - type: http
+    name: admin
+    url: https://admin.example.com
+    basic_auth:
+      username: monitor
+      password_file: ` + passwordFile + `
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	api := cfg.Targets[0].Config().(*HTTPTarget).options
+	if api.BearerToken != "from-env" || api.Body != `{"ping":true}` || api.Headers["X-Env"] != "prod" {
+		t.Errorf("Request options not applied: %+v", api)
+	}
+	if api.FollowRedirects || api.IPVersion != 6 || api.ProxyURL.Host != "proxy:3128" {
+		t.Errorf("Transport options not applied: %+v", api)
+	}
+	if api.TLSConfig == nil || !api.TLSConfig.InsecureSkipVerify {
+		t.Error("Expected insecure_skip_verify to be applied")
+	}
+
+	admin := cfg.Targets[1].Config().(*HTTPTarget).options
+	if admin.BasicAuthUser != "monitor" || admin.BasicAuthPassword != "hunter2" {
+		t.Errorf("Expected basic auth from file, got %q/%q", admin.BasicAuthUser, admin.BasicAuthPassword)
+	}
+	if !admin.FollowRedirects || admin.MaxRedirects != 10 {
+		t.Errorf("Expected default redirect policy, got %v/%d", admin.FollowRedirects, admin.MaxRedirects)
+	}
+}
+
+func TestParseConfigRequiresPushEndpoint(t *testing.T) {
+	_, err := ParseConfig([]byte("agent_id: a\n"))
+	if err == nil || !strings.Contains(err.Error(), "push_endpoint") {
+		t.Errorf("Expected push_endpoint error, got %v", err)
+	}
+
+	// Só com o listener o agente roda sem enviar nada
+	cfg, err := ParseConfig([]byte("agent_id: a\nmetrics_listen: \":9464\"\n"))
+	if err != nil {
+		t.Fatalf("Expected pull-only config to be valid, got %v", err)
+	}
+	if len(cfg.PushEndpoint) != 0 {
+		t.Errorf("Expected no push endpoints, got %v", cfg.PushEndpoint)
+	}
+}
+
+func TestExampleConfigsAreValid(t *testing.T) {
+	for _, path := range []string{"config.example.yaml", "config.docker.yaml"} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if _, err := LoadConfig(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestClockProbeServer(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://localhost:8081/ingest
+targets:
+  - type: ntp
+    name: time
+    servers: [ntp1.local, ntp2.local]
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	if clock := newClockProbe(cfg); clock == nil || clock.server != "ntp1.local" {
+		t.Errorf("Expected clock probe using first ntp server, got %+v", clock)
+	}
+
+	cfg.ClockServer = "time.example.com"
+	if clock := newClockProbe(cfg); clock == nil || clock.server != "time.example.com" {
+		t.Errorf("Expected clock_server to take precedence, got %+v", clock)
+	}
+
+	cfg.ClockServer = ""
+	cfg.Targets = nil
+	if clock := newClockProbe(cfg); clock != nil {
+		t.Errorf("Expected no clock probe without ntp servers, got %+v", clock)
+	}
+}
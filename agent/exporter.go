@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"argos/shared"
+)
+
+var (
+	promInvalidNameChars  = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	promInvalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// promExporter expõe o resultado da última coleta no formato texto do
+// Prometheus, para uso em modo pull. service e target viram labels; um
+// label do probe com o mesmo nome é renomeado para exported_<nome>.
+type promExporter struct {
+	mu      sync.RWMutex
+	metrics []shared.Metric
+	// self é chamado a cada scrape para as métricas do próprio agente
+	self func() []shared.Metric
+}
+
+func newPromExporter(self func() []shared.Metric) *promExporter {
+	return &promExporter{self: self}
+}
+
+// Update substitui o resultado exposto pelo da coleta mais recente
+func (e *promExporter) Update(metrics []shared.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics = metrics
+}
+
+func (e *promExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	metrics := append([]shared.Metric(nil), e.metrics...)
+	e.mu.RUnlock()
+
+	if e.self != nil {
+		metrics = append(metrics, e.self()...)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writePromText(w, metrics)
+}
+
+type promSample struct {
+	labels string
+	value  float64
+}
+
+func writePromText(w io.Writer, metrics []shared.Metric) {
+	families := map[string][]promSample{}
+	seen := map[string]bool{}
+
+	for _, m := range metrics {
+		name := promName(m.Name)
+		labels := promLabels(m)
+		// Séries repetidas no mesmo scrape são inválidas; vale a primeira
+		if key := name + labels; !seen[key] {
+			seen[key] = true
+			families[name] = append(families[name], promSample{labels: labels, value: m.Value})
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		samples := families[name]
+		sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+
+		kind := "gauge"
+		if strings.HasSuffix(name, "_total") {
+			kind = "counter"
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+		for _, s := range samples {
+			fmt.Fprintf(w, "%s%s %s\n", name, s.labels, promValue(s.value))
+		}
+	}
+}
+
+func promName(name string) string {
+	name = promInvalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func promLabels(m shared.Metric) string {
+	labels := map[string]string{}
+	for k, v := range m.Labels {
+		k = promInvalidLabelChars.ReplaceAllString(k, "_")
+		if k == "" || (k[0] >= '0' && k[0] <= '9') {
+			k = "_" + k
+		}
+		if k == "service" || k == "target" || strings.HasPrefix(k, "__") {
+			k = "exported_" + strings.TrimLeft(k, "_")
+		}
+		labels[k] = v
+	}
+	labels["service"] = m.Service
+	labels["target"] = m.Target
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(promEscape(labels[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(v string) string {
+	return promEscaper.Replace(v)
+}
+
+func promValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"argos/shared"
+)
+
+func TestPromExporterFormat(t *testing.T) {
+	e := newPromExporter(func() []shared.Metric {
+		return []shared.Metric{{Service: "agent", Target: "agent-01", Name: "agent_push_success_total", Value: 3}}
+	})
+	e.Update([]shared.Metric{
+		{Service: "http", Target: "site", Name: "http_up", Value: 1, Labels: map[string]string{"url": "https://example.com"}},
+		{Service: "http", Target: "api", Name: "http_up", Value: 0},
+		{Service: "exec", Target: "job", Name: "exec.duration-ms", Value: math.NaN(),
+			Labels: map[string]string{"output": "line \"1\"\nline 2", "service": "billing", "env-name": "prod"}},
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	expected := []string{
+		"# TYPE agent_push_success_total counter\n",
+		`agent_push_success_total{service="agent",target="agent-01"} 3`,
+		"# TYPE http_up gauge\n",
+		`http_up{service="http",target="api"} 0` + "\n" + `http_up{service="http",target="site",url="https://example.com"} 1`,
+		`exec_duration_ms{env_name="prod",exported_service="billing",output="line \"1\"\nline 2",service="exec",target="job"} NaN`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, body)
+		}
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+}
+
+func TestPromExporterReplacesPreviousCollection(t *testing.T) {
+	e := newPromExporter(nil)
+	e.Update([]shared.Metric{{Service: "dns", Target: "old", Name: "dns_up", Value: 1}})
+	e.Update([]shared.Metric{{Service: "dns", Target: "new", Name: "dns_up", Value: 1}})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(w.Body.String(), `target="old"`) {
+		t.Errorf("Expected only the latest collection, got:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"argos/shared"
+)
+
+const (
+	StrategyFailover  = "failover"
+	StrategyReplicate = "replicate"
+
+	maxPushBackoff = 5 * time.Minute
+)
+
+// pushEndpoint é um destino de ingestão com fila e estado de saúde próprios
+type pushEndpoint struct {
+	URL    string
+	pusher *shared.Pusher
+
+	mu        sync.Mutex
+	queue     [][]shared.Metric
+	maxQueue  int
+	failures  int
+	retryAt   time.Time
+	lastError string
+
+	successTotal  int64
+	failureTotal  int64
+	droppedTotal  int64
+	rejectedTotal int64
+}
+
+func (e *pushEndpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.retryAt)
+}
+
+// enqueue adiciona lotes ao final da fila, descartando os mais antigos se cheia
+func (e *pushEndpoint) enqueue(batches ...[]shared.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.queue = append(e.queue, batches...)
+	if over := len(e.queue) - e.maxQueue; over > 0 {
+		e.queue = e.queue[over:]
+		e.droppedTotal += int64(over)
+		log.Printf("Push queue for %s full, dropped %d oldest batches", e.URL, over)
+	}
+}
+
+func (e *pushEndpoint) drain() [][]shared.Metric {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	batches := e.queue
+	e.queue = nil
+	return batches
+}
+
+// flush envia os lotes em ordem até o primeiro erro; os restantes ficam na
+// fila. Um lote que a API recusa de vez (400, 413, 422) é descartado para
+// não travar os seguintes, e um 429 adia a próxima tentativa pelo
+// Retry-After.
+func (e *pushEndpoint) flush(agentID string, backoffBase time.Duration) error {
+	for {
+		e.mu.Lock()
+		if len(e.queue) == 0 {
+			e.mu.Unlock()
+			return nil
+		}
+		batch := e.queue[0]
+		e.mu.Unlock()
+
+		err := e.pusher.Push(agentID, batch)
+
+		var statusErr *shared.StatusError
+		errors.As(err, &statusErr)
+
+		e.mu.Lock()
+		if statusErr != nil && statusErr.Permanent() {
+			log.Printf("Push endpoint %s rejected a batch of %d metrics (%v), dropping it", e.URL, len(batch), err)
+			e.queue = e.queue[1:]
+			e.rejectedTotal++
+			e.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			e.failures++
+			e.failureTotal++
+			e.lastError = err.Error()
+			backoff := backoffBase << min(e.failures-1, 16)
+			if backoff > maxPushBackoff || backoff < 0 {
+				backoff = maxPushBackoff
+			}
+			if statusErr != nil && statusErr.RetryAfter > 0 {
+				backoff = statusErr.RetryAfter
+			}
+			e.retryAt = time.Now().Add(backoff)
+			e.mu.Unlock()
+			return err
+		}
+
+		if e.failures > 0 {
+			log.Printf("Push endpoint %s recovered after %d failures", e.URL, e.failures)
+		}
+		log.Printf("Pushed %d metrics to %s", len(batch), e.URL)
+		e.queue = e.queue[1:]
+		e.failures = 0
+		e.lastError = ""
+		e.retryAt = time.Time{}
+		e.successTotal++
+		e.mu.Unlock()
+	}
+}
+
+// Forwarder distribui os lotes entre os endpoints de ingestão configurados
+type Forwarder struct {
+	AgentID     string
+	Strategy    string
+	BackoffBase time.Duration
+	Endpoints   []*pushEndpoint
+}
+
+func NewForwarder(cfg *Config, newPusher func(endpoint string) *shared.Pusher) *Forwarder {
+	f := &Forwarder{
+		AgentID:     cfg.AgentID,
+		Strategy:    cfg.PushStrategy,
+		BackoffBase: cfg.PushInterval,
+	}
+
+	for _, url := range cfg.PushEndpoint {
+		f.Endpoints = append(f.Endpoints, &pushEndpoint{
+			URL:      url,
+			pusher:   newPusher(url),
+			maxQueue: cfg.PushQueueSize,
+		})
+	}
+
+	return f
+}
+
+// Send enfileira o lote e tenta entregar tudo o que estiver pendente
+func (f *Forwarder) Send(metrics []shared.Metric) {
+	f.Enqueue(metrics)
+	f.Flush()
+}
+
+// Enqueue coloca o lote na fila sem enviar: no replicate, na de cada
+// endpoint; no failover, na do primeiro, de onde Flush o repassa
+func (f *Forwarder) Enqueue(metrics []shared.Metric) {
+	if f.Strategy == StrategyReplicate {
+		for _, e := range f.Endpoints {
+			e.enqueue(metrics)
+		}
+	} else {
+		f.Endpoints[0].enqueue(metrics)
+	}
+}
+
+// Flush tenta entregar tudo o que estiver pendente
+func (f *Forwarder) Flush() {
+	if f.Strategy == StrategyReplicate {
+		f.flushReplicate()
+	} else {
+		f.flushFailover()
+	}
+}
+
+func (f *Forwarder) flushReplicate() {
+	now := time.Now()
+	var wg sync.WaitGroup
+
+	for _, e := range f.Endpoints {
+		if !e.available(now) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(e *pushEndpoint) {
+			defer wg.Done()
+			if err := e.flush(f.AgentID, f.BackoffBase); err != nil {
+				log.Printf("Failed to push metrics to %s: %v", e.URL, err)
+			}
+		}(e)
+	}
+
+	wg.Wait()
+}
+
+// flushFailover entrega no primeiro endpoint disponível. A fila de um
+// endpoint indisponível ou que falhou é repassada ao próximo; o último a
+// mantém.
+func (f *Forwarder) flushFailover() {
+	now := time.Now()
+
+	var carry [][]shared.Metric
+	for i, e := range f.Endpoints {
+		if len(carry) > 0 {
+			// lotes repassados vão para o início da fila (são mais antigos)
+			pending := e.drain()
+			e.enqueue(append(carry, pending...)...)
+			carry = nil
+		}
+
+		if e.available(now) {
+			err := e.flush(f.AgentID, f.BackoffBase)
+			if err == nil {
+				continue
+			}
+			log.Printf("Failed to push metrics to %s: %v", e.URL, err)
+		}
+
+		if i < len(f.Endpoints)-1 {
+			carry = e.drain()
+		}
+	}
+}
+
+// RecordSecurityEvent envia o evento à API
+func (f *Forwarder) RecordSecurityEvent(event shared.SecurityEvent) {
+	if event.Metadata == nil {
+		event.Metadata = map[string]interface{}{}
+	}
+	event.Metadata["agent_id"] = f.AgentID
+
+	delivered := f.deliver("record security event", func(p *shared.Pusher) error {
+		return p.RecordSecurityEvent(event)
+	})
+	if delivered {
+		log.Printf("Security event recorded: %s", event.Description)
+	}
+}
+
+// Register envia o inventário do agente; retorna false se nenhum endpoint aceitou
+func (f *Forwarder) Register(hb shared.AgentHeartbeat) bool {
+	return f.deliver("register", func(p *shared.Pusher) error { return p.Register(hb) })
+}
+
+func (f *Forwarder) Heartbeat(hb shared.AgentHeartbeat) bool {
+	return f.deliver("send heartbeat", func(p *shared.Pusher) error { return p.Heartbeat(hb) })
+}
+
+// deliver faz uma chamada avulsa à API fora da fila de lotes. No failover
+// basta um endpoint aceitar; no replicate ela é enviada a todos.
+func (f *Forwarder) deliver(what string, send func(p *shared.Pusher) error) bool {
+	delivered := false
+	for _, e := range f.Endpoints {
+		if err := send(e.pusher); err != nil {
+			log.Printf("Failed to %s on %s: %v", what, e.URL, err)
+			continue
+		}
+		delivered = true
+		if f.Strategy != StrategyReplicate {
+			break
+		}
+	}
+	return delivered
+}
+
+// SelfMetrics descreve o estado de cada endpoint como métricas do próprio agente
+func (f *Forwarder) SelfMetrics() []shared.Metric {
+	ts := time.Now()
+	var metrics []shared.Metric
+
+	for _, e := range f.Endpoints {
+		e.mu.Lock()
+		labels := map[string]string{"endpoint": e.URL, "strategy": f.Strategy}
+		up := 1.0
+		if e.failures > 0 {
+			up = 0
+		}
+		values := []struct {
+			name  string
+			value float64
+		}{
+			{"agent_push_endpoint_up", up},
+			{"agent_push_success_total", float64(e.successTotal)},
+			{"agent_push_failure_total", float64(e.failureTotal)},
+			{"agent_push_dropped_total", float64(e.droppedTotal)},
+			{"agent_push_rejected_total", float64(e.rejectedTotal)},
+			{"agent_push_queue_batches", float64(len(e.queue))},
+		}
+		e.mu.Unlock()
+
+		for _, v := range values {
+			metrics = append(metrics, shared.Metric{
+				Service: "agent", Target: f.AgentID, Name: v.name, Value: v.value, Labels: labels, TS: ts,
+			})
+		}
+	}
+
+	return metrics
+}
+
+func (f *Forwarder) String() string {
+	return fmt.Sprintf("%v (%s)", f.endpointURLs(), f.Strategy)
+}
+
+func (f *Forwarder) endpointURLs() []string {
+	urls := make([]string, len(f.Endpoints))
+	for i, e := range f.Endpoints {
+		urls[i] = e.URL
+	}
+	return urls
+}
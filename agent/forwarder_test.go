@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+type ingestServer struct {
+	*httptest.Server
+	down     atomic.Bool
+	received atomic.Int64
+}
+
+func newIngestServer(t *testing.T) *ingestServer {
+	s := &ingestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.received.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestForwarder(strategy string, queueSize int, servers ...*ingestServer) *Forwarder {
+	cfg := &Config{AgentID: "agent-test", PushStrategy: strategy, PushQueueSize: queueSize}
+	for _, s := range servers {
+		cfg.PushEndpoint = append(cfg.PushEndpoint, s.URL)
+	}
+	return NewForwarder(cfg, shared.NewPusher)
+}
+
+func testBatch() []shared.Metric {
+	return []shared.Metric{{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: time.Now()}}
+}
+
+func TestForwarderFailover(t *testing.T) {
+	primary := newIngestServer(t)
+	standby := newIngestServer(t)
+	f := newTestForwarder(StrategyFailover, 10, primary, standby)
+	f.BackoffBase = time.Minute
+
+	f.Send(testBatch())
+	if primary.received.Load() != 1 || standby.received.Load() != 0 {
+		t.Fatalf("Expected batch on primary only, got primary=%d standby=%d",
+			primary.received.Load(), standby.received.Load())
+	}
+
+	primary.down.Store(true)
+	f.Send(testBatch())
+	if standby.received.Load() != 1 {
+		t.Errorf("Expected standby to receive batch when primary is down, got %d", standby.received.Load())
+	}
+
+	// Primário em backoff: o próximo lote vai direto ao standby
+	primary.down.Store(false)
+	f.Send(testBatch())
+	if primary.received.Load() != 1 || standby.received.Load() != 2 {
+		t.Errorf("Expected primary to be skipped during backoff, got primary=%d standby=%d",
+			primary.received.Load(), standby.received.Load())
+	}
+}
+
+func TestForwarderReplicate(t *testing.T) {
+	a := newIngestServer(t)
+	b := newIngestServer(t)
+	f := newTestForwarder(StrategyReplicate, 10, a, b)
+
+	f.Send(testBatch())
+	f.Send(testBatch())
+
+	if a.received.Load() != 2 || b.received.Load() != 2 {
+		t.Errorf("Expected 2 batches on each endpoint, got a=%d b=%d", a.received.Load(), b.received.Load())
+	}
+}
+
+func TestForwarderEnqueueThenFlush(t *testing.T) {
+	a := newIngestServer(t)
+	b := newIngestServer(t)
+	f := newTestForwarder(StrategyReplicate, 10, a, b)
+
+	// Enqueue não toca a rede; o envio fica para o Flush
+	f.Enqueue(testBatch())
+	f.Enqueue(testBatch())
+	if a.received.Load() != 0 || b.received.Load() != 0 {
+		t.Fatalf("Expected nothing sent before Flush, got a=%d b=%d", a.received.Load(), b.received.Load())
+	}
+
+	f.Flush()
+	if a.received.Load() != 2 || b.received.Load() != 2 {
+		t.Errorf("Expected 2 batches on each endpoint, got a=%d b=%d", a.received.Load(), b.received.Load())
+	}
+}
+
+func TestForwarderQueuesUntilRecovery(t *testing.T) {
+	server := newIngestServer(t)
+	f := newTestForwarder(StrategyFailover, 2, server)
+	f.BackoffBase = 0
+
+	server.down.Store(true)
+	f.Send(testBatch())
+	f.Send(testBatch())
+	f.Send(testBatch())
+
+	e := f.Endpoints[0]
+	if len(e.queue) != 2 || e.droppedTotal != 1 {
+		t.Fatalf("Expected 2 queued and 1 dropped batch, got queued=%d dropped=%d", len(e.queue), e.droppedTotal)
+	}
+
+	server.down.Store(false)
+	f.Send(testBatch())
+	// O lote novo entra na fila cheia e descarta mais um antigo
+	if server.received.Load() != 2 || e.droppedTotal != 2 {
+		t.Errorf("Expected 2 batches flushed after recovery, got received=%d dropped=%d",
+			server.received.Load(), e.droppedTotal)
+	}
+	if len(e.queue) != 0 {
+		t.Errorf("Expected empty queue, got %d", len(e.queue))
+	}
+}
+
+func TestForwarderDropsRejectedBatch(t *testing.T) {
+	var accepted atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch shared.Batch
+		json.NewDecoder(r.Body).Decode(&batch)
+		if len(batch.Items) > 1 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		accepted.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := &Config{AgentID: "agent-test", PushStrategy: StrategyFailover, PushQueueSize: 10, PushEndpoint: []string{server.URL}}
+	f := NewForwarder(cfg, shared.NewPusher)
+	f.BackoffBase = time.Minute
+
+	// O lote grande demais é descartado e não trava o seguinte
+	f.Send(append(testBatch(), testBatch()...))
+	f.Send(testBatch())
+
+	e := f.Endpoints[0]
+	if accepted.Load() != 1 {
+		t.Errorf("Expected the next batch to be delivered, got %d", accepted.Load())
+	}
+	if len(e.queue) != 0 || e.rejectedTotal != 1 || e.failures != 0 {
+		t.Errorf("Expected rejected batch to be dropped, got queued=%d rejected=%d failures=%d",
+			len(e.queue), e.rejectedTotal, e.failures)
+	}
+}
+
+func TestForwarderHonoursRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cfg := &Config{AgentID: "agent-test", PushStrategy: StrategyFailover, PushQueueSize: 10, PushEndpoint: []string{server.URL}}
+	f := NewForwarder(cfg, shared.NewPusher)
+	f.BackoffBase = time.Hour
+
+	f.Send(testBatch())
+
+	e := f.Endpoints[0]
+	if len(e.queue) != 1 {
+		t.Fatalf("Expected throttled batch to stay queued, got %d", len(e.queue))
+	}
+	if wait := time.Until(e.retryAt); wait <= 5*time.Second || wait > 7*time.Second {
+		t.Errorf("Expected retry in about 7s, got %s", wait)
+	}
+}
+
+func TestForwarderSelfMetrics(t *testing.T) {
+	ok := newIngestServer(t)
+	failing := newIngestServer(t)
+	failing.down.Store(true)
+	f := newTestForwarder(StrategyReplicate, 10, ok, failing)
+
+	f.Send(testBatch())
+
+	values := map[string]float64{}
+	for _, m := range f.SelfMetrics() {
+		values[m.Labels["endpoint"]+" "+m.Name] = m.Value
+	}
+
+	checks := map[string]float64{
+		ok.URL + " agent_push_endpoint_up":        1,
+		ok.URL + " agent_push_success_total":      1,
+		failing.URL + " agent_push_endpoint_up":   0,
+		failing.URL + " agent_push_failure_total": 1,
+		failing.URL + " agent_push_queue_batches": 1,
+	}
+	for key, want := range checks {
+		if got, found := values[key]; !found || got != want {
+			t.Errorf("%s: expected %v, got %v (found=%v)", key, want, got, found)
+		}
+	}
+}
+
+func TestParseConfigPushEndpoints(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint:
+  - http://primary:8081/ingest
+  - http://standby:8081/ingest
+push_strategy: replicate
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if len(cfg.PushEndpoint) != 2 || cfg.PushStrategy != StrategyReplicate || cfg.PushQueueSize != 100 {
+		t.Errorf("Unexpected push config: %+v", cfg)
+	}
+
+	if _, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://primary:8081/ingest
+push_strategy: roundrobin
+`)); err == nil {
+		t.Error("Expected error for unknown push_strategy")
+	}
+}
+
+func TestForwarderRecordSecurityEvent(t *testing.T) {
+	var got shared.SecurityEvent
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &Config{AgentID: "agent-test", PushStrategy: StrategyFailover, PushEndpoint: EndpointList{server.URL + "/ingest"}}
+	f := NewForwarder(cfg, shared.NewPusher)
+	f.RecordSecurityEvent(shared.SecurityEvent{Type: "ssh_hostkey_changed", Severity: "high", Description: "changed"})
+
+	if path != "/api/security/record-event" {
+		t.Errorf("Expected event posted to /api/security/record-event, got %q", path)
+	}
+	if got.Type != "ssh_hostkey_changed" || got.Metadata["agent_id"] != "agent-test" {
+		t.Errorf("Unexpected event payload: %+v", got)
+	}
+}
+
+func TestForwarderRegisterAndHeartbeat(t *testing.T) {
+	var paths []string
+	var registered shared.AgentHeartbeat
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/api/agents/register" {
+			json.NewDecoder(r.Body).Decode(&registered)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: ` + server.URL + `/ingest
+targets:
+  - type: http
+    name: site
+    url: https://example.com
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	f := NewForwarder(cfg, shared.NewPusher)
+
+	if !f.Register(agentInventory(cfg, time.Now())) {
+		t.Fatal("Expected register to be delivered")
+	}
+	if !f.Heartbeat(shared.AgentHeartbeat{AgentID: cfg.AgentID, TS: time.Now()}) {
+		t.Fatal("Expected heartbeat to be delivered")
+	}
+
+	if len(paths) != 2 || paths[0] != "/api/agents/register" || paths[1] != "/api/agents/heartbeat" {
+		t.Errorf("Unexpected request paths %v", paths)
+	}
+	if registered.Version != version || len(registered.Probes) != 1 || registered.Probes[0].Name != "site" {
+		t.Errorf("Unexpected inventory %+v", registered)
+	}
+}
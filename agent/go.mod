@@ -5,8 +5,17 @@ go 1.22
 require (
 	argos/shared v0.0.0
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.31.0
+	google.golang.org/grpc v1.67.3
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-replace argos/shared => ../shared
+require (
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
 
+replace argos/shared => ../shared
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"argos/agent/probes"
+	"argos/shared"
+)
+
+// HTTPClientConfig reúne as opções de requisição e transporte comuns aos
+// probes baseados em HTTP. É embutido inline na configuração do target.
+type HTTPClientConfig struct {
+	Headers         map[string]string `yaml:"headers"`
+	BasicAuth       *BasicAuthConfig  `yaml:"basic_auth"`
+	BearerToken     string            `yaml:"bearer_token"`
+	BearerTokenFile string            `yaml:"bearer_token_file"`
+	BearerTokenEnv  string            `yaml:"bearer_token_env"`
+	FollowRedirects *bool             `yaml:"follow_redirects"`
+	MaxRedirects    int               `yaml:"max_redirects"`
+	TLS             *ProbeTLSConfig   `yaml:"tls"`
+	ProxyURL        string            `yaml:"proxy_url"`
+	IPVersion       int               `yaml:"ip_version"`
+}
+
+type BasicAuthConfig struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	PasswordEnv  string `yaml:"password_env"`
+}
+
+// ProbeTLSConfig configura TLS do lado do probe. Com ca_file o servidor só é
+// aceito se sua cadeia for válida contra esse CA.
+type ProbeTLSConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+}
+
+// options valida a configuração e resolve segredos e certificados, para que
+// erros apareçam no carregamento do config e não na primeira coleta.
+func (c *HTTPClientConfig) options() (probes.HTTPOptions, error) {
+	opts := probes.DefaultHTTPOptions()
+	opts.Headers = c.Headers
+
+	if c.FollowRedirects != nil {
+		opts.FollowRedirects = *c.FollowRedirects
+	}
+	if c.MaxRedirects < 0 {
+		return opts, fmt.Errorf("max_redirects %d: must be positive", c.MaxRedirects)
+	}
+	if c.MaxRedirects > 0 {
+		opts.MaxRedirects = c.MaxRedirects
+	}
+
+	token, err := resolveSecret("bearer_token", c.BearerToken, c.BearerTokenFile, c.BearerTokenEnv)
+	if err != nil {
+		return opts, err
+	}
+	opts.BearerToken = token
+
+	if a := c.BasicAuth; a != nil {
+		if token != "" {
+			return opts, errors.New("basic_auth and bearer_token are mutually exclusive")
+		}
+		if a.Username == "" {
+			return opts, errors.New("basic_auth.username is required")
+		}
+		password, err := resolveSecret("basic_auth.password", a.Password, a.PasswordFile, a.PasswordEnv)
+		if err != nil {
+			return opts, err
+		}
+		opts.BasicAuthUser = a.Username
+		opts.BasicAuthPassword = password
+	}
+
+	if c.TLS != nil {
+		cfg, err := c.TLS.clientConfig()
+		if err != nil {
+			return opts, err
+		}
+		opts.TLSConfig = cfg
+	}
+
+	if c.ProxyURL != "" {
+		if err := validateURL("proxy_url", c.ProxyURL, "http", "https", "socks5"); err != nil {
+			return opts, err
+		}
+		opts.ProxyURL, _ = url.Parse(c.ProxyURL)
+	}
+
+	switch c.IPVersion {
+	case 0, 4, 6:
+		opts.IPVersion = c.IPVersion
+	default:
+		return opts, fmt.Errorf("ip_version %d: must be 4 or 6", c.IPVersion)
+	}
+
+	return opts, nil
+}
+
+func (t *ProbeTLSConfig) clientConfig() (*tls.Config, error) {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	reloader, err := shared.NewCertReloader(t.CertFile, t.KeyFile, t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+
+	cfg := reloader.ClientConfig(t.ServerName)
+	if t.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = nil
+	}
+	return cfg, nil
+}
+
+// resolveSecret retorna o segredo definido em linha, em arquivo ou em
+// variável de ambiente. Apenas uma das fontes pode ser usada.
+func resolveSecret(field, value, file, env string) (string, error) {
+	sources := 0
+	for _, s := range []string{value, file, env} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("%s: set only one of %s, %s_file or %s_env", field, field, field, field)
+	}
+
+	switch {
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("%s_file: %w", field, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case env != "":
+		secret, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("%s_env: environment variable %s is not set", field, env)
+		}
+		return secret, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"argos/agent/probes"
+	"gopkg.in/yaml.v3"
+)
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// JSONMetricConfig mapeia uma expressão do documento para uma métrica:
+//
+//	metrics:
+//	  - name: worker_busy
+//	    path: workers.#.busy
+//	    labels: [{name: worker, field: id}]
+//	  - name: service_status
+//	    path: status
+//	    value_map: {ok: 1, degraded: 0.5, down: 0}
+type JSONMetricConfig struct {
+	Name     string             `yaml:"name"`
+	Path     string             `yaml:"path"`
+	Labels   []JSONLabelConfig  `yaml:"labels"`
+	ValueMap map[string]float64 `yaml:"value_map"`
+}
+
+// JSONLabelConfig aceita só o nome do label ("worker") ou {name, field}
+type JSONLabelConfig struct {
+	Name  string `yaml:"name"`
+	Field string `yaml:"field"`
+}
+
+func (l *JSONLabelConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		l.Name = node.Value
+		return nil
+	}
+	type plain JSONLabelConfig
+	return node.Decode((*plain)(l))
+}
+
+func compileJSONMetrics(configs []JSONMetricConfig) ([]probes.JSONMetric, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("at least one metric is required")
+	}
+
+	seen := map[string]bool{}
+	compiled := make([]probes.JSONMetric, 0, len(configs))
+	for i, c := range configs {
+		if !metricNamePattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("metrics[%d]: name %q must match %s", i, c.Name, metricNamePattern)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("metric %q: duplicate name", c.Name)
+		}
+		seen[c.Name] = true
+
+		if c.Path == "" {
+			return nil, fmt.Errorf("metric %q: path is required", c.Name)
+		}
+		wildcards := probes.JSONPathWildcards(c.Path)
+		if len(c.Labels) > wildcards {
+			return nil, fmt.Errorf("metric %q: %d labels but path %q has %d wildcards", c.Name, len(c.Labels), c.Path, wildcards)
+		}
+
+		m := probes.JSONMetric{Name: c.Name, Path: c.Path, ValueMap: c.ValueMap}
+		labelNames := map[string]bool{"url": true}
+		for _, l := range c.Labels {
+			if !metricNamePattern.MatchString(l.Name) {
+				return nil, fmt.Errorf("metric %q: label name %q must match %s", c.Name, l.Name, metricNamePattern)
+			}
+			if labelNames[l.Name] {
+				return nil, fmt.Errorf("metric %q: label %q is reserved or duplicated", c.Name, l.Name)
+			}
+			labelNames[l.Name] = true
+			m.Labels = append(m.Labels, probes.JSONLabel{Name: l.Name, Field: l.Field})
+		}
+		compiled = append(compiled, m)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigHTTPJSONTarget(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://localhost:8081/ingest
+targets:
+  - type: http_json
+    name: queue-health
+    url: http://queue.internal/health
+    metrics:
+      - name: queue_depth
+        path: queue.depth
+      - name: worker_busy
+        path: workers.#.busy
+        labels: [{name: worker, field: id}]
+      - name: shard_lag
+        path: $.shards[*].lag
+        labels: [shard]
+      - name: service_status
+        path: status
+        value_map: {ok: 1, degraded: 0.5}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	target := cfg.Targets[0].Config().(*HTTPJSONTarget)
+	if len(target.metrics) != 4 {
+		t.Fatalf("Expected 4 metrics, got %d", len(target.metrics))
+	}
+	if l := target.metrics[1].Labels[0]; l.Name != "worker" || l.Field != "id" {
+		t.Errorf("Unexpected label config: %+v", l)
+	}
+	if l := target.metrics[2].Labels[0]; l.Name != "shard" || l.Field != "" {
+		t.Errorf("Expected shorthand label, got %+v", l)
+	}
+	if target.metrics[3].ValueMap["degraded"] != 0.5 {
+		t.Errorf("Unexpected value_map: %v", target.metrics[3].ValueMap)
+	}
+}
+
+func TestParseConfigHTTPJSONErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics string
+		want    string
+	}{
+		{"no metrics", "    metrics: []\n", "at least one metric"},
+		{"bad name", "    metrics:\n      - name: queue-depth\n        path: a\n", "must match"},
+		{"missing path", "    metrics:\n      - name: depth\n", "path is required"},
+		{"too many labels", "    metrics:\n      - name: depth\n        path: a.b\n        labels: [x]\n", "wildcards"},
+		{"reserved label", "    metrics:\n      - name: depth\n        path: a.#\n        labels: [url]\n", "reserved"},
+	}
+
+	for _, tt := range tests {
+		data := "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets:\n  - type: http_json\n    name: j\n    url: http://a\n" + tt.metrics
+		_, err := ParseConfig([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
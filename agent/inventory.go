@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"argos/shared"
+)
+
+// version é definido no build com -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// agentInventory descreve o agente para o registro na API
+func agentInventory(cfg *Config, startedAt time.Time) shared.AgentHeartbeat {
+	hostname, _ := os.Hostname()
+
+	probeList := make([]shared.AgentProbe, 0, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		probeList = append(probeList, shared.AgentProbe{Type: t.Type, Name: t.Name})
+	}
+
+	return shared.AgentHeartbeat{
+		AgentID:   cfg.AgentID,
+		Version:   version,
+		Hostname:  hostname,
+		Probes:    probeList,
+		StartedAt: startedAt,
+	}
+}
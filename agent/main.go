@@ -3,9 +3,10 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -18,23 +19,71 @@ type Probe interface {
 }
 
 func main() {
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "config.yaml"
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func defaultConfigPath() string {
+	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
+		return configPath
 	}
+	return "config.yaml"
+}
 
+func runAgent(configPath string) {
 	cfg, err := LoadConfig(configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
 	log.Printf("Starting Argos Agent: %s", cfg.AgentID)
-	log.Printf("Pushing metrics to: %s every %s", cfg.PushEndpoint, cfg.PushInterval)
+	var reloader *shared.CertReloader
+	if t := cfg.PushTLS; t != nil {
+		reloader, err = shared.NewCertReloader(t.CertFile, t.KeyFile, t.CAFile)
+		if err != nil {
+			log.Fatalf("Failed to load push TLS config: %v", err)
+		}
+	}
+	if cfg.PushToken == "" && (cfg.PushTLS == nil || cfg.PushTLS.CertFile == "") {
+		log.Printf("WARNING: no push_token or client certificate configured, ingest will fail if the API requires authentication")
+	}
 
-	pusher := shared.NewPusher(cfg.PushEndpoint)
+	forwarder := NewForwarder(cfg, func(endpoint string) *shared.Pusher {
+		pusher := shared.NewPusher(endpoint)
+		pusher.Token = cfg.PushToken
+		if reloader != nil {
+			pusher.UseTLS(reloader, cfg.PushTLS.ServerName)
+		}
+		return pusher
+	})
+	// Sem push_endpoint o agente só atende o metrics_listen: coleta no mesmo
+	// intervalo, mas não envia lotes, heartbeat nem eventos de segurança
+	pullOnly := len(forwarder.Endpoints) == 0
+	if pullOnly {
+		log.Printf("No push_endpoint configured, collecting every %s for scraping only", cfg.PushInterval)
+	} else {
+		log.Printf("Pushing metrics to: %s every %s", forwarder, cfg.PushInterval)
+		securityEvents = forwarder.RecordSecurityEvent
+	}
+
+	if cfg.StateDir != "" {
+		if err := os.MkdirAll(cfg.StateDir, 0o700); err != nil {
+			log.Fatalf("Failed to create state_dir: %v", err)
+		}
+		store, err := probes.NewFileHostKeyStore(filepath.Join(cfg.StateDir, "ssh_host_keys.json"))
+		if err != nil {
+			log.Fatalf("Failed to load SSH host keys: %v", err)
+		}
+		sshHostKeys = store
+	}
 
+	pipeline := newLabelPipeline(cfg)
 	probeList := createProbes(cfg)
-	log.Printf("Initialized %d probes", len(probeList))
+	clock := newClockProbe(cfg)
+	if clock != nil {
+		log.Printf("  clock offset check: %s", clock.server)
+		probeList = append(probeList, newScheduledProbe(clock, "agent", cfg.AgentID, nil, clock.timeout, cfg.ProbeTimeout))
+	}
+	log.Printf("Initialized %d probes (concurrency %d)", len(probeList), cfg.ProbeConcurrency)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -45,19 +94,48 @@ func main() {
 	ticker := time.NewTicker(cfg.PushInterval)
 	defer ticker.Stop()
 
+	var exporter *promExporter
+	if cfg.MetricsListen != "" {
+		exporter = newPromExporter(func() []shared.Metric { return pipeline.Process(forwarder.SelfMetrics()) })
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+		go func() {
+			log.Printf("Serving Prometheus metrics on %s/metrics", cfg.MetricsListen)
+			if err := http.ListenAndServe(cfg.MetricsListen, mux); err != nil {
+				log.Fatalf("Metrics listener failed: %v", err)
+			}
+		}()
+	}
+
+	// O envio roda fora do laço de coleta: um endpoint lento atrasa só a
+	// entrega, e os lotes esperam na fila de cada endpoint
+	push := make(chan struct{}, 1)
+	if !pullOnly {
+		go runPusher(cfg, forwarder, clock, push)
+		push <- struct{}{}
+	}
+
 	log.Println("Agent started, collecting metrics...")
 
 	for {
 		select {
 		case <-ticker.C:
-			metrics := collectAllMetrics(ctx, probeList)
-
-			if len(metrics) > 0 {
-				if err := pusher.Push(cfg.AgentID, metrics); err != nil {
-					log.Printf("Failed to push metrics: %v", err)
-				} else {
-					log.Printf("Pushed %d metrics successfully", len(metrics))
-				}
+			metrics := pipeline.Process(collectAllMetrics(ctx, probeList, cfg.ProbeConcurrency))
+			if exporter != nil {
+				exporter.Update(metrics)
+			}
+
+			if pullOnly {
+				continue
+			}
+
+			// As métricas do próprio agente vão em todo ciclo, mesmo sem
+			// métricas dos probes, e passam pelos mesmos relabel_configs
+			forwarder.Enqueue(append(metrics, pipeline.Process(forwarder.SelfMetrics())...))
+			select {
+			case push <- struct{}{}:
+			default:
+				// Já há um envio pendente, que leva este lote junto
 			}
 
 		case <-sigChan:
@@ -67,62 +145,38 @@ func main() {
 	}
 }
 
-func createProbes(cfg *Config) []Probe {
-	var probeList []Probe
-
-	for _, target := range cfg.Targets.HTTP {
-		p := probes.NewHTTPProbe(target.Name, target.URL, target.Method, target.Timeout)
-		probeList = append(probeList, p)
-		log.Printf("  HTTP probe: %s -> %s", target.Name, target.URL)
-	}
+// runPusher entrega os lotes enfileirados e depois o heartbeat, ou o
+// registro até a API aceitá-lo, a cada sinal em push
+func runPusher(cfg *Config, forwarder *Forwarder, clock *clockProbe, push <-chan struct{}) {
+	inventory := agentInventory(cfg, time.Now())
+	registered := false
 
-	for _, target := range cfg.Targets.DNS {
-		p := probes.NewDNSProbe(target.Name, target.FQDN, target.Server)
-		probeList = append(probeList, p)
-		log.Printf("  DNS probe: %s -> %s @ %s", target.Name, target.FQDN, target.Server)
-	}
+	for range push {
+		forwarder.Flush()
 
-	for _, target := range cfg.Targets.SMTP {
-		p := probes.NewSMTPProbe(target.Name, target.Host, target.Port, target.StartTLS, target.Timeout)
-		probeList = append(probeList, p)
-		log.Printf("  SMTP probe: %s -> %s:%d", target.Name, target.Host, target.Port)
+		// Heartbeat mesmo sem métricas: é o que mantém agent_up=1 na API
+		hb := shared.AgentHeartbeat{AgentID: cfg.AgentID, TS: time.Now()}
+		if clock != nil {
+			hb.ClockOffsetMS = clock.Offset()
+		}
+		if !registered {
+			inventory.TS, inventory.ClockOffsetMS = hb.TS, hb.ClockOffsetMS
+			registered = forwarder.Register(inventory)
+		} else {
+			forwarder.Heartbeat(hb)
+		}
 	}
+}
 
-	for _, target := range cfg.Targets.ICMP {
-		p := probes.NewICMPProbe(target.Name, target.Host, target.Timeout)
-		probeList = append(probeList, p)
-		log.Printf("  ICMP probe: %s -> %s", target.Name, target.Host)
-	}
+func createProbes(cfg *Config) []*scheduledProbe {
+	var probeList []*scheduledProbe
 
-	for _, target := range cfg.Targets.Postgres {
-		p := probes.NewPostgresProbe(target.Name, target.DSN, target.SlowMS, target.PingSQL)
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+		p := newScheduledProbe(target.Probe(), target.Service(), target.Name, target.Labels, target.Timeout(), cfg.ProbeTimeout)
 		probeList = append(probeList, p)
-		log.Printf("  Postgres probe: %s", target.Name)
+		log.Printf("  %s probe: %s", target.Type, target.Describe())
 	}
 
 	return probeList
 }
-
-func collectAllMetrics(ctx context.Context, probeList []Probe) []shared.Metric {
-	var wg sync.WaitGroup
-	metricsChan := make(chan []shared.Metric, len(probeList))
-
-	for _, probe := range probeList {
-		wg.Add(1)
-		go func(p Probe) {
-			defer wg.Done()
-			metrics := p.Collect(ctx)
-			metricsChan <- metrics
-		}(probe)
-	}
-
-	wg.Wait()
-	close(metricsChan)
-
-	var allMetrics []shared.Metric
-	for metrics := range metricsChan {
-		allMetrics = append(allMetrics, metrics...)
-	}
-
-	return allMetrics
-}
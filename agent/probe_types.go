@@ -0,0 +1,511 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"argos/agent/probes"
+)
+
+type HTTPTarget struct {
+	Name             string        `yaml:"name"`
+	URL              string        `yaml:"url"`
+	Method           string        `yaml:"method"`
+	Timeout          time.Duration `yaml:"timeout"`
+	Body             string        `yaml:"body"`
+	HTTPClientConfig `yaml:",inline"`
+
+	options probes.HTTPOptions
+}
+
+type DNSTarget struct {
+	Name    string        `yaml:"name"`
+	FQDN    string        `yaml:"fqdn"`
+	Server  string        `yaml:"server"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type SMTPTarget struct {
+	Name     string        `yaml:"name"`
+	Host     string        `yaml:"host"`
+	Port     int           `yaml:"port"`
+	StartTLS bool          `yaml:"starttls"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// GRPCTarget usa TLS quando o bloco `tls` está presente (`tls: {}` para
+// validar com o trust store do sistema); caso contrário, texto puro.
+type GRPCTarget struct {
+	Name     string            `yaml:"name"`
+	Address  string            `yaml:"address"`
+	Service  string            `yaml:"service"`
+	TLS      *ProbeTLSConfig   `yaml:"tls"`
+	Metadata map[string]string `yaml:"metadata"`
+	Timeout  time.Duration     `yaml:"timeout"`
+
+	tlsConfig *tls.Config
+}
+
+// SSHTarget fixa as chaves aceitas em fingerprints ("SHA256:..."); sem elas,
+// a primeira chave vista vira a referência
+type SSHTarget struct {
+	Name              string        `yaml:"name"`
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port"`
+	Timeout           time.Duration `yaml:"timeout"`
+	Fingerprints      []string      `yaml:"fingerprints"`
+	HostKeyAlgorithms []string      `yaml:"host_key_algorithms"`
+}
+
+// NTPTarget aceita um servidor ou uma lista em `servers` (host ou host:port)
+type NTPTarget struct {
+	Name    string        `yaml:"name"`
+	Servers EndpointList  `yaml:"servers"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type ICMPTarget struct {
+	Name    string        `yaml:"name"`
+	Host    string        `yaml:"host"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type PostgresTarget struct {
+	Name    string `yaml:"name"`
+	DSN     string `yaml:"dsn"`
+	SlowMS  int    `yaml:"slow_ms"`
+	PingSQL string `yaml:"ping_sql"`
+}
+
+type ExecTarget struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Env     map[string]string `yaml:"env"`
+	Dir     string            `yaml:"dir"`
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// HTTPJSONTarget extrai métricas de um endpoint que responde JSON
+type HTTPJSONTarget struct {
+	Name             string             `yaml:"name"`
+	URL              string             `yaml:"url"`
+	Method           string             `yaml:"method"`
+	Timeout          time.Duration      `yaml:"timeout"`
+	Body             string             `yaml:"body"`
+	Metrics          []JSONMetricConfig `yaml:"metrics"`
+	HTTPClientConfig `yaml:",inline"`
+
+	options probes.HTTPOptions
+	metrics []probes.JSONMetric
+}
+
+// WebSocketTarget usa headers, autenticação, tls e ip_version de
+// HTTPClientConfig no handshake; proxy e redirecionamentos não se aplicam.
+type WebSocketTarget struct {
+	Name             string        `yaml:"name"`
+	URL              string        `yaml:"url"`
+	Timeout          time.Duration `yaml:"timeout"`
+	Subprotocols     []string      `yaml:"subprotocols"`
+	Send             string        `yaml:"send"`
+	Expect           string        `yaml:"expect"`
+	HTTPClientConfig `yaml:",inline"`
+
+	options probes.HTTPOptions
+	expect  *regexp.Regexp
+}
+
+// SyntheticTarget é uma transação de passos HTTP em ordem; as opções de
+// cliente (auth, TLS, proxy...) valem para todos os passos.
+type SyntheticTarget struct {
+	Name             string            `yaml:"name"`
+	Timeout          time.Duration     `yaml:"timeout"`
+	StepTimeout      time.Duration     `yaml:"step_timeout"`
+	Variables        map[string]string `yaml:"variables"`
+	Steps            []SyntheticStep   `yaml:"steps"`
+	HTTPClientConfig `yaml:",inline"`
+
+	options probes.HTTPOptions
+	steps   []probes.SyntheticStep
+}
+
+func init() {
+	RegisterProbe("http", ProbeSpec[HTTPTarget]{
+		Service: "web",
+		Defaults: func(t *HTTPTarget) {
+			if t.Method == "" {
+				t.Method = "GET"
+			}
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *HTTPTarget) error {
+			if err := validateURL("url", t.URL, "http", "https"); err != nil {
+				return err
+			}
+			opts, err := t.HTTPClientConfig.options()
+			if err != nil {
+				return err
+			}
+			opts.Body = t.Body
+			t.options = opts
+			return nil
+		},
+		Build: func(t *HTTPTarget) Probe {
+			return probes.NewHTTPProbeWithOptions(t.Name, t.URL, t.Method, t.Timeout, t.options)
+		},
+		Describe: func(t *HTTPTarget) string { return t.URL },
+		Timeout:  func(t *HTTPTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("http_json", ProbeSpec[HTTPJSONTarget]{
+		Service: "json",
+		Defaults: func(t *HTTPJSONTarget) {
+			if t.Method == "" {
+				t.Method = "GET"
+			}
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *HTTPJSONTarget) error {
+			if err := validateURL("url", t.URL, "http", "https"); err != nil {
+				return err
+			}
+			metrics, err := compileJSONMetrics(t.Metrics)
+			if err != nil {
+				return err
+			}
+			opts, err := t.HTTPClientConfig.options()
+			if err != nil {
+				return err
+			}
+			opts.Body = t.Body
+			t.options = opts
+			t.metrics = metrics
+			return nil
+		},
+		Build: func(t *HTTPJSONTarget) Probe {
+			return probes.NewHTTPJSONProbe(t.Name, t.URL, t.Method, t.Timeout, t.metrics, t.options)
+		},
+		Describe: func(t *HTTPJSONTarget) string { return t.URL },
+		Timeout:  func(t *HTTPJSONTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("synthetic", ProbeSpec[SyntheticTarget]{
+		Service: "synthetic",
+		Defaults: func(t *SyntheticTarget) {
+			if t.StepTimeout == 0 {
+				t.StepTimeout = 10 * time.Second
+			}
+			if t.Timeout == 0 {
+				t.Timeout = 30 * time.Second
+			}
+			for i := range t.Steps {
+				if t.Steps[i].Method == "" {
+					t.Steps[i].Method = "GET"
+				}
+			}
+		},
+		Validate: func(t *SyntheticTarget) error {
+			steps, err := compileSyntheticSteps(t.Steps, t.Variables)
+			if err != nil {
+				return err
+			}
+			opts, err := t.HTTPClientConfig.options()
+			if err != nil {
+				return err
+			}
+			t.steps = steps
+			t.options = opts
+			return nil
+		},
+		Build: func(t *SyntheticTarget) Probe {
+			return probes.NewSyntheticProbe(t.Name, t.steps, t.Variables, t.Timeout, t.StepTimeout, t.options)
+		},
+		Describe: func(t *SyntheticTarget) string { return fmt.Sprintf("%d steps", len(t.Steps)) },
+		// timeout limita a transação inteira, e não só um passo
+		Timeout: func(t *SyntheticTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("websocket", ProbeSpec[WebSocketTarget]{
+		Service: "websocket",
+		Defaults: func(t *WebSocketTarget) {
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *WebSocketTarget) error {
+			if err := validateURL("url", t.URL, "ws", "wss"); err != nil {
+				return err
+			}
+			if t.ProxyURL != "" {
+				return errors.New("proxy_url is not supported by the websocket probe")
+			}
+			if t.Expect != "" {
+				if t.Send == "" {
+					return errors.New("expect requires send")
+				}
+				re, err := regexp.Compile(t.Expect)
+				if err != nil {
+					return fmt.Errorf("expect %q: %v", t.Expect, err)
+				}
+				t.expect = re
+			}
+			opts, err := t.HTTPClientConfig.options()
+			if err != nil {
+				return err
+			}
+			t.options = opts
+			return nil
+		},
+		Build: func(t *WebSocketTarget) Probe {
+			return probes.NewWebSocketProbe(t.Name, t.URL, t.Timeout, t.Subprotocols, t.Send, t.expect, t.options)
+		},
+		Describe: func(t *WebSocketTarget) string { return t.URL },
+		Timeout:  func(t *WebSocketTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("dns", ProbeSpec[DNSTarget]{
+		Service: "dns",
+		Defaults: func(t *DNSTarget) {
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *DNSTarget) error {
+			if t.FQDN == "" {
+				return errors.New("fqdn is required")
+			}
+			return validateHostPort("server", t.Server)
+		},
+		Build: func(t *DNSTarget) Probe {
+			return probes.NewDNSProbe(t.Name, t.FQDN, t.Server, t.Timeout)
+		},
+		Describe: func(t *DNSTarget) string { return t.FQDN + " @ " + t.Server },
+		Timeout:  func(t *DNSTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("smtp", ProbeSpec[SMTPTarget]{
+		Service: "smtp",
+		Defaults: func(t *SMTPTarget) {
+			if t.Port == 0 {
+				t.Port = 25
+			}
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *SMTPTarget) error {
+			if t.Host == "" {
+				return errors.New("host is required")
+			}
+			return validatePort("port", t.Port)
+		},
+		Build: func(t *SMTPTarget) Probe {
+			return probes.NewSMTPProbe(t.Name, t.Host, t.Port, t.StartTLS, t.Timeout)
+		},
+		Describe: func(t *SMTPTarget) string { return fmt.Sprintf("%s:%d", t.Host, t.Port) },
+		Timeout:  func(t *SMTPTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("grpc", ProbeSpec[GRPCTarget]{
+		Service: "grpc",
+		Defaults: func(t *GRPCTarget) {
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *GRPCTarget) error {
+			if err := validateHostPort("address", t.Address); err != nil {
+				return err
+			}
+			if t.TLS != nil {
+				cfg, err := t.TLS.clientConfig()
+				if err != nil {
+					return err
+				}
+				t.tlsConfig = cfg
+			}
+			return nil
+		},
+		Build: func(t *GRPCTarget) Probe {
+			return probes.NewGRPCProbe(t.Name, t.Address, t.Service, t.tlsConfig, t.Metadata, t.Timeout)
+		},
+		Describe: func(t *GRPCTarget) string {
+			if t.Service == "" {
+				return t.Address
+			}
+			return t.Address + " " + t.Service
+		},
+		Timeout: func(t *GRPCTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("ssh", ProbeSpec[SSHTarget]{
+		Service: "ssh",
+		Defaults: func(t *SSHTarget) {
+			if t.Port == 0 {
+				t.Port = 22
+			}
+			if t.Timeout == 0 {
+				t.Timeout = 5 * time.Second
+			}
+		},
+		Validate: func(t *SSHTarget) error {
+			if t.Host == "" {
+				return errors.New("host is required")
+			}
+			for _, fp := range t.Fingerprints {
+				if !strings.HasPrefix(fp, "SHA256:") {
+					return fmt.Errorf("fingerprint %q: expected SHA256:<base64> (ssh-keygen -lf)", fp)
+				}
+			}
+			return validatePort("port", t.Port)
+		},
+		Build: func(t *SSHTarget) Probe {
+			p := probes.NewSSHProbe(t.Name, t.Host, t.Port, t.Timeout, t.Fingerprints, t.HostKeyAlgorithms, reportHostKeyChange)
+			p.Store = sshHostKeys
+			return p
+		},
+		Describe: func(t *SSHTarget) string { return fmt.Sprintf("%s:%d", t.Host, t.Port) },
+		Timeout:  func(t *SSHTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("ntp", ProbeSpec[NTPTarget]{
+		Service: "ntp",
+		Defaults: func(t *NTPTarget) {
+			if t.Timeout == 0 {
+				t.Timeout = 2 * time.Second
+			}
+		},
+		Validate: func(t *NTPTarget) error {
+			if len(t.Servers) == 0 {
+				return errors.New("servers is required")
+			}
+			for i, server := range t.Servers {
+				if server == "" {
+					return fmt.Errorf("servers[%d]: empty server", i)
+				}
+			}
+			return nil
+		},
+		Build: func(t *NTPTarget) Probe {
+			return probes.NewNTPProbe(t.Name, t.Servers, t.Timeout)
+		},
+		Describe: func(t *NTPTarget) string { return strings.Join(t.Servers, ", ") },
+		// Os servidores são consultados em paralelo, cada um até timeout
+		Timeout: func(t *NTPTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("icmp", ProbeSpec[ICMPTarget]{
+		Service: "network",
+		Defaults: func(t *ICMPTarget) {
+			if t.Timeout == 0 {
+				t.Timeout = 2 * time.Second
+			}
+		},
+		Validate: func(t *ICMPTarget) error {
+			if t.Host == "" {
+				return errors.New("host is required")
+			}
+			return nil
+		},
+		Build: func(t *ICMPTarget) Probe {
+			return probes.NewICMPProbe(t.Name, t.Host, t.Timeout)
+		},
+		Describe: func(t *ICMPTarget) string { return t.Host },
+		Timeout:  func(t *ICMPTarget) time.Duration { return t.Timeout },
+	})
+
+	RegisterProbe("postgres", ProbeSpec[PostgresTarget]{
+		Service: "db",
+		Defaults: func(t *PostgresTarget) {
+			if t.PingSQL == "" {
+				t.PingSQL = "SELECT 1"
+			}
+			if t.SlowMS == 0 {
+				t.SlowMS = 100
+			}
+		},
+		Validate: func(t *PostgresTarget) error {
+			if t.DSN == "" {
+				return errors.New("dsn is required")
+			}
+			return nil
+		},
+		Build: func(t *PostgresTarget) Probe {
+			return probes.NewPostgresProbe(t.Name, t.DSN, t.SlowMS, t.PingSQL)
+		},
+	})
+
+	RegisterProbe("exec", ProbeSpec[ExecTarget]{
+		Service: "exec",
+		Defaults: func(t *ExecTarget) {
+			if t.Timeout == 0 {
+				t.Timeout = 10 * time.Second
+			}
+		},
+		Validate: func(t *ExecTarget) error {
+			if t.Command == "" {
+				return errors.New("command is required")
+			}
+			return nil
+		},
+		Build: func(t *ExecTarget) Probe {
+			return probes.NewExecProbe(t.Name, t.Command, t.Args, t.Env, t.Dir, t.Timeout)
+		},
+		Describe: func(t *ExecTarget) string { return t.Command },
+		Timeout:  func(t *ExecTarget) time.Duration { return t.Timeout },
+	})
+}
+
+func validateURL(field, raw string, schemes ...string) error {
+	if raw == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %v", field, err)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%s %q: missing host", field, raw)
+	}
+
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q: scheme must be one of %v", field, raw, schemes)
+}
+
+func validateHostPort(field, addr string) error {
+	if addr == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%s %q: %v", field, addr, err)
+	}
+	if host == "" || port == "" {
+		return fmt.Errorf("%s %q: expected host:port", field, addr)
+	}
+	return nil
+}
+
+func validatePort(field string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s %d: out of range", field, port)
+	}
+	return nil
+}
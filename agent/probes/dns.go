@@ -9,26 +9,30 @@ import (
 )
 
 type DNSProbe struct {
-	Name   string
-	FQDN   string
-	Server string
+	Name    string
+	FQDN    string
+	Server  string
+	Timeout time.Duration
 }
 
-func NewDNSProbe(name, fqdn, server string) *DNSProbe {
+func NewDNSProbe(name, fqdn, server string, timeout time.Duration) *DNSProbe {
 	return &DNSProbe{
-		Name:   name,
-		FQDN:   fqdn,
-		Server: server,
+		Name:    name,
+		FQDN:    fqdn,
+		Server:  server,
+		Timeout: timeout,
 	}
 }
 
 func (p *DNSProbe) Collect(ctx context.Context) []shared.Metric {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
 	start := time.Now()
 
 	resolver := &net.Resolver{
 		PreferGo: true,
 		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			dialer := net.Dialer{Timeout: 5 * time.Second}
+			var dialer net.Dialer
 			return dialer.DialContext(ctx, "udp", p.Server)
 		},
 	}
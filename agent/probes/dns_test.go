@@ -3,10 +3,11 @@ package probes
 import (
 	"context"
 	"testing"
+	"time"
 )
 
 func TestDNSProbeSuccess(t *testing.T) {
-	probe := NewDNSProbe("test-dns", "google.com", "8.8.8.8:53")
+	probe := NewDNSProbe("test-dns", "google.com", "8.8.8.8:53", 5*time.Second)
 	metrics := probe.Collect(context.Background())
 
 	if len(metrics) != 2 {
@@ -38,7 +39,7 @@ func TestDNSProbeSuccess(t *testing.T) {
 }
 
 func TestDNSProbeInvalidFQDN(t *testing.T) {
-	probe := NewDNSProbe("test-dns", "this-domain-absolutely-does-not-exist-12345.com", "8.8.8.8:53")
+	probe := NewDNSProbe("test-dns", "this-domain-absolutely-does-not-exist-12345.com", "8.8.8.8:53", 5*time.Second)
 	metrics := probe.Collect(context.Background())
 
 	var foundUp bool
@@ -57,7 +58,7 @@ func TestDNSProbeInvalidFQDN(t *testing.T) {
 }
 
 func TestDNSProbeInvalidServer(t *testing.T) {
-	probe := NewDNSProbe("test-dns", "google.com", "192.0.2.1:53")
+	probe := NewDNSProbe("test-dns", "google.com", "192.0.2.1:53", 5*time.Second)
 	metrics := probe.Collect(context.Background())
 
 	var foundUp bool
@@ -75,7 +76,7 @@ func TestDNSProbeInvalidServer(t *testing.T) {
 func TestDNSProbeLabels(t *testing.T) {
 	fqdn := "example.com"
 	server := "1.1.1.1:53"
-	probe := NewDNSProbe("test-dns", fqdn, server)
+	probe := NewDNSProbe("test-dns", fqdn, server, 5*time.Second)
 	metrics := probe.Collect(context.Background())
 
 	for _, m := range metrics {
@@ -0,0 +1,335 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"argos/shared"
+)
+
+// Códigos de saída do padrão de plugins Nagios
+const (
+	ExecOK       = 0
+	ExecWarning  = 1
+	ExecCritical = 2
+	ExecUnknown  = 3
+)
+
+// execOutputMaxLen limita o label output de exec_status. O texto livre do
+// plugin ainda cria uma série nova sempre que muda (contadores, horários),
+// então plugins com saída variável devem ter a primeira linha estável.
+const execOutputMaxLen = 100
+
+var execStateNames = map[int]string{
+	ExecOK:       "OK",
+	ExecWarning:  "WARNING",
+	ExecCritical: "CRITICAL",
+	ExecUnknown:  "UNKNOWN",
+}
+
+type ExecProbe struct {
+	Name    string
+	Command string
+	Args    []string
+	Env     map[string]string
+	Dir     string
+	Timeout time.Duration
+
+	// A primeira linha da saída também vai para o log quando o estado muda
+	mu         sync.Mutex
+	lastOutput string
+	lastStatus int
+}
+
+type PerfData struct {
+	Label string
+	Value float64
+	UOM   string
+	Warn  string
+	Crit  string
+	Min   string
+	Max   string
+}
+
+func NewExecProbe(name, command string, args []string, env map[string]string, dir string, timeout time.Duration) *ExecProbe {
+	return &ExecProbe{
+		Name:    name,
+		Command: command,
+		Args:    args,
+		Env:     env,
+		Dir:     dir,
+		Timeout: timeout,
+	}
+}
+
+func (p *ExecProbe) Collect(ctx context.Context) []shared.Metric {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Dir = p.Dir
+	cmd.WaitDelay = time.Second
+	if len(p.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range p.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stdout
+
+	start := time.Now()
+	err := cmd.Run()
+	latency := time.Since(start).Seconds() * 1000
+	ts := time.Now()
+
+	status := ExecOK
+	summary, perf := ParsePluginOutput(stdout.String())
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		status = ExecUnknown
+		summary = "timeout after " + p.Timeout.String()
+		perf = nil
+	case errors.As(err, &exitErr):
+		status = exitErr.ExitCode()
+		if status < ExecOK || status > ExecUnknown {
+			status = ExecUnknown
+		}
+	case err != nil:
+		status = ExecUnknown
+		summary = err.Error()
+		perf = nil
+	}
+
+	p.recordOutput(status, summary)
+
+	labels := map[string]string{
+		"command": p.Command,
+		"state":   execStateNames[status],
+	}
+
+	// Só exec_status leva a saída, truncada, para limitar as séries novas
+	statusLabels := map[string]string{
+		"command": p.Command,
+		"state":   execStateNames[status],
+		"output":  truncateOutput(summary, execOutputMaxLen),
+	}
+
+	metrics := []shared.Metric{
+		{Service: "exec", Target: p.Name, Name: "exec_status", Value: float64(status), Labels: statusLabels, TS: ts},
+		{Service: "exec", Target: p.Name, Name: "exec_duration_ms", Value: latency, Labels: labels, TS: ts},
+	}
+
+	// Os perfdata compartilham o nome exec_perf e se distinguem pelo label
+	// "label": derivar o nome do label juntaria "/" e "" ou "a-b" e "a_b"
+	for _, pd := range perf {
+		name := "exec_perf"
+		perfLabels := map[string]string{
+			"command": p.Command,
+			"label":   pd.Label,
+		}
+		if pd.UOM != "" {
+			perfLabels["uom"] = pd.UOM
+		}
+
+		metrics = append(metrics, shared.Metric{
+			Service: "exec", Target: p.Name, Name: name, Value: pd.Value, Labels: perfLabels, TS: ts,
+		})
+
+		thresholds := []struct{ suffix, raw string }{
+			{"warn", pd.Warn}, {"crit", pd.Crit}, {"min", pd.Min}, {"max", pd.Max},
+		}
+		for _, th := range thresholds {
+			v, ok := parseThreshold(th.raw)
+			if !ok {
+				continue
+			}
+			metrics = append(metrics, shared.Metric{
+				Service: "exec", Target: p.Name, Name: name + "_" + th.suffix, Value: v, Labels: perfLabels, TS: ts,
+			})
+		}
+	}
+
+	return metrics
+}
+
+// recordOutput guarda a saída e a registra no log quando o estado não é OK e
+// mudou desde a execução anterior
+func (p *ExecProbe) recordOutput(status int, summary string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if status != ExecOK && (status != p.lastStatus || summary != p.lastOutput) {
+		log.Printf("exec %s: %s: %s", p.Name, execStateNames[status], summary)
+	}
+	p.lastStatus = status
+	p.lastOutput = summary
+}
+
+// truncateOutput corta s em até max bytes sem partir um caractere UTF-8
+func truncateOutput(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// LastOutput retorna a primeira linha da última execução
+func (p *ExecProbe) LastOutput() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastOutput
+}
+
+// ParsePluginOutput separa a primeira linha de texto e os dados de performance
+// de uma saída no formato de plugin Nagios:
+//
+//	TEXTO | perfdata
+//	TEXTO LONGO
+//	TEXTO LONGO | perfdata
+func ParsePluginOutput(out string) (string, []PerfData) {
+	lines := strings.Split(strings.TrimRight(out, "\r\n"), "\n")
+
+	var summary string
+	var perfParts []string
+
+	for i, line := range lines {
+		text, perfPart, hasPerf := strings.Cut(line, "|")
+		if i == 0 {
+			summary = strings.TrimSpace(text)
+		}
+		if hasPerf {
+			perfParts = append(perfParts, perfPart)
+		}
+	}
+
+	var perf []PerfData
+	for _, part := range perfParts {
+		perf = append(perf, ParsePerfData(part)...)
+	}
+
+	return summary, perf
+}
+
+// ParsePerfData interpreta entradas 'label'=value[UOM];warn;crit;min;max
+// separadas por espaço. Entradas inválidas ou com valor "U" são ignoradas.
+func ParsePerfData(s string) []PerfData {
+	var result []PerfData
+
+	for _, token := range splitPerfTokens(s) {
+		eq := strings.LastIndex(token, "=")
+		if eq <= 0 {
+			continue
+		}
+
+		label := token[:eq]
+		if len(label) >= 2 && label[0] == '\'' && label[len(label)-1] == '\'' {
+			label = strings.ReplaceAll(label[1:len(label)-1], "''", "'")
+		}
+
+		fields := strings.Split(token[eq+1:], ";")
+		value, uom, ok := splitValueUOM(fields[0])
+		if !ok {
+			continue
+		}
+
+		pd := PerfData{Label: label, Value: value, UOM: uom}
+		for i, f := range fields[1:] {
+			switch i {
+			case 0:
+				pd.Warn = f
+			case 1:
+				pd.Crit = f
+			case 2:
+				pd.Min = f
+			case 3:
+				pd.Max = f
+			}
+		}
+
+		result = append(result, pd)
+	}
+
+	return result
+}
+
+func splitPerfTokens(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'':
+			if inQuote && i+1 < len(s) && s[i+1] == '\'' {
+				cur.WriteString("''")
+				i++
+				continue
+			}
+			inQuote = !inQuote
+			cur.WriteByte(c)
+		case (c == ' ' || c == '\t') && !inQuote:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+
+	return tokens
+}
+
+func splitValueUOM(s string) (float64, string, bool) {
+	end := 0
+	for end < len(s) && strings.ContainsRune("0123456789.-+eE", rune(s[end])) {
+		end++
+	}
+	// "e"/"E" também podem iniciar uma unidade; recua até obter um número válido
+	for end > 0 {
+		if v, err := strconv.ParseFloat(s[:end], 64); err == nil {
+			return v, s[end:], true
+		}
+		end--
+	}
+	return 0, "", false
+}
+
+// parseThreshold extrai o limite superior de um range Nagios ([@][start:]end)
+func parseThreshold(s string) (float64, bool) {
+	s = strings.TrimPrefix(s, "@")
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		s = s[i+1:]
+	}
+	if s == "" || s == "~" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,186 @@
+package probes
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExecProbeStatusMapping(t *testing.T) {
+	tests := []struct {
+		script string
+		status float64
+		state  string
+	}{
+		{"echo 'OK - all good'; exit 0", 0, "OK"},
+		{"echo 'WARNING - disk 85%'; exit 1", 1, "WARNING"},
+		{"echo 'CRITICAL - disk 95%'; exit 2", 2, "CRITICAL"},
+		{"echo 'UNKNOWN - no data'; exit 3", 3, "UNKNOWN"},
+		{"echo 'weird'; exit 42", 3, "UNKNOWN"},
+	}
+
+	for _, tt := range tests {
+		probe := NewExecProbe("test-exec", "sh", []string{"-c", tt.script}, nil, "", 5*time.Second)
+		metrics := probe.Collect(context.Background())
+
+		var found bool
+		for _, m := range metrics {
+			if m.Name == "exec_status" {
+				found = true
+				if m.Value != tt.status {
+					t.Errorf("%q: expected exec_status=%.0f, got %f", tt.script, tt.status, m.Value)
+				}
+				if m.Labels["state"] != tt.state {
+					t.Errorf("%q: expected state %s, got %s", tt.script, tt.state, m.Labels["state"])
+				}
+			}
+		}
+
+		if !found {
+			t.Errorf("%q: missing exec_status metric", tt.script)
+		}
+	}
+}
+
+func TestExecProbePerfData(t *testing.T) {
+	script := `echo "DISK OK - free space: / 3326 MB (56%) | /=2643MB;5948;5958;0;5968"; echo "second line | 'inode usage'=12%;80;90"`
+	probe := NewExecProbe("test-exec", "sh", []string{"-c", script}, nil, "", 5*time.Second)
+	metrics := probe.Collect(context.Background())
+	if out := probe.LastOutput(); out != "DISK OK - free space: / 3326 MB (56%)" {
+		t.Errorf("Unexpected output: %q", out)
+	}
+
+	values := map[string]float64{}
+	for _, m := range metrics {
+		if m.Name == "exec_status" {
+			if m.Labels["output"] != "DISK OK - free space: / 3326 MB (56%)" {
+				t.Errorf("Expected first output line as label, got %q", m.Labels["output"])
+			}
+			continue
+		}
+		if _, ok := m.Labels["output"]; ok {
+			t.Errorf("%s: only exec_status carries the output label", m.Name)
+		}
+		if m.Name == "exec_duration_ms" {
+			continue
+		}
+		values[m.Name+"{"+m.Labels["label"]+"}"] = m.Value
+		if m.Labels["label"] == "inode usage" && m.Labels["uom"] != "%" {
+			t.Errorf("Expected uom label %%, got %q", m.Labels["uom"])
+		}
+	}
+
+	expected := map[string]float64{
+		"exec_perf{/}":                2643,
+		"exec_perf_warn{/}":           5948,
+		"exec_perf_crit{/}":           5958,
+		"exec_perf_min{/}":            0,
+		"exec_perf_max{/}":            5968,
+		"exec_perf{inode usage}":      12,
+		"exec_perf_warn{inode usage}": 80,
+		"exec_perf_crit{inode usage}": 90,
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d perf series, got %v", len(expected), values)
+	}
+	for name, want := range expected {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("Missing metric %s", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("Expected %s=%f, got %f", name, want, got)
+		}
+	}
+}
+
+func TestExecProbeTruncatesOutputLabel(t *testing.T) {
+	script := `printf 'OK %0150d\n' 0`
+	probe := NewExecProbe("test-exec", "sh", []string{"-c", script}, nil, "", 5*time.Second)
+
+	for _, m := range probe.Collect(context.Background()) {
+		if m.Name == "exec_status" && len(m.Labels["output"]) != execOutputMaxLen {
+			t.Errorf("Expected output label truncated to %d bytes, got %d", execOutputMaxLen, len(m.Labels["output"]))
+		}
+	}
+	if len(probe.LastOutput()) <= execOutputMaxLen {
+		t.Errorf("Expected LastOutput to keep the full line, got %d bytes", len(probe.LastOutput()))
+	}
+
+	if got := truncateOutput("ação", 2); got != "a" {
+		t.Errorf("Expected cut before a multi-byte rune, got %q", got)
+	}
+}
+
+func TestExecProbePerfLabelsDoNotCollide(t *testing.T) {
+	script := `echo "OK | a-b=1 a_b=2 '/'=3 ''=4"`
+	probe := NewExecProbe("test-exec", "sh", []string{"-c", script}, nil, "", 5*time.Second)
+
+	seen := map[string]float64{}
+	for _, m := range probe.Collect(context.Background()) {
+		if m.Name == "exec_perf" {
+			seen[m.Labels["label"]] = m.Value
+		}
+	}
+	for label, want := range map[string]float64{"a-b": 1, "a_b": 2, "/": 3} {
+		if seen[label] != want {
+			t.Errorf("Expected %q=%v, got %v", label, want, seen)
+		}
+	}
+}
+
+func TestExecProbeTimeout(t *testing.T) {
+	probe := NewExecProbe("test-exec", "sleep", []string{"5"}, nil, "", 100*time.Millisecond)
+
+	start := time.Now()
+	metrics := probe.Collect(context.Background())
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Probe did not honour timeout, took %s", time.Since(start))
+	}
+
+	for _, m := range metrics {
+		if m.Name == "exec_status" && m.Value != 3 {
+			t.Errorf("Expected exec_status=3 on timeout, got %f", m.Value)
+		}
+	}
+}
+
+func TestExecProbeEnvAndDir(t *testing.T) {
+	dir := t.TempDir()
+	probe := NewExecProbe("test-exec", "sh", []string{"-c", `echo "$GREETING from $(pwd)"`},
+		map[string]string{"GREETING": "hello"}, dir, 5*time.Second)
+	probe.Collect(context.Background())
+
+	want := "hello from " + dir
+	if out := probe.LastOutput(); out != want {
+		t.Errorf("Expected output %q, got %q", want, out)
+	}
+}
+
+func TestExecProbeMissingCommand(t *testing.T) {
+	probe := NewExecProbe("test-exec", "/nonexistent/check_plugin", nil, nil, "", time.Second)
+	metrics := probe.Collect(context.Background())
+
+	if metrics[0].Name != "exec_status" || metrics[0].Value != 3 {
+		t.Errorf("Expected exec_status=3 for missing command, got %s=%f", metrics[0].Name, metrics[0].Value)
+	}
+}
+
+func TestParsePerfData(t *testing.T) {
+	perf := ParsePerfData(`time=0.06s;1;2;0 'it''s'=5 size=U;1;2 load1=0.5;;;0`)
+
+	if len(perf) != 3 {
+		t.Fatalf("Expected 3 perfdata entries, got %d: %+v", len(perf), perf)
+	}
+
+	if perf[0].Label != "time" || perf[0].Value != 0.06 || perf[0].UOM != "s" || perf[0].Warn != "1" || perf[0].Min != "0" {
+		t.Errorf("Unexpected entry: %+v", perf[0])
+	}
+	if perf[1].Label != "it's" || perf[1].Value != 5 {
+		t.Errorf("Unexpected quoted entry: %+v", perf[1])
+	}
+	if perf[2].Label != "load1" || perf[2].Warn != "" || perf[2].Min != "0" {
+		t.Errorf("Unexpected entry: %+v", perf[2])
+	}
+}
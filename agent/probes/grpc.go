@@ -0,0 +1,93 @@
+package probes
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"argos/shared"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCProbe chama grpc.health.v1.Health/Check. Service vazio consulta a
+// saúde geral do servidor. Sem TLSConfig a conexão é em texto puro.
+type GRPCProbe struct {
+	Name      string
+	Address   string
+	Service   string
+	TLSConfig *tls.Config
+	Metadata  map[string]string
+	Timeout   time.Duration
+}
+
+func NewGRPCProbe(name, address, service string, tlsConfig *tls.Config, md map[string]string, timeout time.Duration) *GRPCProbe {
+	return &GRPCProbe{
+		Name:      name,
+		Address:   address,
+		Service:   service,
+		TLSConfig: tlsConfig,
+		Metadata:  md,
+		Timeout:   timeout,
+	}
+}
+
+func (p *GRPCProbe) Collect(ctx context.Context) []shared.Metric {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	labels := map[string]string{
+		"address": p.Address,
+		"service": p.Service,
+	}
+
+	start := time.Now()
+	servingStatus, err := p.check(ctx)
+	latency := time.Since(start).Seconds() * 1000
+	ts := time.Now()
+
+	up := 0.0
+	if err == nil && servingStatus == healthpb.HealthCheckResponse_SERVING {
+		up = 1
+	}
+
+	metrics := []shared.Metric{
+		{Service: "grpc", Target: p.Name, Name: "grpc_up", Value: up, Labels: labels, TS: ts},
+		{Service: "grpc", Target: p.Name, Name: "grpc_latency_ms", Value: latency, Labels: labels, TS: ts},
+		// Código de status da RPC (0 = OK, 14 = UNAVAILABLE, 4 = DEADLINE_EXCEEDED...)
+		{Service: "grpc", Target: p.Name, Name: "grpc_status_code", Value: float64(status.Code(err)), Labels: labels, TS: ts},
+	}
+	if err == nil {
+		metrics = append(metrics, shared.Metric{
+			Service: "grpc", Target: p.Name, Name: "grpc_serving_status", Value: float64(servingStatus), Labels: labels, TS: ts,
+		})
+	}
+	return metrics
+}
+
+func (p *GRPCProbe) check(ctx context.Context) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	creds := insecure.NewCredentials()
+	if p.TLSConfig != nil {
+		creds = credentials.NewTLS(p.TLSConfig.Clone())
+	}
+
+	conn, err := grpc.NewClient(p.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+	defer conn.Close()
+
+	if len(p.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(p.Metadata))
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: p.Service})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+	return resp.GetStatus(), nil
+}
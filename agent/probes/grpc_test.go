@@ -0,0 +1,120 @@
+package probes
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startHealthServer sobe um servidor gRPC com o serviço de health padrão.
+// Com requireToken, chamadas sem o metadata "x-token: secret" são rejeitadas.
+func startHealthServer(t *testing.T, requireToken bool) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	var opts []grpc.ServerOption
+	if requireToken {
+		opts = append(opts, grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			if v := md.Get("x-token"); len(v) == 0 || v[0] != "secret" {
+				return nil, status.Error(codes.Unauthenticated, "missing token")
+			}
+			return handler(ctx, req)
+		}))
+	}
+
+	server := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(server, hs)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String(), hs
+}
+
+func grpcValues(p *GRPCProbe) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range p.Collect(context.Background()) {
+		values[m.Name] = m.Value
+	}
+	return values
+}
+
+func TestGRPCProbeServing(t *testing.T) {
+	addr, hs := startHealthServer(t, false)
+	hs.SetServingStatus("orders.v1.Orders", healthpb.HealthCheckResponse_SERVING)
+
+	values := grpcValues(NewGRPCProbe("orders", addr, "orders.v1.Orders", nil, nil, 5*time.Second))
+
+	if values["grpc_up"] != 1 {
+		t.Errorf("Expected grpc_up=1, got %v", values["grpc_up"])
+	}
+	if values["grpc_serving_status"] != float64(healthpb.HealthCheckResponse_SERVING) {
+		t.Errorf("Expected serving status 1, got %v", values["grpc_serving_status"])
+	}
+	if values["grpc_status_code"] != 0 {
+		t.Errorf("Expected RPC status OK, got %v", values["grpc_status_code"])
+	}
+}
+
+func TestGRPCProbeNotServing(t *testing.T) {
+	addr, hs := startHealthServer(t, false)
+	hs.SetServingStatus("orders.v1.Orders", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	values := grpcValues(NewGRPCProbe("orders", addr, "orders.v1.Orders", nil, nil, 5*time.Second))
+
+	if values["grpc_up"] != 0 {
+		t.Errorf("Expected grpc_up=0, got %v", values["grpc_up"])
+	}
+	if values["grpc_serving_status"] != float64(healthpb.HealthCheckResponse_NOT_SERVING) {
+		t.Errorf("Expected serving status 2, got %v", values["grpc_serving_status"])
+	}
+}
+
+func TestGRPCProbeUnknownService(t *testing.T) {
+	addr, _ := startHealthServer(t, false)
+
+	values := grpcValues(NewGRPCProbe("orders", addr, "missing.Service", nil, nil, 5*time.Second))
+
+	if values["grpc_up"] != 0 {
+		t.Errorf("Expected grpc_up=0, got %v", values["grpc_up"])
+	}
+	if values["grpc_status_code"] != float64(codes.NotFound) {
+		t.Errorf("Expected NOT_FOUND status code, got %v", values["grpc_status_code"])
+	}
+}
+
+func TestGRPCProbeMetadata(t *testing.T) {
+	addr, _ := startHealthServer(t, true)
+
+	without := grpcValues(NewGRPCProbe("orders", addr, "", nil, nil, 5*time.Second))
+	if without["grpc_status_code"] != float64(codes.Unauthenticated) {
+		t.Errorf("Expected UNAUTHENTICATED without metadata, got %v", without["grpc_status_code"])
+	}
+
+	with := grpcValues(NewGRPCProbe("orders", addr, "", nil, map[string]string{"x-token": "secret"}, 5*time.Second))
+	if with["grpc_up"] != 1 {
+		t.Errorf("Expected grpc_up=1 with metadata, got %v", with["grpc_up"])
+	}
+}
+
+func TestGRPCProbeUnreachable(t *testing.T) {
+	values := grpcValues(NewGRPCProbe("orders", "127.0.0.1:1", "", nil, nil, 500*time.Millisecond))
+
+	if values["grpc_up"] != 0 {
+		t.Errorf("Expected grpc_up=0, got %v", values["grpc_up"])
+	}
+	if _, ok := values["grpc_serving_status"]; ok {
+		t.Error("Expected no serving status when the RPC fails")
+	}
+}
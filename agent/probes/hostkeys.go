@@ -0,0 +1,76 @@
+package probes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// HostKeyStore guarda a última chave SSH vista em cada endereço, para que
+// o TOFU do SSHProbe sobreviva a reinícios do agente
+type HostKeyStore interface {
+	Load(addr string) string
+	Save(addr, fingerprint string) error
+}
+
+// FileHostKeyStore mantém as fingerprints num arquivo JSON, regravado
+// inteiro (arquivo temporário e rename) a cada mudança
+type FileHostKeyStore struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+// NewFileHostKeyStore lê path, se existir
+func NewFileHostKeyStore(path string) (*FileHostKeyStore, error) {
+	s := &FileHostKeyStore{path: path, keys: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileHostKeyStore) Load(addr string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[addr]
+}
+
+func (s *FileHostKeyStore) Save(addr, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys[addr] == fingerprint {
+		return nil
+	}
+	s.keys[addr] = fingerprint
+
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -2,34 +2,136 @@ package probes
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"argos/shared"
 )
 
+// HTTPOptions personaliza a requisição e o transporte usados pelos probes HTTP
+type HTTPOptions struct {
+	Headers map[string]string
+	Body    string
+
+	BasicAuthUser     string
+	BasicAuthPassword string
+	BearerToken       string
+
+	// Com FollowRedirects desligado a resposta 3xx é reportada como está
+	FollowRedirects bool
+	MaxRedirects    int
+
+	TLSConfig *tls.Config
+	ProxyURL  *url.URL
+	// IPVersion força a família de endereços: 4, 6 ou 0 (qualquer)
+	IPVersion int
+}
+
+// DefaultHTTPOptions reproduz o comportamento do http.Client padrão
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{FollowRedirects: true, MaxRedirects: 10}
+}
+
+// NewHTTPClient monta um http.Client com transporte próprio conforme as opções
+func NewHTTPClient(timeout time.Duration, opts HTTPOptions) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	network := "tcp"
+	if opts.IPVersion == 4 || opts.IPVersion == 6 {
+		network = fmt.Sprintf("tcp%d", opts.IPVersion)
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig:     opts.TLSConfig,
+		TLSHandshakeTimeout: timeout,
+		// Cada coleta mede uma conexão nova
+		DisableKeepAlives: true,
+	}
+	if opts.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(opts.ProxyURL)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !opts.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// NewRequest cria a requisição aplicando headers e autenticação
+func (o HTTPOptions) NewRequest(ctx context.Context, method, url, body string) (*http.Request, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range o.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	switch {
+	case o.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+o.BearerToken)
+	case o.BasicAuthUser != "":
+		req.SetBasicAuth(o.BasicAuthUser, o.BasicAuthPassword)
+	}
+	return req, nil
+}
+
 type HTTPProbe struct {
 	Name    string
 	URL     string
 	Method  string
 	Timeout time.Duration
+	Options HTTPOptions
 	client  *http.Client
 }
 
 func NewHTTPProbe(name, url, method string, timeout time.Duration) *HTTPProbe {
+	return NewHTTPProbeWithOptions(name, url, method, timeout, DefaultHTTPOptions())
+}
+
+func NewHTTPProbeWithOptions(name, url, method string, timeout time.Duration, opts HTTPOptions) *HTTPProbe {
 	return &HTTPProbe{
 		Name:    name,
 		URL:     url,
 		Method:  method,
 		Timeout: timeout,
-		client:  &http.Client{Timeout: timeout},
+		Options: opts,
+		client:  NewHTTPClient(timeout, opts),
 	}
 }
 
 func (p *HTTPProbe) Collect(ctx context.Context) []shared.Metric {
 	start := time.Now()
 
-	req, err := http.NewRequestWithContext(ctx, p.Method, p.URL, nil)
+	req, err := p.Options.NewRequest(ctx, p.Method, p.URL, p.Options.Body)
 	if err != nil {
 		return p.errorMetrics(start)
 	}
@@ -2,8 +2,12 @@ package probes
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -145,3 +149,109 @@ func TestHTTPProbeInvalidURL(t *testing.T) {
 		t.Error("Missing http_up metric")
 	}
 }
+
+func TestHTTPProbeRequestOptions(t *testing.T) {
+	var gotHeader, gotAuth, gotBody, gotHost string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Env")
+		gotAuth = r.Header.Get("Authorization")
+		gotHost = r.Host
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	opts := DefaultHTTPOptions()
+	opts.Headers = map[string]string{"X-Env": "prod", "Host": "api.internal"}
+	opts.Body = `{"ping":true}`
+	opts.BearerToken = "secret"
+
+	probe := NewHTTPProbeWithOptions("test-server", server.URL, "POST", 5*time.Second, opts)
+	probe.Collect(context.Background())
+
+	if gotHeader != "prod" {
+		t.Errorf("Expected X-Env header prod, got %q", gotHeader)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Expected bearer auth, got %q", gotAuth)
+	}
+	if gotBody != `{"ping":true}` {
+		t.Errorf("Expected request body, got %q", gotBody)
+	}
+	if gotHost != "api.internal" {
+		t.Errorf("Expected Host override, got %q", gotHost)
+	}
+}
+
+func TestHTTPProbeRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops, _ := strconv.Atoi(r.URL.Query().Get("hops"))
+		if hops > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/?hops=%d", hops-1), http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		follow bool
+		max    int
+		up     float64
+		status float64
+	}{
+		{"follow within limit", true, 3, 1, 200},
+		{"too many hops", true, 2, 0, 0},
+		{"redirects disabled", false, 0, 1, 302},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultHTTPOptions()
+			opts.FollowRedirects = tt.follow
+			opts.MaxRedirects = tt.max
+
+			probe := NewHTTPProbeWithOptions("test-server", server.URL+"/?hops=3", "GET", 5*time.Second, opts)
+			values := map[string]float64{}
+			for _, m := range probe.Collect(context.Background()) {
+				values[m.Name] = m.Value
+			}
+
+			if values["http_up"] != tt.up {
+				t.Errorf("Expected http_up=%v, got %v", tt.up, values["http_up"])
+			}
+			if values["http_status_code"] != tt.status {
+				t.Errorf("Expected status %v, got %v", tt.status, values["http_status_code"])
+			}
+		})
+	}
+}
+
+func TestHTTPProbeInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	up := func(opts HTTPOptions) float64 {
+		probe := NewHTTPProbeWithOptions("test-server", server.URL, "GET", 5*time.Second, opts)
+		for _, m := range probe.Collect(context.Background()) {
+			if m.Name == "http_up" {
+				return m.Value
+			}
+		}
+		return -1
+	}
+
+	if v := up(DefaultHTTPOptions()); v != 0 {
+		t.Errorf("Expected http_up=0 for self-signed certificate, got %v", v)
+	}
+
+	opts := DefaultHTTPOptions()
+	opts.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	if v := up(opts); v != 1 {
+		t.Errorf("Expected http_up=1 with insecure_skip_verify, got %v", v)
+	}
+}
@@ -0,0 +1,191 @@
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+// maxJSONBody limita o tamanho do documento lido da resposta
+const maxJSONBody = 4 << 20
+
+// JSONMetric extrai uma métrica do documento. Path aceita curingas; cada
+// valor encontrado vira uma série, rotulada por Labels na ordem dos curingas.
+type JSONMetric struct {
+	Name     string
+	Path     string
+	Labels   []JSONLabel
+	ValueMap map[string]float64
+}
+
+// JSONLabel nomeia um curinga do caminho. O valor do label é o índice (ou a
+// chave do objeto) ou, com Field, um campo do elemento percorrido.
+type JSONLabel struct {
+	Name  string
+	Field string
+}
+
+type HTTPJSONProbe struct {
+	Name    string
+	URL     string
+	Method  string
+	Timeout time.Duration
+	Metrics []JSONMetric
+	Options HTTPOptions
+	client  *http.Client
+}
+
+func NewHTTPJSONProbe(name, url, method string, timeout time.Duration, metrics []JSONMetric, opts HTTPOptions) *HTTPJSONProbe {
+	return &HTTPJSONProbe{
+		Name:    name,
+		URL:     url,
+		Method:  method,
+		Timeout: timeout,
+		Metrics: metrics,
+		Options: opts,
+		client:  NewHTTPClient(timeout, opts),
+	}
+}
+
+func (p *HTTPJSONProbe) Collect(ctx context.Context) []shared.Metric {
+	start := time.Now()
+	labels := map[string]string{"url": p.URL}
+
+	doc, status, err := p.fetch(ctx)
+	latency := time.Since(start).Seconds() * 1000
+	ts := time.Now()
+
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	metrics := []shared.Metric{
+		{Service: "json", Target: p.Name, Name: "http_json_up", Value: up, Labels: labels, TS: ts},
+		{Service: "json", Target: p.Name, Name: "http_json_latency_ms", Value: latency, Labels: labels, TS: ts},
+	}
+	if status > 0 {
+		metrics = append(metrics, shared.Metric{
+			Service: "json", Target: p.Name, Name: "http_json_status_code", Value: float64(status), Labels: labels, TS: ts,
+		})
+	}
+	if err != nil {
+		return metrics
+	}
+
+	// Expressões sem resultado numérico são contadas em vez de gerar série
+	missing := 0
+	for _, m := range p.Metrics {
+		series := m.extract(doc)
+		if len(series) == 0 {
+			missing++
+		}
+		for _, s := range series {
+			seriesLabels := make(map[string]string, len(labels)+len(s.labels))
+			for k, v := range labels {
+				seriesLabels[k] = v
+			}
+			for k, v := range s.labels {
+				seriesLabels[k] = v
+			}
+			metrics = append(metrics, shared.Metric{
+				Service: "json", Target: p.Name, Name: m.Name, Value: s.value, Labels: seriesLabels, TS: ts,
+			})
+		}
+	}
+
+	return append(metrics, shared.Metric{
+		Service: "json", Target: p.Name, Name: "http_json_missing", Value: float64(missing), Labels: labels, TS: ts,
+	})
+}
+
+func (p *HTTPJSONProbe) fetch(ctx context.Context) (any, int, error) {
+	req, err := p.Options.NewRequest(ctx, p.Method, p.URL, p.Options.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var doc any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJSONBody)).Decode(&doc); err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return doc, resp.StatusCode, nil
+}
+
+type jsonSeries struct {
+	value  float64
+	labels map[string]string
+}
+
+func (m JSONMetric) extract(doc any) []jsonSeries {
+	var series []jsonSeries
+	for _, match := range QueryJSON(doc, m.Path) {
+		value, ok := m.toNumber(match.Value)
+		if !ok {
+			continue
+		}
+
+		labels := make(map[string]string, len(match.Keys))
+		for i, key := range match.Keys {
+			label := JSONLabel{Name: defaultJSONLabel(i, len(match.Keys))}
+			if i < len(m.Labels) {
+				label = m.Labels[i]
+			}
+			labels[label.Name] = key
+			if label.Field != "" {
+				if v, found := LookupJSON(match.Elements[i], label.Field); found {
+					labels[label.Name] = JSONString(v)
+				}
+			}
+		}
+		series = append(series, jsonSeries{value: value, labels: labels})
+	}
+	return series
+}
+
+// toNumber converte o valor JSON: números como estão, booleanos como 1/0 e
+// strings pelo ValueMap ou, na falta dele, como número em texto
+func (m JSONMetric) toNumber(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if n, ok := m.ValueMap[val]; ok {
+			return n, true
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func defaultJSONLabel(i, total int) string {
+	if total == 1 {
+		return "index"
+	}
+	return "index" + strconv.Itoa(i+1)
+}
@@ -0,0 +1,88 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const healthJSON = `{
+  "status": "degraded",
+  "queue": {"depth": 42, "paused": false},
+  "version": "1.4.2",
+  "workers": [
+    {"id": "w-a", "busy": 3},
+    {"id": "w-b", "busy": "7"}
+  ],
+  "shards": {"eu": {"lag": 1.5}, "us": {"lag": 0.2}}
+}`
+
+func TestHTTPJSONProbeExtractsMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(healthJSON))
+	}))
+	defer server.Close()
+
+	metrics := []JSONMetric{
+		{Name: "queue_depth", Path: "queue.depth"},
+		{Name: "queue_paused", Path: "$.queue.paused"},
+		{Name: "service_status", Path: "status", ValueMap: map[string]float64{"ok": 1, "degraded": 0.5}},
+		{Name: "worker_busy", Path: "workers.#.busy", Labels: []JSONLabel{{Name: "worker", Field: "id"}}},
+		{Name: "shard_lag", Path: "$.shards[*].lag", Labels: []JSONLabel{{Name: "shard"}}},
+		{Name: "version", Path: "version"},
+	}
+	probe := NewHTTPJSONProbe("health", server.URL, "GET", 5*time.Second, metrics, DefaultHTTPOptions())
+
+	values := map[string]float64{}
+	for _, m := range probe.Collect(context.Background()) {
+		key := m.Name
+		for _, l := range []string{"worker", "shard"} {
+			if v := m.Labels[l]; v != "" {
+				key += "/" + v
+			}
+		}
+		values[key] = m.Value
+	}
+
+	expected := map[string]float64{
+		"http_json_up":      1,
+		"queue_depth":       42,
+		"queue_paused":      0,
+		"service_status":    0.5,
+		"worker_busy/w-a":   3,
+		"worker_busy/w-b":   7,
+		"shard_lag/eu":      1.5,
+		"shard_lag/us":      0.2,
+		"http_json_missing": 1,
+	}
+	for key, want := range expected {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("%s: expected %v, got %v (found=%v)", key, want, got, ok)
+		}
+	}
+	if _, ok := values["version"]; ok {
+		t.Error("Non-numeric string without value_map must not produce a series")
+	}
+}
+
+func TestHTTPJSONProbeInvalidBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>not json</html>"))
+	}))
+	defer server.Close()
+
+	probe := NewHTTPJSONProbe("health", server.URL, "GET", 5*time.Second,
+		[]JSONMetric{{Name: "queue_depth", Path: "queue.depth"}}, DefaultHTTPOptions())
+
+	for _, m := range probe.Collect(context.Background()) {
+		if m.Name == "http_json_up" && m.Value != 0 {
+			t.Errorf("Expected http_json_up=0 for invalid JSON, got %v", m.Value)
+		}
+		if m.Name == "queue_depth" {
+			t.Error("Expected no extracted metrics for invalid JSON")
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package probes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LookupJSON resolve um caminho no estilo gjson ("data.items.0.id") sobre um
+// documento decodificado com encoding/json. Também aceita a notação JSONPath
+// ("$.data.items[0].id"). Retorna false se o caminho não existir.
+func LookupJSON(doc any, path string) (any, bool) {
+	current := doc
+	for _, key := range splitJSONPath(normalizeJSONPath(path)) {
+		v, ok := jsonChild(current, key)
+		if !ok {
+			return nil, false
+		}
+		current = v
+	}
+	return current, true
+}
+
+func jsonChild(node any, key string) (any, bool) {
+	switch n := node.(type) {
+	case map[string]any:
+		v, ok := n[key]
+		return v, ok
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return nil, false
+		}
+		return n[i], true
+	}
+	return nil, false
+}
+
+// JSONMatch é um valor encontrado por QueryJSON. Keys contém, para cada
+// curinga do caminho, o índice do array ou a chave do objeto percorrido, e
+// Elements o elemento correspondente.
+type JSONMatch struct {
+	Value    any
+	Keys     []string
+	Elements []any
+}
+
+// QueryJSON é como LookupJSON, mas aceita curingas ("#" ou "*", ou "[*]" na
+// notação JSONPath) que percorrem todos os itens de um array ou objeto.
+// Chaves de objetos são visitadas em ordem alfabética.
+func QueryJSON(doc any, path string) []JSONMatch {
+	var matches []JSONMatch
+	var walk func(node any, keys []string, match JSONMatch)
+	walk = func(node any, keys []string, match JSONMatch) {
+		if len(keys) == 0 {
+			match.Value = node
+			matches = append(matches, match)
+			return
+		}
+
+		key, rest := keys[0], keys[1:]
+		if !IsJSONWildcard(key) {
+			if v, ok := jsonChild(node, key); ok {
+				walk(v, rest, match)
+			}
+			return
+		}
+
+		visit := func(k string, v any) {
+			next := JSONMatch{
+				Keys:     append(append([]string(nil), match.Keys...), k),
+				Elements: append(append([]any(nil), match.Elements...), v),
+			}
+			walk(v, rest, next)
+		}
+		switch n := node.(type) {
+		case []any:
+			for i, v := range n {
+				visit(strconv.Itoa(i), v)
+			}
+		case map[string]any:
+			names := make([]string, 0, len(n))
+			for name := range n {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				visit(name, n[name])
+			}
+		}
+	}
+
+	walk(doc, splitJSONPath(normalizeJSONPath(path)), JSONMatch{})
+	return matches
+}
+
+// JSONPathWildcards conta os curingas de um caminho
+func JSONPathWildcards(path string) int {
+	n := 0
+	for _, key := range splitJSONPath(normalizeJSONPath(path)) {
+		if IsJSONWildcard(key) {
+			n++
+		}
+	}
+	return n
+}
+
+func IsJSONWildcard(key string) bool {
+	return key == "#" || key == "*"
+}
+
+// normalizeJSONPath converte "$.a[0].b[*]" para "a.0.b.#" e remove o prefixo "$."
+func normalizeJSONPath(path string) string {
+	path = strings.TrimPrefix(path, "$")
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			b.WriteByte(path[i])
+			b.WriteByte(path[i+1])
+			i++
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				b.WriteString(path[i:])
+				i = len(path)
+				continue
+			}
+			index := strings.Trim(path[i+1:i+end], `'"`)
+			if index == "*" {
+				index = "#"
+			}
+			b.WriteByte('.')
+			b.WriteString(strings.ReplaceAll(index, ".", `\.`))
+			i += end
+		default:
+			b.WriteByte(path[i])
+		}
+	}
+	return strings.TrimPrefix(b.String(), ".")
+}
+
+// splitJSONPath separa o caminho em chaves; "\." escapa pontos dentro de uma chave
+func splitJSONPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	var keys []string
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			b.WriteByte('.')
+			i++
+		case path[i] == '.':
+			keys = append(keys, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(path[i])
+		}
+	}
+	return append(keys, b.String())
+}
+
+// JSONString converte um valor JSON para texto: strings sem aspas, números
+// sem notação científica desnecessária e objetos/arrays serializados.
+func JSONString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,172 @@
+package probes
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"argos/shared"
+)
+
+// Segundos entre a época NTP (1900) e a época Unix (1970)
+const ntpEpochOffset = 2208988800
+
+// NTPResult é o resultado de uma consulta SNTP
+type NTPResult struct {
+	// Offset é quanto o relógio local está atrasado (positivo) ou adiantado
+	// (negativo) em relação ao servidor
+	Offset  time.Duration
+	Delay   time.Duration
+	Stratum int
+}
+
+// QueryNTP faz uma consulta SNTPv4 (RFC 4330) ao servidor (host ou host:port)
+func QueryNTP(ctx context.Context, server string, timeout time.Duration) (NTPResult, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return NTPResult{}, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	req := make([]byte, 48)
+	req[0] = 0<<6 | 4<<3 | 3 // LI=0, VN=4, Mode=3 (cliente)
+	t1 := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTPTime(t1))
+
+	if _, err := conn.Write(req); err != nil {
+		return NTPResult{}, err
+	}
+
+	resp := make([]byte, 48)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return NTPResult{}, err
+		}
+		t4 := time.Now()
+		if n < 48 {
+			continue
+		}
+		// Ignora respostas que não sejam para esta requisição
+		if binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]) {
+			continue
+		}
+		return parseNTPResponse(resp, t1, t4)
+	}
+}
+
+func parseNTPResponse(resp []byte, t1, t4 time.Time) (NTPResult, error) {
+	mode := resp[0] & 0x7
+	if mode != 4 && mode != 5 {
+		return NTPResult{}, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	if resp[0]>>6 == 3 {
+		return NTPResult{}, errors.New("server clock not synchronized")
+	}
+
+	stratum := int(resp[1])
+	if stratum == 0 {
+		return NTPResult{}, fmt.Errorf("kiss-o'-death: %s", string(resp[12:16]))
+	}
+
+	t2 := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	t3 := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	if t3.IsZero() || t3.Before(t2) {
+		return NTPResult{}, errors.New("invalid server timestamps")
+	}
+
+	return NTPResult{
+		Offset:  (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:   t4.Sub(t1) - t3.Sub(t2),
+		Stratum: stratum,
+	}, nil
+}
+
+func toNTPTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	return secs<<32 | frac
+}
+
+func fromNTPTime(v uint64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	secs := int64(v>>32) - ntpEpochOffset
+	nanos := (v & 0xFFFFFFFF) * 1e9 >> 32
+	return time.Unix(secs, int64(nanos))
+}
+
+// NTPProbe consulta cada servidor e reporta offset, atraso e stratum
+type NTPProbe struct {
+	Name    string
+	Servers []string
+	Timeout time.Duration
+}
+
+func NewNTPProbe(name string, servers []string, timeout time.Duration) *NTPProbe {
+	return &NTPProbe{
+		Name:    name,
+		Servers: servers,
+		Timeout: timeout,
+	}
+}
+
+// Collect consulta os servidores em paralelo, para que o probe todo caiba em
+// Timeout (o deadline da coleta) mesmo com vários servidores lentos
+func (p *NTPProbe) Collect(ctx context.Context) []shared.Metric {
+	results := make([][]shared.Metric, len(p.Servers))
+	var wg sync.WaitGroup
+	for i, server := range p.Servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			results[i] = p.query(ctx, server)
+		}(i, server)
+	}
+	wg.Wait()
+
+	var metrics []shared.Metric
+	for _, r := range results {
+		metrics = append(metrics, r...)
+	}
+	return metrics
+}
+
+func (p *NTPProbe) query(ctx context.Context, server string) []shared.Metric {
+	result, err := QueryNTP(ctx, server, p.Timeout)
+	ts := time.Now()
+	labels := map[string]string{"server": server}
+
+	if err != nil {
+		return []shared.Metric{
+			{Service: "ntp", Target: p.Name, Name: "ntp_up", Value: 0, Labels: labels, TS: ts},
+		}
+	}
+
+	return []shared.Metric{
+		{Service: "ntp", Target: p.Name, Name: "ntp_up", Value: 1, Labels: labels, TS: ts},
+		{Service: "ntp", Target: p.Name, Name: "ntp_offset_ms", Value: durationMS(result.Offset), Labels: labels, TS: ts},
+		{Service: "ntp", Target: p.Name, Name: "ntp_delay_ms", Value: durationMS(result.Delay), Labels: labels, TS: ts},
+		{Service: "ntp", Target: p.Name, Name: "ntp_stratum", Value: float64(result.Stratum), Labels: labels, TS: ts},
+	}
+}
+
+func durationMS(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
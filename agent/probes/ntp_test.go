@@ -0,0 +1,119 @@
+package probes
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+// startNTPServer responde como um servidor stratum 2 cujo relógio está
+// skew à frente do local. Com stratum 0 simula um kiss-o'-death.
+func startNTPServer(t *testing.T, skew time.Duration, stratum byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0] = 0<<6 | 4<<3 | 4 // LI=0, VN=4, Mode=4 (servidor)
+			resp[1] = stratum
+			copy(resp[12:16], "RATE")
+			copy(resp[24:32], buf[40:48])
+			now := time.Now().Add(skew)
+			binary.BigEndian.PutUint64(resp[32:], toNTPTime(now))
+			binary.BigEndian.PutUint64(resp[40:], toNTPTime(now))
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestQueryNTPOffset(t *testing.T) {
+	server := startNTPServer(t, 2*time.Second, 2)
+
+	result, err := QueryNTP(context.Background(), server, time.Second)
+	if err != nil {
+		t.Fatalf("QueryNTP failed: %v", err)
+	}
+
+	if math.Abs(result.Offset.Seconds()-2) > 0.05 {
+		t.Errorf("Expected offset ~2s, got %s", result.Offset)
+	}
+	if result.Delay < 0 || result.Delay > 50*time.Millisecond {
+		t.Errorf("Unexpected delay %s", result.Delay)
+	}
+	if result.Stratum != 2 {
+		t.Errorf("Expected stratum 2, got %d", result.Stratum)
+	}
+}
+
+func TestNTPProbeMetrics(t *testing.T) {
+	good := startNTPServer(t, -500*time.Millisecond, 1)
+	kod := startNTPServer(t, 0, 0)
+
+	probe := NewNTPProbe("time", []string{good, kod}, time.Second)
+	values := map[string]float64{}
+	for _, m := range probe.Collect(context.Background()) {
+		values[m.Name+"/"+m.Labels["server"]] = m.Value
+	}
+
+	if values["ntp_up/"+good] != 1 {
+		t.Errorf("Expected ntp_up=1 for %s", good)
+	}
+	if offset := values["ntp_offset_ms/"+good]; math.Abs(offset+500) > 50 {
+		t.Errorf("Expected offset ~-500ms, got %v", offset)
+	}
+	if values["ntp_stratum/"+good] != 1 {
+		t.Errorf("Expected stratum 1, got %v", values["ntp_stratum/"+good])
+	}
+	if values["ntp_up/"+kod] != 0 {
+		t.Errorf("Expected ntp_up=0 for kiss-o'-death server")
+	}
+}
+
+func TestNTPProbeSlowServersInParallel(t *testing.T) {
+	// Servidores que nunca respondem: cada consulta gasta o timeout inteiro
+	var servers []string
+	for i := 0; i < 3; i++ {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		servers = append(servers, conn.LocalAddr().String())
+	}
+
+	timeout := 200 * time.Millisecond
+	probe := NewNTPProbe("time", servers, timeout)
+	start := time.Now()
+	metrics := probe.Collect(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*timeout {
+		t.Errorf("Expected servers to be queried in parallel, took %s", elapsed)
+	}
+	if len(metrics) != 3 || metrics[0].Labels["server"] != servers[0] || metrics[0].Value != 0 {
+		t.Errorf("Expected ntp_up=0 per server in config order, got %+v", metrics)
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	now := time.Now()
+	back := fromNTPTime(toNTPTime(now))
+	if d := back.Sub(now); d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("NTP timestamp round trip drifted by %s", d)
+	}
+}
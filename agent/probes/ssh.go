@@ -0,0 +1,233 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"argos/shared"
+	"golang.org/x/crypto/ssh"
+)
+
+// errKeyExchangeDone interrompe o handshake logo após a troca de chaves,
+// antes de qualquer tentativa de autenticação
+var errKeyExchangeDone = errors.New("key exchange complete")
+
+// HostKeyChange descreve uma chave de host diferente da esperada
+type HostKeyChange struct {
+	Target      string
+	Address     string
+	RemoteIP    string
+	KeyType     string
+	Fingerprint string
+	// Expected são as fingerprints fixadas ou a vista anteriormente
+	Expected []string
+	Pinned   bool
+}
+
+// SSHProbe lê o banner e conclui a troca de chaves sem autenticar. Sem
+// fingerprints fixadas, a primeira chave vista vira a referência (TOFU) e
+// uma mudança é reportada uma vez, passando a nova chave a ser a referência.
+// Com Store, a referência é lida e gravada nele; sem ele, fica em memória.
+// OnHostKeyChange roda fora da coleta.
+type SSHProbe struct {
+	Name              string
+	Host              string
+	Port              int
+	Timeout           time.Duration
+	Fingerprints      []string
+	HostKeyAlgorithms []string
+	OnHostKeyChange   func(HostKeyChange)
+	Store             HostKeyStore
+
+	mu       sync.Mutex
+	loaded   bool
+	lastSeen string
+	reported string
+}
+
+func NewSSHProbe(name, host string, port int, timeout time.Duration, fingerprints, hostKeyAlgorithms []string, onChange func(HostKeyChange)) *SSHProbe {
+	return &SSHProbe{
+		Name:              name,
+		Host:              host,
+		Port:              port,
+		Timeout:           timeout,
+		Fingerprints:      fingerprints,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		OnHostKeyChange:   onChange,
+	}
+}
+
+func (p *SSHProbe) Collect(ctx context.Context) []shared.Metric {
+	addr := net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+	start := time.Now()
+
+	banner, key, remote, err := p.handshake(ctx, addr)
+	latency := time.Since(start).Seconds() * 1000
+	ts := time.Now()
+
+	labels := map[string]string{"host": addr}
+	if err != nil {
+		return []shared.Metric{
+			{Service: "ssh", Target: p.Name, Name: "ssh_up", Value: 0, Labels: labels, TS: ts},
+			{Service: "ssh", Target: p.Name, Name: "ssh_latency_ms", Value: latency, Labels: labels, TS: ts},
+		}
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	keyLabels := map[string]string{
+		"host":        addr,
+		"banner":      banner,
+		"key_type":    key.Type(),
+		"fingerprint": fingerprint,
+	}
+
+	changed := 0.0
+	if change := p.checkHostKey(addr, remote, key.Type(), fingerprint); change != nil {
+		changed = 1
+		if p.OnHostKeyChange != nil && p.firstReport(fingerprint) {
+			go p.OnHostKeyChange(*change)
+		}
+	}
+
+	return []shared.Metric{
+		{Service: "ssh", Target: p.Name, Name: "ssh_up", Value: 1, Labels: labels, TS: ts},
+		{Service: "ssh", Target: p.Name, Name: "ssh_latency_ms", Value: latency, Labels: labels, TS: ts},
+		{Service: "ssh", Target: p.Name, Name: "ssh_hostkey_changed", Value: changed, Labels: keyLabels, TS: ts},
+	}
+}
+
+// checkHostKey compara com as fingerprints fixadas ou, sem elas, com a
+// última vista. Retorna nil se a chave for a esperada.
+func (p *SSHProbe) checkHostKey(addr, remote, keyType, fingerprint string) *HostKeyChange {
+	change := &HostKeyChange{
+		Target:      p.Name,
+		Address:     addr,
+		RemoteIP:    remote,
+		KeyType:     keyType,
+		Fingerprint: fingerprint,
+	}
+
+	if len(p.Fingerprints) > 0 {
+		for _, pinned := range p.Fingerprints {
+			if pinned == fingerprint {
+				return nil
+			}
+		}
+		change.Expected = p.Fingerprints
+		change.Pinned = true
+		return change
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.loaded && p.Store != nil {
+		p.lastSeen = p.Store.Load(addr)
+	}
+	p.loaded = true
+
+	previous := p.lastSeen
+	p.lastSeen = fingerprint
+	if previous != fingerprint && p.Store != nil {
+		if err := p.Store.Save(addr, fingerprint); err != nil {
+			log.Printf("ssh %s: failed to save host key: %v", p.Name, err)
+		}
+	}
+	if previous == "" || previous == fingerprint {
+		return nil
+	}
+	change.Expected = []string{previous}
+	return change
+}
+
+// firstReport evita repetir o evento a cada coleta enquanto uma chave
+// divergente de uma fixada continuar sendo apresentada
+func (p *SSHProbe) firstReport(fingerprint string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.reported == fingerprint {
+		return false
+	}
+	p.reported = fingerprint
+	return true
+}
+
+func (p *SSHProbe) handshake(ctx context.Context, addr string) (string, ssh.PublicKey, string, error) {
+	dialer := net.Dialer{Timeout: p.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", nil, "", err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(p.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	recorder := &bannerConn{Conn: conn}
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User:              "argos-probe",
+		HostKeyAlgorithms: p.HostKeyAlgorithms,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errKeyExchangeDone
+		},
+		Timeout: p.Timeout,
+	}
+
+	_, _, _, err = ssh.NewClientConn(recorder, addr, config)
+	if hostKey == nil {
+		if err == nil {
+			err = errors.New("no host key received")
+		}
+		return recorder.banner(), nil, "", err
+	}
+
+	remote, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	return recorder.banner(), hostKey, remote, nil
+}
+
+// bannerConn guarda o início do que o servidor envia para extrair a linha
+// de identificação ("SSH-2.0-OpenSSH_9.6"), lida internamente pela lib ssh
+type bannerConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *bannerConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.buf.Len() < 1024 {
+		c.buf.Write(b[:n])
+	}
+	return n, err
+}
+
+func (c *bannerConn) banner() string {
+	for _, line := range strings.Split(c.buf.String(), "\n") {
+		if strings.HasPrefix(line, "SSH-") {
+			// "SSH-2.0-OpenSSH_9.6p1 Ubuntu" -> "OpenSSH_9.6p1 Ubuntu"
+			line = strings.TrimSpace(line)
+			if parts := strings.SplitN(line, "-", 3); len(parts) == 3 {
+				return parts[2]
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+func (c HostKeyChange) String() string {
+	return fmt.Sprintf("%s %s key changed to %s (expected %s)", c.Address, c.KeyType, c.Fingerprint, strings.Join(c.Expected, ", "))
+}
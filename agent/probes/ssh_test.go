@@ -0,0 +1,190 @@
+package probes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startSSHServer aceita conexões e faz o handshake com a chave atual de
+// hostKey, que pode ser trocada durante o teste
+func startSSHServer(t *testing.T, hostKey *atomic.Pointer[ssh.Signer]) (string, int) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			config := &ssh.ServerConfig{NoClientAuth: true, ServerVersion: "SSH-2.0-ArgosTest_1.0"}
+			config.AddHostKey(*hostKey.Load())
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, config)
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(lis.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+func newHostKey(t *testing.T) ssh.Signer {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	return signer
+}
+
+func sshMetric(p *SSHProbe, name string) (float64, map[string]string) {
+	for _, m := range p.Collect(context.Background()) {
+		if m.Name == name {
+			return m.Value, m.Labels
+		}
+	}
+	return -1, nil
+}
+
+func TestSSHProbeBannerAndFingerprint(t *testing.T) {
+	var hostKey atomic.Pointer[ssh.Signer]
+	signer := newHostKey(t)
+	hostKey.Store(&signer)
+	host, port := startSSHServer(t, &hostKey)
+
+	probe := NewSSHProbe("bastion", host, port, 5*time.Second, nil, nil, nil)
+
+	if up, _ := sshMetric(probe, "ssh_up"); up != 1 {
+		t.Fatalf("Expected ssh_up=1, got %v", up)
+	}
+	changed, labels := sshMetric(probe, "ssh_hostkey_changed")
+	if changed != 0 {
+		t.Errorf("Expected ssh_hostkey_changed=0, got %v", changed)
+	}
+	if labels["banner"] != "ArgosTest_1.0" {
+		t.Errorf("Expected banner ArgosTest_1.0, got %q", labels["banner"])
+	}
+	if labels["fingerprint"] != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("Unexpected fingerprint %q", labels["fingerprint"])
+	}
+	if labels["key_type"] != "ssh-ed25519" {
+		t.Errorf("Expected key type ssh-ed25519, got %q", labels["key_type"])
+	}
+}
+
+func TestSSHProbeDetectsChangedKey(t *testing.T) {
+	var hostKey atomic.Pointer[ssh.Signer]
+	first := newHostKey(t)
+	hostKey.Store(&first)
+	host, port := startSSHServer(t, &hostKey)
+
+	changes := make(chan HostKeyChange, 10)
+	probe := NewSSHProbe("bastion", host, port, 5*time.Second, nil, nil, func(c HostKeyChange) {
+		changes <- c
+	})
+
+	sshMetric(probe, "ssh_up")
+
+	second := newHostKey(t)
+	hostKey.Store(&second)
+
+	if changed, _ := sshMetric(probe, "ssh_hostkey_changed"); changed != 1 {
+		t.Errorf("Expected ssh_hostkey_changed=1 after key rotation, got %v", changed)
+	}
+	select {
+	case c := <-changes:
+		if c.Expected[0] != ssh.FingerprintSHA256(first.PublicKey()) {
+			t.Fatalf("Expected change event against the first key, got %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change event")
+	}
+
+	// A nova chave passa a ser a referência
+	if changed, _ := sshMetric(probe, "ssh_hostkey_changed"); changed != 0 {
+		t.Errorf("Expected ssh_hostkey_changed=0 once the new key is known, got %v", changed)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected a single change event, got %d more", len(changes))
+	}
+}
+
+func TestSSHProbeRemembersKeyAcrossRestarts(t *testing.T) {
+	var hostKey atomic.Pointer[ssh.Signer]
+	first := newHostKey(t)
+	hostKey.Store(&first)
+	host, port := startSSHServer(t, &hostKey)
+
+	path := filepath.Join(t.TempDir(), "ssh_host_keys.json")
+	store, err := NewFileHostKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := NewSSHProbe("bastion", host, port, 5*time.Second, nil, nil, nil)
+	probe.Store = store
+	sshMetric(probe, "ssh_up")
+
+	// Agente reiniciado: a chave nova é comparada com a gravada
+	second := newHostKey(t)
+	hostKey.Store(&second)
+	store, err = NewFileHostKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewSSHProbe("bastion", host, port, 5*time.Second, nil, nil, nil)
+	restarted.Store = store
+	if changed, _ := sshMetric(restarted, "ssh_hostkey_changed"); changed != 1 {
+		t.Errorf("Expected ssh_hostkey_changed=1 after restart with a new key, got %v", changed)
+	}
+	if got := store.Load(net.JoinHostPort(host, strconv.Itoa(port))); got != ssh.FingerprintSHA256(second.PublicKey()) {
+		t.Errorf("Expected the new key to be saved, got %q", got)
+	}
+}
+
+func TestSSHProbePinnedFingerprint(t *testing.T) {
+	var hostKey atomic.Pointer[ssh.Signer]
+	signer := newHostKey(t)
+	hostKey.Store(&signer)
+	host, port := startSSHServer(t, &hostKey)
+
+	var events atomic.Int32
+	pinned := []string{"SHA256:not-the-real-key"}
+	probe := NewSSHProbe("bastion", host, port, 5*time.Second, pinned, nil, func(c HostKeyChange) {
+		events.Add(1)
+		if !c.Pinned {
+			t.Error("Expected change to be flagged as pinned")
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		if changed, _ := sshMetric(probe, "ssh_hostkey_changed"); changed != 1 {
+			t.Errorf("Expected ssh_hostkey_changed=1 for pinned mismatch, got %v", changed)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := events.Load(); n != 1 {
+		t.Errorf("Expected a single security event, got %d", n)
+	}
+}
+
+func TestSSHProbeUnreachable(t *testing.T) {
+	probe := NewSSHProbe("bastion", "127.0.0.1", 1, 500*time.Millisecond, nil, nil, nil)
+	if up, _ := sshMetric(probe, "ssh_up"); up != 0 {
+		t.Errorf("Expected ssh_up=0, got %v", up)
+	}
+}
@@ -0,0 +1,347 @@
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+var syntheticVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// maxSyntheticBody limita quanto de cada resposta é lido para extração/asserções
+const maxSyntheticBody = 1 << 20
+
+// Motivos de falha de um passo, no label reason de synthetic_failed_step. O
+// erro completo pode ter a URL expandida, com tokens extraídos, e vai só
+// para o log.
+const (
+	SyntheticReasonRequest = "request"
+	SyntheticReasonTimeout = "timeout"
+	SyntheticReasonConnect = "connect"
+	SyntheticReasonStatus  = "status"
+	SyntheticReasonAssert  = "assert"
+	SyntheticReasonExtract = "extract"
+)
+
+type stepError struct {
+	reason string
+	err    error
+}
+
+func (e *stepError) Error() string { return e.err.Error() }
+func (e *stepError) Unwrap() error { return e.err }
+
+func stepFailure(reason string, err error) error {
+	return &stepError{reason: reason, err: err}
+}
+
+// transportFailure distingue timeout de falha de conexão
+func transportFailure(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err) {
+		return stepFailure(SyntheticReasonTimeout, err)
+	}
+	return stepFailure(SyntheticReasonConnect, err)
+}
+
+func failureReason(err error) string {
+	var se *stepError
+	if errors.As(err, &se) {
+		return se.reason
+	}
+	return SyntheticReasonRequest
+}
+
+// SyntheticStep é uma requisição da transação. URL, headers e body aceitam
+// variáveis no formato ${nome}, definidas no probe ou extraídas de passos anteriores.
+type SyntheticStep struct {
+	Name    string
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	Extract []SyntheticExtract
+	Assert  SyntheticAssert
+}
+
+// SyntheticExtract captura um valor da resposta em uma variável. Exatamente
+// uma das fontes (JSONPath, Header, Regex) deve ser definida; no regex é
+// usado o primeiro grupo de captura, ou o match inteiro se não houver grupos.
+type SyntheticExtract struct {
+	Var      string
+	JSONPath string
+	Header   string
+	Regex    *regexp.Regexp
+}
+
+// SyntheticAssert define as condições para o passo ser considerado OK.
+// Sem Status, qualquer 2xx é aceito.
+type SyntheticAssert struct {
+	Status       []int
+	BodyContains string
+	BodyRegex    *regexp.Regexp
+	JSON         map[string]string
+	MaxLatency   time.Duration
+}
+
+type SyntheticProbe struct {
+	Name        string
+	Steps       []SyntheticStep
+	Variables   map[string]string
+	Timeout     time.Duration
+	StepTimeout time.Duration
+	Options     HTTPOptions
+	client      *http.Client
+}
+
+func NewSyntheticProbe(name string, steps []SyntheticStep, variables map[string]string, timeout, stepTimeout time.Duration, opts HTTPOptions) *SyntheticProbe {
+	return &SyntheticProbe{
+		Name:        name,
+		Steps:       steps,
+		Variables:   variables,
+		Timeout:     timeout,
+		StepTimeout: stepTimeout,
+		Options:     opts,
+		client:      NewHTTPClient(stepTimeout, opts),
+	}
+}
+
+func (p *SyntheticProbe) Collect(ctx context.Context) []shared.Metric {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	// Cada execução tem sua própria sessão de cookies (ex: login)
+	client := *p.client
+	client.Jar, _ = cookiejar.New(nil)
+
+	vars := make(map[string]string, len(p.Variables))
+	for k, v := range p.Variables {
+		vars[k] = v
+	}
+
+	var metrics []shared.Metric
+	failedIndex := 0
+	failedStep, reason := "", ""
+	start := time.Now()
+
+	for i, step := range p.Steps {
+		stepStart := time.Now()
+		err := p.runStep(ctx, &client, step, vars)
+		latency := time.Since(stepStart).Seconds() * 1000
+		ts := time.Now()
+
+		labels := map[string]string{"step": step.Name, "index": strconv.Itoa(i + 1)}
+		up := 1.0
+		if err != nil {
+			up = 0
+		}
+		metrics = append(metrics,
+			shared.Metric{Service: "synthetic", Target: p.Name, Name: "synthetic_step_up", Value: up, Labels: labels, TS: ts},
+			shared.Metric{Service: "synthetic", Target: p.Name, Name: "synthetic_step_latency_ms", Value: latency, Labels: labels, TS: ts},
+		)
+
+		// Os passos seguintes dependem deste; a transação para no primeiro erro
+		if err != nil {
+			failedIndex = i + 1
+			failedStep = step.Name
+			reason = failureReason(err)
+			log.Printf("synthetic %s: step %s failed (%s): %v", p.Name, step.Name, reason, err)
+			break
+		}
+	}
+
+	total := time.Since(start).Seconds() * 1000
+	ts := time.Now()
+	up := 1.0
+	if failedIndex > 0 {
+		up = 0
+	}
+
+	failedLabels := map[string]string{"failed_step": failedStep, "reason": reason}
+	return append(metrics,
+		shared.Metric{Service: "synthetic", Target: p.Name, Name: "synthetic_up", Value: up, TS: ts},
+		shared.Metric{Service: "synthetic", Target: p.Name, Name: "synthetic_duration_ms", Value: total, TS: ts},
+		shared.Metric{Service: "synthetic", Target: p.Name, Name: "synthetic_failed_step", Value: float64(failedIndex), Labels: failedLabels, TS: ts},
+	)
+}
+
+func (p *SyntheticProbe) runStep(ctx context.Context, client *http.Client, step SyntheticStep, vars map[string]string) error {
+	url, err := expandVars(step.URL, vars)
+	if err != nil {
+		return err
+	}
+	body, err := expandVars(step.Body, vars)
+	if err != nil {
+		return err
+	}
+
+	req, err := p.Options.NewRequest(ctx, step.Method, url, body)
+	if err != nil {
+		return err
+	}
+	// Headers do passo têm precedência sobre os do probe (e sobre a autenticação)
+	for k, v := range step.Headers {
+		value, err := expandVars(v, vars)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(k, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(k, value)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return transportFailure(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSyntheticBody))
+	latency := time.Since(start)
+	if err != nil {
+		return transportFailure(fmt.Errorf("read body: %w", err))
+	}
+
+	// JSON é decodificado sob demanda, no máximo uma vez por passo
+	var doc any
+	var docErr error
+	decoded := false
+	jsonDoc := func() (any, error) {
+		if !decoded {
+			decoded = true
+			docErr = json.Unmarshal(data, &doc)
+		}
+		return doc, docErr
+	}
+
+	if err := checkAssertions(step.Assert, resp, data, latency, jsonDoc); err != nil {
+		if failureReason(err) == SyntheticReasonStatus {
+			return err
+		}
+		return stepFailure(SyntheticReasonAssert, err)
+	}
+
+	for _, e := range step.Extract {
+		value, err := extractValue(e, resp, data, jsonDoc)
+		if err != nil {
+			return stepFailure(SyntheticReasonExtract, fmt.Errorf("extract %s: %w", e.Var, err))
+		}
+		vars[e.Var] = value
+	}
+	return nil
+}
+
+func checkAssertions(a SyntheticAssert, resp *http.Response, body []byte, latency time.Duration, jsonDoc func() (any, error)) error {
+	if len(a.Status) > 0 {
+		ok := false
+		for _, code := range a.Status {
+			if resp.StatusCode == code {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return stepFailure(SyntheticReasonStatus, fmt.Errorf("status %d, expected %v", resp.StatusCode, a.Status))
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return stepFailure(SyntheticReasonStatus, fmt.Errorf("status %d, expected 2xx", resp.StatusCode))
+	}
+
+	if a.MaxLatency > 0 && latency > a.MaxLatency {
+		return fmt.Errorf("latency exceeds %s", a.MaxLatency)
+	}
+	if a.BodyContains != "" && !strings.Contains(string(body), a.BodyContains) {
+		return fmt.Errorf("body does not contain %q", a.BodyContains)
+	}
+	if a.BodyRegex != nil && !a.BodyRegex.Match(body) {
+		return fmt.Errorf("body does not match %q", a.BodyRegex)
+	}
+
+	for path, want := range a.JSON {
+		doc, err := jsonDoc()
+		if err != nil {
+			return fmt.Errorf("invalid JSON body: %w", err)
+		}
+		got, found := LookupJSON(doc, path)
+		if !found {
+			return fmt.Errorf("json %s: not found", path)
+		}
+		if JSONString(got) != want {
+			return fmt.Errorf("json %s: got %q, expected %q", path, JSONString(got), want)
+		}
+	}
+	return nil
+}
+
+func extractValue(e SyntheticExtract, resp *http.Response, body []byte, jsonDoc func() (any, error)) (string, error) {
+	switch {
+	case e.JSONPath != "":
+		doc, err := jsonDoc()
+		if err != nil {
+			return "", fmt.Errorf("invalid JSON body: %w", err)
+		}
+		v, found := LookupJSON(doc, e.JSONPath)
+		if !found {
+			return "", fmt.Errorf("json %s: not found", e.JSONPath)
+		}
+		return JSONString(v), nil
+
+	case e.Header != "":
+		v := resp.Header.Get(e.Header)
+		if v == "" {
+			return "", fmt.Errorf("header %s: not found", e.Header)
+		}
+		return v, nil
+
+	case e.Regex != nil:
+		match := e.Regex.FindSubmatch(body)
+		if match == nil {
+			return "", fmt.Errorf("regex %q: no match", e.Regex)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}
+	return "", fmt.Errorf("no source configured")
+}
+
+// expandVars substitui ${nome}; variáveis indefinidas são erro do passo
+func expandVars(s string, vars map[string]string) (string, error) {
+	var missing []string
+	out := syntheticVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-1]
+		v, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// SyntheticVars retorna os nomes de variáveis referenciados em s
+func SyntheticVars(s string) []string {
+	var names []string
+	for _, m := range syntheticVarPattern.FindAllStringSubmatch(s, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
@@ -0,0 +1,228 @@
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+// newCRUDServer simula uma API com login por token e um recurso
+func newCRUDServer(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	items := map[string]string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"token": "tok-123"}})
+	})
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		items["42"] = "widget"
+		mu.Unlock()
+		w.Header().Set("Location", "/items/42")
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/items/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/items/")
+		mu.Lock()
+		defer mu.Unlock()
+		name, ok := items[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(items, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`<item id="` + id + `">` + name + `</item>`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func syntheticValues(metrics []shared.Metric) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range metrics {
+		key := m.Name
+		if step := m.Labels["step"]; step != "" {
+			key += "/" + step
+		}
+		values[key] = m.Value
+	}
+	return values
+}
+
+func crudSteps() []SyntheticStep {
+	return []SyntheticStep{
+		{
+			Name: "login", Method: "POST", URL: "${base}/login",
+			Extract: []SyntheticExtract{{Var: "token", JSONPath: "data.token"}},
+		},
+		{
+			Name: "create", Method: "POST", URL: "${base}/items",
+			Headers: map[string]string{"Authorization": "Bearer ${token}"},
+			Extract: []SyntheticExtract{{Var: "item", Header: "Location"}},
+			Assert:  SyntheticAssert{Status: []int{201}},
+		},
+		{
+			Name: "read", Method: "GET", URL: "${base}${item}",
+			Extract: []SyntheticExtract{{Var: "id", Regex: regexp.MustCompile(`id="(\d+)"`)}},
+			Assert:  SyntheticAssert{BodyContains: "widget"},
+		},
+		{
+			Name: "delete", Method: "DELETE", URL: "${base}/items/${id}",
+			Assert: SyntheticAssert{Status: []int{204}},
+		},
+	}
+}
+
+func TestSyntheticProbeSuccess(t *testing.T) {
+	server := newCRUDServer(t)
+	probe := NewSyntheticProbe("crud", crudSteps(), map[string]string{"base": server.URL},
+		10*time.Second, 5*time.Second, DefaultHTTPOptions())
+
+	values := syntheticValues(probe.Collect(context.Background()))
+
+	if values["synthetic_up"] != 1 {
+		t.Errorf("Expected synthetic_up=1, got %v", values["synthetic_up"])
+	}
+	if values["synthetic_failed_step"] != 0 {
+		t.Errorf("Expected no failed step, got %v", values["synthetic_failed_step"])
+	}
+	for _, step := range []string{"login", "create", "read", "delete"} {
+		if values["synthetic_step_up/"+step] != 1 {
+			t.Errorf("Expected step %s to pass", step)
+		}
+		if _, ok := values["synthetic_step_latency_ms/"+step]; !ok {
+			t.Errorf("Missing latency for step %s", step)
+		}
+	}
+}
+
+func TestSyntheticProbeReportsFirstFailingStep(t *testing.T) {
+	server := newCRUDServer(t)
+	steps := crudSteps()
+	// Sem o token a criação deve falhar e os passos seguintes não rodam
+	steps[0].Extract = nil
+	probe := NewSyntheticProbe("crud", steps, map[string]string{"base": server.URL, "token": "wrong"},
+		10*time.Second, 5*time.Second, DefaultHTTPOptions())
+
+	metrics := probe.Collect(context.Background())
+	values := syntheticValues(metrics)
+
+	if values["synthetic_up"] != 0 {
+		t.Errorf("Expected synthetic_up=0, got %v", values["synthetic_up"])
+	}
+	if values["synthetic_failed_step"] != 2 {
+		t.Errorf("Expected failed step index 2, got %v", values["synthetic_failed_step"])
+	}
+	if _, ran := values["synthetic_step_up/read"]; ran {
+		t.Error("Steps after the failure must not run")
+	}
+
+	for _, m := range metrics {
+		if m.Name == "synthetic_failed_step" {
+			if m.Labels["failed_step"] != "create" {
+				t.Errorf("Expected failed_step=create, got %q", m.Labels["failed_step"])
+			}
+			if m.Labels["reason"] != SyntheticReasonStatus {
+				t.Errorf("Expected reason status, got %q", m.Labels["reason"])
+			}
+			if _, ok := m.Labels["error"]; ok {
+				t.Error("Raw error text must not be a label")
+			}
+		}
+	}
+}
+
+func TestSyntheticJSONAssertion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok","checks":[{"healthy":true}]}`))
+	}))
+	defer server.Close()
+
+	steps := []SyntheticStep{{
+		Name: "health", Method: "GET", URL: server.URL,
+		Assert: SyntheticAssert{JSON: map[string]string{"status": "ok", "checks.0.healthy": "false"}},
+	}}
+	probe := NewSyntheticProbe("health", steps, nil, 5*time.Second, 5*time.Second, DefaultHTTPOptions())
+
+	if values := syntheticValues(probe.Collect(context.Background())); values["synthetic_up"] != 0 {
+		t.Error("Expected JSON assertion to fail")
+	}
+}
+
+func TestSyntheticFailureReasons(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer slow.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := map[string]struct {
+		step        SyntheticStep
+		stepTimeout time.Duration
+		reason      string
+	}{
+		"assert":  {SyntheticStep{Name: "s", Method: "GET", URL: slow.URL, Assert: SyntheticAssert{BodyContains: "ok"}}, 5 * time.Second, SyntheticReasonAssert},
+		"connect": {SyntheticStep{Name: "s", Method: "GET", URL: closed.URL + "/?token=secret"}, 5 * time.Second, SyntheticReasonConnect},
+		"timeout": {SyntheticStep{Name: "s", Method: "GET", URL: slow.URL}, 100 * time.Millisecond, SyntheticReasonTimeout},
+	}
+	for name, tt := range tests {
+		probe := NewSyntheticProbe("reasons", []SyntheticStep{tt.step}, nil, 5*time.Second, tt.stepTimeout, DefaultHTTPOptions())
+		for _, m := range probe.Collect(context.Background()) {
+			if m.Name != "synthetic_failed_step" {
+				continue
+			}
+			if m.Labels["reason"] != tt.reason {
+				t.Errorf("%s: expected reason %s, got %q", name, tt.reason, m.Labels["reason"])
+			}
+			for _, v := range m.Labels {
+				if strings.Contains(v, "secret") {
+					t.Errorf("%s: URL leaked into labels: %v", name, m.Labels)
+				}
+			}
+		}
+	}
+}
+
+func TestLookupJSON(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{"queue":{"depth":42},"a.b":1,"items":[{"id":"x"}]}`), &doc)
+
+	tests := []struct {
+		path  string
+		want  string
+		found bool
+	}{
+		{"queue.depth", "42", true},
+		{"$.queue.depth", "42", true},
+		{`a\.b`, "1", true},
+		{"items.0.id", "x", true},
+		{"items.1.id", "", false},
+		{"queue.missing", "", false},
+	}
+	for _, tt := range tests {
+		v, found := LookupJSON(doc, tt.path)
+		if found != tt.found || JSONString(v) != tt.want {
+			t.Errorf("%s: expected %q (found=%v), got %q (found=%v)", tt.path, tt.want, tt.found, JSONString(v), found)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+// GUID fixo do RFC 6455 usado no cálculo de Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage limita o tamanho de uma mensagem recebida
+const maxWebSocketMessage = 1 << 20
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// WebSocketProbe faz o handshake e, opcionalmente, envia Send e espera uma
+// resposta que case com Expect. Sem Expect, qualquer mensagem é aceita.
+type WebSocketProbe struct {
+	Name         string
+	URL          string
+	Timeout      time.Duration
+	Subprotocols []string
+	Send         string
+	Expect       *regexp.Regexp
+	Options      HTTPOptions
+}
+
+func NewWebSocketProbe(name, url string, timeout time.Duration, subprotocols []string, send string, expect *regexp.Regexp, opts HTTPOptions) *WebSocketProbe {
+	return &WebSocketProbe{
+		Name:         name,
+		URL:          url,
+		Timeout:      timeout,
+		Subprotocols: subprotocols,
+		Send:         send,
+		Expect:       expect,
+		Options:      opts,
+	}
+}
+
+func (p *WebSocketProbe) Collect(ctx context.Context) []shared.Metric {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	labels := map[string]string{"url": p.URL}
+	start := time.Now()
+
+	conn, err := p.handshake(ctx)
+	handshake := time.Since(start).Seconds() * 1000
+	ts := time.Now()
+
+	if err != nil {
+		return []shared.Metric{
+			{Service: "websocket", Target: p.Name, Name: "ws_up", Value: 0, Labels: labels, TS: ts},
+			{Service: "websocket", Target: p.Name, Name: "ws_handshake_latency_ms", Value: handshake, Labels: labels, TS: ts},
+		}
+	}
+	defer conn.close()
+
+	metrics := []shared.Metric{
+		{Service: "websocket", Target: p.Name, Name: "ws_handshake_latency_ms", Value: handshake, Labels: labels, TS: ts},
+	}
+
+	up := 1.0
+	if p.Send != "" {
+		sent := time.Now()
+		err := conn.roundTrip(p.Send, p.Expect)
+		rtt := time.Since(sent).Seconds() * 1000
+		if err != nil {
+			up = 0
+		} else {
+			metrics = append(metrics, shared.Metric{
+				Service: "websocket", Target: p.Name, Name: "ws_message_rtt_ms", Value: rtt, Labels: labels, TS: time.Now(),
+			})
+		}
+	}
+
+	return append(metrics, shared.Metric{
+		Service: "websocket", Target: p.Name, Name: "ws_up", Value: up, Labels: labels, TS: time.Now(),
+	})
+}
+
+type wsConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (p *WebSocketProbe) handshake(ctx context.Context) (*wsConn, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	secure := u.Scheme == "wss"
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if secure {
+			port = "443"
+		}
+	}
+
+	network := "tcp"
+	if p.Options.IPVersion == 4 || p.Options.IPVersion == 6 {
+		network = fmt.Sprintf("tcp%d", p.Options.IPVersion)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if secure {
+		cfg := &tls.Config{}
+		if p.Options.TLSConfig != nil {
+			cfg = p.Options.TLSConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		cfg.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	httpURL := *u
+	httpURL.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+	req, err := p.Options.NewRequest(ctx, http.MethodGet, httpURL.String(), "")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(p.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(p.Subprotocols, ", "))
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("handshake status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		conn.Close()
+		return nil, errors.New("invalid Sec-WebSocket-Accept")
+	}
+
+	return &wsConn{Conn: conn, reader: reader}, nil
+}
+
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// roundTrip envia a mensagem e lê até chegar uma resposta que case com expect
+func (c *wsConn) roundTrip(message string, expect *regexp.Regexp) error {
+	if err := c.writeFrame(wsOpText, []byte(message)); err != nil {
+		return err
+	}
+
+	for {
+		reply, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if expect == nil || expect.Match(reply) {
+			return nil
+		}
+	}
+}
+
+// writeFrame envia um frame final; frames do cliente são sempre mascarados
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 0x80|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	header = append(header, mask...)
+
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+
+	_, err := c.Write(append(header, masked...))
+	return err
+}
+
+// readMessage retorna a próxima mensagem de dados, juntando fragmentos e
+// respondendo a pings no caminho
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			return nil, errors.New("connection closed by server")
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketMessage {
+				return nil, errors.New("message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("unexpected opcode %d", opcode)
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, errors.New("frame too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *wsConn) close() {
+	// Close normal (1000); erros aqui não afetam o resultado do probe
+	c.writeFrame(wsOpClose, []byte{0x03, 0xE8})
+	c.Close()
+}
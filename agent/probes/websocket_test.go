@@ -0,0 +1,127 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWebSocketServer aceita o upgrade e responde cada mensagem de texto com
+// um ping seguido de "ignored" e "pong:<mensagem>", em frames não mascarados
+func newWebSocketServer(t *testing.T, accept func(key string) string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + accept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		ws := &wsConn{Conn: conn, reader: rw.Reader}
+		for {
+			_, opcode, payload, err := ws.readFrame()
+			if err != nil || opcode == wsOpClose {
+				return
+			}
+			for _, frame := range [][]byte{
+				{0x80 | wsOpPing, 0},
+				serverFrame("ignored"),
+				serverFrame("pong:" + string(payload)),
+			} {
+				conn.Write(frame)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serverFrame(text string) []byte {
+	return append([]byte{0x80 | wsOpText, byte(len(text))}, text...)
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func wsValues(p *WebSocketProbe) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range p.Collect(context.Background()) {
+		values[m.Name] = m.Value
+	}
+	return values
+}
+
+func TestWebSocketProbeRoundTrip(t *testing.T) {
+	server := newWebSocketServer(t, websocketAccept)
+
+	probe := NewWebSocketProbe("gateway", wsURL(server), 5*time.Second, nil,
+		`{"type":"ping"}`, regexp.MustCompile(`^pong:`), DefaultHTTPOptions())
+	values := wsValues(probe)
+
+	if values["ws_up"] != 1 {
+		t.Errorf("Expected ws_up=1, got %v", values["ws_up"])
+	}
+	if _, ok := values["ws_handshake_latency_ms"]; !ok {
+		t.Error("Missing ws_handshake_latency_ms")
+	}
+	if _, ok := values["ws_message_rtt_ms"]; !ok {
+		t.Error("Missing ws_message_rtt_ms")
+	}
+}
+
+func TestWebSocketProbeNoMatchingReply(t *testing.T) {
+	server := newWebSocketServer(t, websocketAccept)
+
+	probe := NewWebSocketProbe("gateway", wsURL(server), 300*time.Millisecond, nil,
+		"hello", regexp.MustCompile(`never`), DefaultHTTPOptions())
+	values := wsValues(probe)
+
+	if values["ws_up"] != 0 {
+		t.Errorf("Expected ws_up=0 when no reply matches, got %v", values["ws_up"])
+	}
+	if _, ok := values["ws_message_rtt_ms"]; ok {
+		t.Error("Expected no RTT when the reply never arrives")
+	}
+}
+
+func TestWebSocketProbeHandshakeOnly(t *testing.T) {
+	server := newWebSocketServer(t, websocketAccept)
+
+	probe := NewWebSocketProbe("gateway", wsURL(server), 5*time.Second, nil, "", nil, DefaultHTTPOptions())
+	if values := wsValues(probe); values["ws_up"] != 1 {
+		t.Errorf("Expected ws_up=1 for handshake only, got %v", values["ws_up"])
+	}
+}
+
+func TestWebSocketProbeInvalidAccept(t *testing.T) {
+	server := newWebSocketServer(t, func(string) string { return "bogus" })
+
+	probe := NewWebSocketProbe("gateway", wsURL(server), 5*time.Second, nil, "", nil, DefaultHTTPOptions())
+	if values := wsValues(probe); values["ws_up"] != 0 {
+		t.Errorf("Expected ws_up=0 for invalid accept key, got %v", values["ws_up"])
+	}
+}
+
+func TestWebSocketProbeNotUpgraded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	probe := NewWebSocketProbe("gateway", wsURL(server), 5*time.Second, nil, "", nil, DefaultHTTPOptions())
+	if values := wsValues(probe); values["ws_up"] != 0 {
+		t.Errorf("Expected ws_up=0 without upgrade, got %v", values["ws_up"])
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProbeSpec descreve como um tipo de probe é configurado e construído.
+// T é a struct de configuração do target, decodificada do YAML.
+type ProbeSpec[T any] struct {
+	// Service é o service das métricas do probe (ex: "web" para http), usado
+	// também em probe_timeout. Sem ele, vale o nome do tipo.
+	Service  string
+	Defaults func(cfg *T)
+	Validate func(cfg *T) error
+	Build    func(cfg *T) Probe
+	Describe func(cfg *T) string
+	// Timeout retorna o timeout do target, de onde sai o deadline da coleta.
+	// Em probes de vários passos, precisa cobrir o pior caso do probe
+	// inteiro, não de um passo. Sem ele, vale probe_timeout.
+	Timeout func(cfg *T) time.Duration
+}
+
+type probeType struct {
+	name    string
+	service string
+	load    func(node *yaml.Node) (*loadedTarget, error)
+}
+
+type loadedTarget struct {
+	config   any
+	build    func() Probe
+	describe string
+	timeout  time.Duration
+}
+
+var probeRegistry = map[string]*probeType{}
+
+// RegisterProbe adiciona um tipo de probe ao registro. Deve ser chamado em init().
+func RegisterProbe[T any](name string, spec ProbeSpec[T]) {
+	if _, exists := probeRegistry[name]; exists {
+		panic(fmt.Sprintf("probe type %q registered twice", name))
+	}
+
+	service := spec.Service
+	if service == "" {
+		service = name
+	}
+
+	probeRegistry[name] = &probeType{
+		name:    name,
+		service: service,
+		load: func(node *yaml.Node) (*loadedTarget, error) {
+			cfg := new(T)
+			if err := checkKnownFields(node, reflect.TypeOf(cfg), commonTargetFields); err != nil {
+				return nil, err
+			}
+			if err := node.Decode(cfg); err != nil {
+				return nil, err
+			}
+			if spec.Defaults != nil {
+				spec.Defaults(cfg)
+			}
+			if spec.Validate != nil {
+				if err := spec.Validate(cfg); err != nil {
+					return nil, err
+				}
+			}
+
+			lt := &loadedTarget{
+				config: cfg,
+				build:  func() Probe { return spec.Build(cfg) },
+			}
+			if spec.Describe != nil {
+				lt.describe = spec.Describe(cfg)
+			}
+			if spec.Timeout != nil {
+				lt.timeout = spec.Timeout(cfg)
+			}
+			return lt, nil
+		},
+	}
+}
+
+// commonTargetFields são as chaves de Target, aceitas em qualquer tipo
+var commonTargetFields = map[string]bool{"type": true, "name": true, "labels": true}
+
+var yamlUnmarshaler = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkKnownFields recusa chaves que não existem em t, com a linha de cada
+// uma, como o KnownFields do yaml.Decoder, que node.Decode não tem. Tipos
+// com UnmarshalYAML próprio não são verificados.
+func checkKnownFields(node *yaml.Node, t reflect.Type, extra map[string]bool) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if reflect.PointerTo(t).Implements(yamlUnmarshaler) {
+		return nil
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := map[string]reflect.Type{}
+		collectYAMLFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			ft, ok := fields[key.Value]
+			if !ok {
+				if extra[key.Value] || key.Tag == "!!merge" {
+					continue
+				}
+				return fmt.Errorf("line %d: unknown field %q", key.Line, key.Value)
+			}
+			if err := checkKnownFields(node.Content[i+1], ft, nil); err != nil {
+				return err
+			}
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := checkKnownFields(item, t.Elem(), nil); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := checkKnownFields(node.Content[i], t.Elem(), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// collectYAMLFields junta as chaves de t com seus tipos, seguindo as regras
+// do yaml.v3: a tag, ou o nome do campo em minúsculas, e structs ",inline"
+func collectYAMLFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			if ft := f.Type; ft.Kind() == reflect.Struct {
+				collectYAMLFields(ft, fields)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+// ProbeTypes retorna os nomes dos tipos registrados em ordem alfabética.
+func ProbeTypes() []string {
+	names := make([]string, 0, len(probeRegistry))
+	for name := range probeRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Target é uma entrada da lista `targets` do config. Os campos comuns
+// ficam aqui; o restante é decodificado pelo tipo de probe registrado.
+type Target struct {
+	Type   string            `yaml:"type"`
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels"`
+
+	line   int
+	node   *yaml.Node
+	loaded *loadedTarget
+}
+
+func (t *Target) UnmarshalYAML(node *yaml.Node) error {
+	type header Target
+	var h header
+	if err := node.Decode(&h); err != nil {
+		return err
+	}
+
+	*t = Target(h)
+	t.line = node.Line
+	t.node = node
+	return nil
+}
+
+// Config retorna a configuração tipada do target (ex: *HTTPTarget).
+func (t *Target) Config() any {
+	if t.loaded == nil {
+		return nil
+	}
+	return t.loaded.config
+}
+
+func (t *Target) Probe() Probe {
+	p := t.loaded.build()
+	if len(t.Labels) > 0 {
+		return &labeledProbe{Probe: p, labels: t.Labels}
+	}
+	return p
+}
+
+// Service retorna o service das métricas do tipo do target
+func (t *Target) Service() string {
+	if pt, ok := probeRegistry[t.Type]; ok {
+		return pt.service
+	}
+	return t.Type
+}
+
+// Timeout retorna o timeout configurado do target ou zero se o tipo não tem
+func (t *Target) Timeout() time.Duration {
+	if t.loaded == nil {
+		return 0
+	}
+	return t.loaded.timeout
+}
+
+func (t *Target) Describe() string {
+	if t.loaded == nil || t.loaded.describe == "" {
+		return t.Name
+	}
+	return t.Name + " -> " + t.loaded.describe
+}
+
+// TargetList aceita tanto a lista tipada:
+//
+//	targets:
+//	  - type: http
+//	    name: site
+//
+// quanto o formato antigo agrupado por tipo:
+//
+//	targets:
+//	  http:
+//	    - name: site
+type TargetList []Target
+
+func (l *TargetList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var targets []Target
+		if err := node.Decode(&targets); err != nil {
+			return err
+		}
+		*l = targets
+		return nil
+
+	case yaml.MappingNode:
+		var targets []Target
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			typeName := node.Content[i].Value
+			var group []Target
+			if err := node.Content[i+1].Decode(&group); err != nil {
+				return err
+			}
+			for j := range group {
+				if group[j].Type == "" {
+					group[j].Type = typeName
+				}
+			}
+			targets = append(targets, group...)
+		}
+		*l = targets
+		return nil
+
+	default:
+		return fmt.Errorf("line %d: targets must be a list", node.Line)
+	}
+}
+
+// resolve decodifica, aplica defaults e valida cada target usando o registro.
+// Todos os erros encontrados são retornados juntos.
+func (l TargetList) resolve() error {
+	var errs []string
+	seen := map[string]int{}
+
+	for i := range l {
+		t := &l[i]
+		where := fmt.Sprintf("line %d: target %q", t.line, t.Name)
+
+		if t.Name == "" {
+			where = fmt.Sprintf("line %d: target #%d", t.line, i+1)
+			errs = append(errs, where+": name is required")
+		} else if prev, dup := seen[t.Name]; dup {
+			errs = append(errs, fmt.Sprintf("%s: duplicate target name (first defined at line %d)", where, prev))
+		} else {
+			seen[t.Name] = t.line
+		}
+
+		if t.Type == "" {
+			errs = append(errs, where+": type is required")
+			continue
+		}
+
+		pt, ok := probeRegistry[t.Type]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: unknown probe type %q (known: %s)",
+				where, t.Type, strings.Join(ProbeTypes(), ", ")))
+			continue
+		}
+
+		loaded, err := pt.load(t.node)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s (%s): %v", where, t.Type, err))
+			continue
+		}
+		t.loaded = loaded
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid targets:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
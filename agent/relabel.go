@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"argos/shared"
+)
+
+// Pseudo-labels disponíveis para as regras; removidos após o relabel.
+// Alterar __name__ renomeia a métrica.
+const (
+	labelMetricName = "__name__"
+	labelService    = "__service__"
+	labelTarget     = "__target__"
+)
+
+// RelabelConfig segue a semântica do relabel_configs do Prometheus
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+
+	re *regexp.Regexp
+}
+
+func (rc *RelabelConfig) compile() error {
+	if rc.Action == "" {
+		rc.Action = "replace"
+	}
+	if rc.Separator == "" {
+		rc.Separator = ";"
+	}
+	if rc.Regex == "" {
+		rc.Regex = "(.*)"
+	}
+	if rc.Replacement == "" && (rc.Action == "replace" || rc.Action == "labelmap") {
+		rc.Replacement = "$1"
+	}
+
+	re, err := regexp.Compile("^(?:" + rc.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("regex %q: %v", rc.Regex, err)
+	}
+	rc.re = re
+
+	switch rc.Action {
+	case "replace":
+		if rc.TargetLabel == "" {
+			return fmt.Errorf("action replace requires target_label")
+		}
+	case "keep", "drop":
+		if len(rc.SourceLabels) == 0 {
+			return fmt.Errorf("action %s requires source_labels", rc.Action)
+		}
+	case "labelmap", "labeldrop", "labelkeep":
+	default:
+		return fmt.Errorf("unknown action %q (expected replace, keep, drop, labelmap, labeldrop or labelkeep)", rc.Action)
+	}
+
+	return nil
+}
+
+// apply executa a regra sobre o conjunto de labels. Retorna false se a
+// métrica deve ser descartada.
+func (rc *RelabelConfig) apply(labels map[string]string) bool {
+	values := make([]string, len(rc.SourceLabels))
+	for i, name := range rc.SourceLabels {
+		values[i] = labels[name]
+	}
+	value := strings.Join(values, rc.Separator)
+
+	switch rc.Action {
+	case "keep":
+		return rc.re.MatchString(value)
+	case "drop":
+		return !rc.re.MatchString(value)
+	case "replace":
+		match := rc.re.FindStringSubmatchIndex(value)
+		if match == nil {
+			return true
+		}
+		target := string(rc.re.ExpandString(nil, rc.TargetLabel, value, match))
+		result := string(rc.re.ExpandString(nil, rc.Replacement, value, match))
+		if result == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = result
+		}
+	case "labelmap":
+		names := make([]string, 0, len(labels))
+		for name := range labels {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if match := rc.re.FindStringSubmatchIndex(name); match != nil {
+				labels[string(rc.re.ExpandString(nil, rc.Replacement, name, match))] = labels[name]
+			}
+		}
+	case "labeldrop":
+		for name := range labels {
+			if rc.re.MatchString(name) && !strings.HasPrefix(name, "__") {
+				delete(labels, name)
+			}
+		}
+	case "labelkeep":
+		for name := range labels {
+			if !rc.re.MatchString(name) && !strings.HasPrefix(name, "__") {
+				delete(labels, name)
+			}
+		}
+	}
+	return true
+}
+
+func compileRelabelConfigs(configs []RelabelConfig) error {
+	for i := range configs {
+		if err := configs[i].compile(); err != nil {
+			return fmt.Errorf("relabel_configs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// labelPipeline aplica external_labels e relabel_configs antes do push
+type labelPipeline struct {
+	externalLabels map[string]string
+	rules          []RelabelConfig
+}
+
+func newLabelPipeline(cfg *Config) *labelPipeline {
+	return &labelPipeline{
+		externalLabels: cfg.ExternalLabels,
+		rules:          cfg.RelabelConfigs,
+	}
+}
+
+func (p *labelPipeline) Process(metrics []shared.Metric) []shared.Metric {
+	if len(p.externalLabels) == 0 && len(p.rules) == 0 {
+		return metrics
+	}
+
+	out := metrics[:0:0]
+	for _, m := range metrics {
+		// Probes compartilham o mesmo map entre métricas; sempre copiar
+		labels := make(map[string]string, len(m.Labels)+len(p.externalLabels)+3)
+		for k, v := range m.Labels {
+			labels[k] = v
+		}
+		// external_labels não sobrescrevem labels existentes
+		for k, v := range p.externalLabels {
+			if _, exists := labels[k]; !exists {
+				labels[k] = v
+			}
+		}
+
+		labels[labelMetricName] = m.Name
+		labels[labelService] = m.Service
+		labels[labelTarget] = m.Target
+
+		keep := true
+		for i := range p.rules {
+			if !p.rules[i].apply(labels) {
+				keep = false
+				break
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		m.Name = labels[labelMetricName]
+		m.Service = labels[labelService]
+		m.Target = labels[labelTarget]
+		for k := range labels {
+			if strings.HasPrefix(k, "__") {
+				delete(labels, k)
+			}
+		}
+		m.Labels = labels
+
+		if m.Name == "" {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// labeledProbe adiciona os labels configurados no target às métricas do probe
+type labeledProbe struct {
+	Probe
+	labels map[string]string
+}
+
+func (p *labeledProbe) Collect(ctx context.Context) []shared.Metric {
+	metrics := p.Probe.Collect(ctx)
+	for i := range metrics {
+		labels := make(map[string]string, len(metrics[i].Labels)+len(p.labels))
+		for k, v := range metrics[i].Labels {
+			labels[k] = v
+		}
+		for k, v := range p.labels {
+			labels[k] = v
+		}
+		metrics[i].Labels = labels
+	}
+	return metrics
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"argos/shared"
+)
+
+func newTestPipeline(t *testing.T, external map[string]string, rules []RelabelConfig) *labelPipeline {
+	if err := compileRelabelConfigs(rules); err != nil {
+		t.Fatalf("compileRelabelConfigs: %v", err)
+	}
+	return &labelPipeline{externalLabels: external, rules: rules}
+}
+
+func TestExternalLabelsDoNotOverride(t *testing.T) {
+	probeLabels := map[string]string{"url": "https://example.com", "env": "staging"}
+	metrics := []shared.Metric{
+		{Service: "web", Target: "site", Name: "http_up", Value: 1, Labels: probeLabels},
+		{Service: "web", Target: "site", Name: "http_latency_ms", Value: 10, Labels: probeLabels},
+	}
+
+	p := newTestPipeline(t, map[string]string{"env": "prod", "team": "sre"}, nil)
+	out := p.Process(metrics)
+
+	if out[0].Labels["env"] != "staging" {
+		t.Errorf("External label overrode existing label: %v", out[0].Labels)
+	}
+	if out[0].Labels["team"] != "sre" {
+		t.Errorf("Expected external label team=sre, got %v", out[0].Labels)
+	}
+	if _, leaked := probeLabels["team"]; leaked {
+		t.Error("Pipeline mutated the probe's shared label map")
+	}
+}
+
+func TestRelabelActions(t *testing.T) {
+	metrics := []shared.Metric{
+		{Service: "web", Target: "site", Name: "http_up", Labels: map[string]string{"url": "https://example.com:8443/health", "meta_zone": "a"}},
+		{Service: "web", Target: "site", Name: "http_errors_4xx", Labels: map[string]string{"url": "https://example.com"}},
+		{Service: "dns", Target: "resolver", Name: "dns_up", Labels: map[string]string{"fqdn": "example.com"}},
+	}
+
+	p := newTestPipeline(t, nil, []RelabelConfig{
+		{SourceLabels: []string{"__service__"}, Regex: "web|db", Action: "keep"},
+		{SourceLabels: []string{"__name__"}, Regex: "http_errors_.*", Action: "drop"},
+		{SourceLabels: []string{"url"}, Regex: `https?://([^/:]+).*`, TargetLabel: "host"},
+		{Regex: "meta_(.+)", Action: "labelmap"},
+		{Regex: "meta_.*|url", Action: "labeldrop"},
+		{SourceLabels: []string{"__name__"}, Regex: "http_(.*)", TargetLabel: "__name__", Replacement: "web_$1"},
+	})
+
+	out := p.Process(metrics)
+	if len(out) != 1 {
+		t.Fatalf("Expected 1 metric after keep/drop, got %d: %+v", len(out), out)
+	}
+
+	m := out[0]
+	if m.Name != "web_up" {
+		t.Errorf("Expected metric renamed to web_up, got %s", m.Name)
+	}
+	want := map[string]string{"host": "example.com", "zone": "a"}
+	if len(m.Labels) != len(want) {
+		t.Errorf("Expected labels %v, got %v", want, m.Labels)
+	}
+	for k, v := range want {
+		if m.Labels[k] != v {
+			t.Errorf("Expected %s=%s, got %q", k, v, m.Labels[k])
+		}
+	}
+}
+
+func TestRelabelConfigValidation(t *testing.T) {
+	tests := []RelabelConfig{
+		{Action: "replace"},
+		{Action: "keep"},
+		{Action: "explode", SourceLabels: []string{"a"}},
+		{Regex: "(", TargetLabel: "a"},
+	}
+	for _, rc := range tests {
+		if err := compileRelabelConfigs([]RelabelConfig{rc}); err == nil {
+			t.Errorf("Expected error for %+v", rc)
+		}
+	}
+}
+
+func TestTargetLabels(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: a
+push_endpoint: http://localhost/ingest
+targets:
+  - type: exec
+    name: ok
+    command: "true"
+    labels:
+      team: payments
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+
+	metrics := cfg.Targets[0].Probe().Collect(context.Background())
+	for _, m := range metrics {
+		if m.Labels["team"] != "payments" {
+			t.Errorf("Expected target label team=payments on %s, got %v", m.Name, m.Labels)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+
+	"argos/agent/probes"
+	"argos/shared"
+)
+
+// securityEvents recebe os eventos de segurança gerados pelos probes. O
+// runAgent o aponta para o Forwarder; em validate/check eles só vão para o log.
+var securityEvents = func(event shared.SecurityEvent) {
+	log.Printf("Security event (not sent): %s", event.Description)
+}
+
+// sshHostKeys guarda as chaves vistas pelos probes ssh sem fingerprints
+// fixadas. O runAgent o aponta para state_dir; sem ele, ficam em memória.
+var sshHostKeys probes.HostKeyStore
+
+func reportHostKeyChange(c probes.HostKeyChange) {
+	severity := "high"
+	if c.Pinned {
+		severity = "critical"
+	}
+
+	securityEvents(shared.SecurityEvent{
+		Type:        "ssh_hostkey_changed",
+		Severity:    severity,
+		Description: "SSH host key changed: " + c.String(),
+		Service:     "ssh",
+		Target:      c.Target,
+		IPAddress:   c.RemoteIP,
+		Metadata: map[string]interface{}{
+			"address":     c.Address,
+			"key_type":    c.KeyType,
+			"fingerprint": c.Fingerprint,
+			"expected":    c.Expected,
+			"pinned":      c.Pinned,
+		},
+	})
+}
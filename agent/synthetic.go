@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"argos/agent/probes"
+)
+
+var syntheticVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SyntheticStep é um passo da transação como escrito no config:
+//
+//	steps:
+//	  - name: login
+//	    method: POST
+//	    url: ${base}/login
+//	    extract:
+//	      - var: token
+//	        json: data.token
+//	    assert:
+//	      status: [200]
+type SyntheticStep struct {
+	Name    string             `yaml:"name"`
+	Method  string             `yaml:"method"`
+	URL     string             `yaml:"url"`
+	Headers map[string]string  `yaml:"headers"`
+	Body    string             `yaml:"body"`
+	Extract []SyntheticExtract `yaml:"extract"`
+	Assert  SyntheticAssert    `yaml:"assert"`
+}
+
+type SyntheticExtract struct {
+	Var    string `yaml:"var"`
+	JSON   string `yaml:"json"`
+	Header string `yaml:"header"`
+	Regex  string `yaml:"regex"`
+}
+
+type SyntheticAssert struct {
+	Status       []int             `yaml:"status"`
+	BodyContains string            `yaml:"body_contains"`
+	BodyRegex    string            `yaml:"body_regex"`
+	JSON         map[string]string `yaml:"json"`
+	MaxLatency   time.Duration     `yaml:"max_latency"`
+}
+
+// compileSyntheticSteps valida os passos e os converte para o probe. Variáveis
+// usadas precisam estar em variables ou ter sido extraídas por um passo anterior.
+func compileSyntheticSteps(steps []SyntheticStep, variables map[string]string) ([]probes.SyntheticStep, error) {
+	if len(steps) == 0 {
+		return nil, errors.New("at least one step is required")
+	}
+
+	defined := map[string]bool{}
+	for name := range variables {
+		defined[name] = true
+	}
+	names := map[string]bool{}
+	compiled := make([]probes.SyntheticStep, 0, len(steps))
+
+	for i, s := range steps {
+		where := fmt.Sprintf("steps[%d]", i)
+		if s.Name == "" {
+			return nil, fmt.Errorf("%s: name is required", where)
+		}
+		where = fmt.Sprintf("step %q", s.Name)
+		if names[s.Name] {
+			return nil, fmt.Errorf("%s: duplicate step name", where)
+		}
+		names[s.Name] = true
+
+		if s.URL == "" {
+			return nil, fmt.Errorf("%s: url is required", where)
+		}
+		// URLs com variáveis só podem ser verificadas na execução
+		if len(probes.SyntheticVars(s.URL)) == 0 {
+			if err := validateURL("url", s.URL, "http", "https"); err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+		}
+
+		used := probes.SyntheticVars(s.URL + s.Body)
+		for _, v := range s.Headers {
+			used = append(used, probes.SyntheticVars(v)...)
+		}
+		for _, name := range used {
+			if !defined[name] {
+				return nil, fmt.Errorf("%s: variable ${%s} is not defined by variables or a previous step", where, name)
+			}
+		}
+
+		step := probes.SyntheticStep{
+			Name:    s.Name,
+			Method:  strings.ToUpper(s.Method),
+			URL:     s.URL,
+			Headers: s.Headers,
+			Body:    s.Body,
+		}
+
+		assert, err := s.Assert.compile()
+		if err != nil {
+			return nil, fmt.Errorf("%s: assert: %w", where, err)
+		}
+		step.Assert = assert
+
+		for j, e := range s.Extract {
+			extract, err := e.compile()
+			if err != nil {
+				return nil, fmt.Errorf("%s: extract[%d]: %w", where, j, err)
+			}
+			step.Extract = append(step.Extract, extract)
+			defined[e.Var] = true
+		}
+
+		compiled = append(compiled, step)
+	}
+
+	return compiled, nil
+}
+
+func (e SyntheticExtract) compile() (probes.SyntheticExtract, error) {
+	out := probes.SyntheticExtract{Var: e.Var, JSONPath: e.JSON, Header: e.Header}
+	if !syntheticVarName.MatchString(e.Var) {
+		return out, fmt.Errorf("var %q: must match %s", e.Var, syntheticVarName)
+	}
+
+	sources := 0
+	for _, s := range []string{e.JSON, e.Header, e.Regex} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return out, errors.New("exactly one of json, header or regex is required")
+	}
+
+	if e.Regex != "" {
+		re, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return out, fmt.Errorf("regex %q: %v", e.Regex, err)
+		}
+		out.Regex = re
+	}
+	return out, nil
+}
+
+func (a SyntheticAssert) compile() (probes.SyntheticAssert, error) {
+	out := probes.SyntheticAssert{
+		Status:       a.Status,
+		BodyContains: a.BodyContains,
+		JSON:         a.JSON,
+		MaxLatency:   a.MaxLatency,
+	}
+
+	for _, code := range a.Status {
+		if code < 100 || code > 599 {
+			return out, fmt.Errorf("status %d: invalid HTTP status", code)
+		}
+	}
+	if a.BodyRegex != "" {
+		re, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return out, fmt.Errorf("body_regex %q: %v", a.BodyRegex, err)
+		}
+		out.BodyRegex = re
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigSyntheticTarget(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agent_id: agent-test
+push_endpoint: http://localhost:8081/ingest
+targets:
+  - type: synthetic
+    name: crud
+    variables:
+      base: https://api.example.com
+    steps:
+      - name: login
+        method: post
+        url: ${base}/login
+        extract:
+          - var: token
+            json: data.token
+      - name: read
+        url: ${base}/items
+        headers:
+          Authorization: Bearer ${token}
+        assert:
+          status: [200, 304]
+          json:
+            healthy: true
+            count: 3
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	target := cfg.Targets[0].Config().(*SyntheticTarget)
+	if len(target.steps) != 2 || target.steps[0].Method != "POST" || target.steps[1].Method != "GET" {
+		t.Fatalf("Unexpected compiled steps: %+v", target.steps)
+	}
+	if got := target.steps[1].Assert.JSON; got["healthy"] != "true" || got["count"] != "3" {
+		t.Errorf("Expected scalar JSON assertions as strings, got %v", got)
+	}
+}
+
+func TestParseConfigSyntheticErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps string
+		want  string
+	}{
+		{"no steps", "    steps: []\n", "at least one step"},
+		{"undefined variable", "    steps:\n      - name: a\n        url: ${base}/x\n", "variable ${base} is not defined"},
+		{"variable from later step", "    steps:\n      - name: a\n        url: http://a/${id}\n      - name: b\n        url: http://a\n        extract:\n          - var: id\n            header: X-Id\n", "variable ${id}"},
+		{"two extract sources", "    steps:\n      - name: a\n        url: http://a\n        extract:\n          - var: id\n            json: id\n            header: X-Id\n", "exactly one of"},
+		{"bad regex", "    steps:\n      - name: a\n        url: http://a\n        assert:\n          body_regex: '('\n", "body_regex"},
+		{"duplicate step", "    steps:\n      - name: a\n        url: http://a\n      - name: a\n        url: http://b\n", "duplicate step name"},
+	}
+
+	for _, tt := range tests {
+		data := "agent_id: a\npush_endpoint: http://localhost/ingest\ntargets:\n  - type: synthetic\n    name: s\n" + tt.steps
+		_, err := ParseConfig([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+// agentUpMetric é a série sintética derivada do inventário: 1 se o agente
+// foi visto nos últimos agentDownAfter, 0 caso contrário
+const agentUpMetric = "agent_up"
+
+// agentDownAfter é configurável por AGENT_DOWN_AFTER
+var agentDownAfter = 2 * time.Minute
+
+func agentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if id := strings.TrimPrefix(r.URL.Path, "/api/agents/"); id != r.URL.Path && id != "" {
+		getAgentHandler(w, id)
+	} else {
+		listAgentsHandler(w)
+	}
+}
+
+// agentActionHandler atende o POST de /api/agents/register e
+// /api/agents/heartbeat e repassa os demais métodos ao agentsHandler, para
+// que GET /api/agents/<id> funcione também para esses ids
+func agentActionHandler(post http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			agentsHandler(w, r)
+			return
+		}
+		post(w, r)
+	}
+}
+
+func listAgentsHandler(w http.ResponseWriter) {
+	agents, err := storage.ListAgents()
+	if err != nil {
+		log.Printf("Error listing agents: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	up := 0
+	for i := range agents {
+		agents[i].Up = agentIsUp(&agents[i], now)
+		if agents[i].Up {
+			up++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"agents": agents,
+		"count":  len(agents),
+		"up":     up,
+	})
+}
+
+func getAgentHandler(w http.ResponseWriter, agentID string) {
+	agent, err := storage.GetAgent(agentID)
+	if err != nil {
+		log.Printf("Error getting agent: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if agent == nil {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	agent.Up = agentIsUp(agent, time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agent)
+}
+
+// registerAgentHandler grava o inventário enviado pelo agente ao iniciar
+func registerAgentHandler(w http.ResponseWriter, r *http.Request) {
+	hb, ok := decodeHeartbeat(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	agent := Agent{
+		AgentID:       hb.AgentID,
+		Version:       hb.Version,
+		Hostname:      hb.Hostname,
+		Probes:        hb.Probes,
+		LastSeenAt:    now,
+		ClockSkewMS:   clockSkew(now, hb.TS),
+		ClockOffsetMS: hb.ClockOffsetMS,
+	}
+	if !hb.StartedAt.IsZero() {
+		agent.StartedAt = &hb.StartedAt
+	}
+
+	if err := storage.RegisterAgent(&agent); err != nil {
+		log.Printf("Error registering agent: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	agent.Up = true
+
+	log.Printf("Registered agent %s (version %s, %d probes)", agent.AgentID, agent.Version, len(agent.Probes))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agent)
+}
+
+func agentHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	hb, ok := decodeHeartbeat(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := storage.TouchAgent(hb.AgentID, now, clockSkew(now, hb.TS), hb.ClockOffsetMS); err != nil {
+		log.Printf("Error recording heartbeat: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// decodeHeartbeat autentica o agente como na ingestão e valida o corpo
+func decodeHeartbeat(w http.ResponseWriter, r *http.Request) (*shared.AgentHeartbeat, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	creds, ok := authenticateIngest(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes)
+	var hb shared.AgentHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Body exceeds %d bytes", limits.MaxBodyBytes), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if hb.AgentID == "" {
+		http.Error(w, "agent_id is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if creds != nil && !creds.allows(hb.AgentID) {
+		log.Printf("Rejected heartbeat: %s credentials for %v used with agent_id %q", creds.source, creds.agentIDs, hb.AgentID)
+		http.Error(w, "agent_id does not match credentials", http.StatusForbidden)
+		return nil, false
+	}
+
+	return &hb, true
+}
+
+// touchAgentOnIngest conta um lote recebido como sinal de vida. O skew
+// compara received, a chegada da requisição, com o SentAt do lote; os TS das
+// amostras incluem a coleta e a fila do agente. Sem SentAt (agentes
+// antigos), o skew não é atualizado.
+func touchAgentOnIngest(batch shared.Batch, received time.Time) {
+	if batch.AgentID == "" {
+		return
+	}
+
+	if err := storage.TouchAgent(batch.AgentID, time.Now(), clockSkew(received, batch.SentAt), nil); err != nil {
+		log.Printf("Failed to update agent %s: %v", batch.AgentID, err)
+	}
+}
+
+// clockSkew retorna received - sent em ms, ou nil se o agente não enviou TS
+func clockSkew(received, sent time.Time) *float64 {
+	if sent.IsZero() {
+		return nil
+	}
+	skew := received.Sub(sent).Seconds() * 1000
+	return &skew
+}
+
+func agentIsUp(agent *Agent, now time.Time) bool {
+	return now.Sub(agent.LastSeenAt) <= agentDownAfter
+}
+
+func agentUpSeries(agent *Agent, now time.Time) shared.Metric {
+	value := 0.0
+	if agentIsUp(agent, now) {
+		value = 1
+	}
+	return shared.Metric{
+		Service: "agent",
+		Target:  agent.AgentID,
+		Name:    agentUpMetric,
+		Value:   value,
+		Labels:  map[string]string{"version": agent.Version, "hostname": agent.Hostname},
+		TS:      now,
+	}
+}
+
+func agentUpMetrics(matchers []LabelMatcher, now time.Time) ([]shared.Metric, error) {
+	agents, err := storage.ListAgents()
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []shared.Metric
+	for i := range agents {
+		if m := agentUpSeries(&agents[i], now); matchesAll(matchers, m.Labels) {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+// queryAgentUp responde /api/metrics/query?name=agent_up. Sem target, retorna
+// a série do primeiro agente fora do ar (ou de um qualquer se todos estiverem
+// no ar), para que uma única regra cubra todos os agentes.
+func queryAgentUp(service, target string, matchers []LabelMatcher, now time.Time) (*shared.Metric, error) {
+	if service != "" && service != "agent" {
+		return nil, nil
+	}
+
+	if target != "" {
+		agent, err := storage.GetAgent(target)
+		if err != nil || agent == nil {
+			return nil, err
+		}
+		m := agentUpSeries(agent, now)
+		if !matchesAll(matchers, m.Labels) {
+			return nil, nil
+		}
+		return &m, nil
+	}
+
+	metrics, err := agentUpMetrics(matchers, now)
+	if err != nil || len(metrics) == 0 {
+		return nil, err
+	}
+	lowest := metrics[0]
+	for _, m := range metrics[1:] {
+		if m.Value < lowest.Value {
+			lowest = m
+		}
+	}
+	return &lowest, nil
+}
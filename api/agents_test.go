@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+func heartbeatRequest(path string, hb shared.AgentHeartbeat, token string) *http.Request {
+	body, _ := json.Marshal(hb)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestRegisterAndListAgents(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	hb := shared.AgentHeartbeat{
+		AgentID:   "agent-01",
+		Version:   "1.4.0",
+		Hostname:  "web-01",
+		Probes:    []shared.AgentProbe{{Type: "http", Name: "site"}},
+		StartedAt: time.Now().Add(-time.Minute),
+		TS:        time.Now().Add(-2 * time.Second),
+	}
+	w := httptest.NewRecorder()
+	registerAgentHandler(w, heartbeatRequest("/api/agents/register", hb, ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	agentsHandler(w, httptest.NewRequest("GET", "/api/agents", nil))
+
+	var list struct {
+		Agents []Agent `json:"agents"`
+		Up     int     `json:"up"`
+	}
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list.Agents) != 1 || list.Up != 1 {
+		t.Fatalf("Expected one agent up, got %+v", list)
+	}
+
+	a := list.Agents[0]
+	if a.Version != "1.4.0" || a.Hostname != "web-01" || len(a.Probes) != 1 || !a.Up {
+		t.Errorf("Unexpected agent %+v", a)
+	}
+	if a.ClockSkewMS == nil || *a.ClockSkewMS < 1900 || *a.ClockSkewMS > 3000 {
+		t.Errorf("Expected clock skew ~2000ms, got %v", a.ClockSkewMS)
+	}
+
+	w = httptest.NewRecorder()
+	agentsHandler(w, httptest.NewRequest("GET", "/api/agents/agent-01", nil))
+	var detail Agent
+	json.NewDecoder(w.Body).Decode(&detail)
+	if w.Code != http.StatusOK || detail.AgentID != "agent-01" {
+		t.Errorf("Expected agent detail, got %d %+v", w.Code, detail)
+	}
+
+	w = httptest.NewRecorder()
+	agentsHandler(w, httptest.NewRequest("GET", "/api/agents/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown agent, got %d", w.Code)
+	}
+}
+
+func TestAgentHeartbeatRequiresMatchingCredentials(t *testing.T) {
+	withIngestAuth(t)
+	mock := &mockStorage{}
+	storage = mock
+
+	token, _ := generateAgentToken()
+	mock.CreateAgentToken(&AgentToken{AgentID: "agent-01"}, hashAgentToken(token))
+
+	w := httptest.NewRecorder()
+	agentHeartbeatHandler(w, heartbeatRequest("/api/agents/heartbeat", shared.AgentHeartbeat{AgentID: "agent-02", TS: time.Now()}, token))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	agentHeartbeatHandler(w, heartbeatRequest("/api/agents/heartbeat", shared.AgentHeartbeat{AgentID: "agent-01", TS: time.Now()}, token))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if _, ok := mock.agents["agent-01"]; !ok {
+		t.Error("Expected heartbeat to create the agent")
+	}
+}
+
+func TestRegisterAgentBodyLimit(t *testing.T) {
+	storage = &mockStorage{}
+	defer func(l ingestLimits) { limits = l }(limits)
+	limits.MaxBodyBytes = 64
+
+	hb := shared.AgentHeartbeat{AgentID: "agent-01", Hostname: strings.Repeat("x", 100), TS: time.Now()}
+	w := httptest.NewRecorder()
+	registerAgentHandler(w, heartbeatRequest("/api/agents/register", hb, ""))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+}
+
+func TestIngestMarksAgentSeen(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, ingestRequest("agent-01", ""))
+
+	a := mock.agents["agent-01"]
+	if a == nil || time.Since(a.LastSeenAt) > time.Second {
+		t.Fatalf("Expected ingest to update agent last seen, got %+v", a)
+	}
+	if a.ClockSkewMS != nil {
+		t.Errorf("Expected no skew without sent_at, got %v", *a.ClockSkewMS)
+	}
+
+	// Amostras que esperaram na fila não contam como skew; só o sent_at
+	batch := shared.Batch{
+		AgentID: "agent-01",
+		Items:   []shared.Metric{{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: time.Now().Add(-10 * time.Minute)}},
+		SentAt:  time.Now().Add(-2 * time.Second),
+	}
+	body, _ := json.Marshal(batch)
+	w = httptest.NewRecorder()
+	ingestHandler(w, httptest.NewRequest("POST", "/ingest", bytes.NewReader(body)))
+	if a := mock.agents["agent-01"]; a.ClockSkewMS == nil || *a.ClockSkewMS < 1900 || *a.ClockSkewMS > 3000 {
+		t.Errorf("Expected clock skew ~2000ms from sent_at, got %v", a.ClockSkewMS)
+	}
+}
+
+func TestAgentActionPathsServeAgentDetail(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	w := httptest.NewRecorder()
+	registerAgentHandler(w, heartbeatRequest("/api/agents/register", shared.AgentHeartbeat{AgentID: "register", TS: time.Now()}, ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	agentActionHandler(registerAgentHandler)(w, httptest.NewRequest("GET", "/api/agents/register", nil))
+	var detail Agent
+	json.NewDecoder(w.Body).Decode(&detail)
+	if w.Code != http.StatusOK || detail.AgentID != "register" {
+		t.Errorf("Expected detail of agent \"register\", got %d %+v", w.Code, detail)
+	}
+}
+
+func TestQueryAgentUp(t *testing.T) {
+	now := time.Now()
+	mock := &mockStorage{agents: map[string]*Agent{
+		"agent-01": {AgentID: "agent-01", LastSeenAt: now},
+		"agent-02": {AgentID: "agent-02", LastSeenAt: now.Add(-agentDownAfter - time.Minute)},
+	}}
+	storage = mock
+
+	query := func(params string) (int, shared.Metric) {
+		w := httptest.NewRecorder()
+		queryHandler(w, httptest.NewRequest("GET", "/api/metrics/query?name=agent_up"+params, nil))
+		var m shared.Metric
+		json.NewDecoder(w.Body).Decode(&m)
+		return w.Code, m
+	}
+
+	if _, m := query("&target=agent-01"); m.Value != 1 {
+		t.Errorf("Expected agent_up=1 for agent-01, got %v", m.Value)
+	}
+	if _, m := query("&target=agent-02"); m.Value != 0 {
+		t.Errorf("Expected agent_up=0 for stale agent-02, got %v", m.Value)
+	}
+	if _, m := query(""); m.Value != 0 || m.Target != "agent-02" {
+		t.Errorf("Expected query without target to return the down agent, got %+v", m)
+	}
+	if code, _ := query("&service=web"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for agent_up outside the agent service, got %d", code)
+	}
+}
@@ -59,18 +59,41 @@ func (m *mockStorageWithAlertRules) DeleteAlertRule(id int) error {
 func (m *mockStorageWithAlertRules) InsertMetrics(agentID string, metrics []shared.Metric) error {
 	return nil
 }
-func (m *mockStorageWithAlertRules) QueryLatest(name, service, target string) (*shared.Metric, error) {
+func (m *mockStorageWithAlertRules) InsertBatches(batches []shared.Batch) error {
+	return nil
+}
+func (m *mockStorageWithAlertRules) QueryLatest(name, service, target string, matchers []LabelMatcher) (*shared.Metric, error) {
+	return nil, nil
+}
+func (m *mockStorageWithAlertRules) QueryRange(q RangeQuery) (*RangeResult, error) {
 	return nil, nil
 }
-func (m *mockStorageWithAlertRules) QueryRange(name, service, target string, start, end time.Time, step string) ([]shared.DataPoint, error) {
+func (m *mockStorageWithAlertRules) SelectSeries(q SeriesQuery) ([]shared.Metric, error) {
 	return nil, nil
 }
+func (m *mockStorageWithAlertRules) Rollup(tier rollupTier, upTo time.Time) (time.Time, error) {
+	return upTo, nil
+}
+func (m *mockStorageWithAlertRules) RollupRange(tier rollupTier, from, to time.Time) error {
+	return nil
+}
+
+func (m *mockStorageWithAlertRules) PruneRollups(tier rollupTier, before time.Time) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorageWithAlertRules) BackfillMetricsPartitions(since time.Time) error { return nil }
+func (m *mockStorageWithAlertRules) ListMetricsPartitions() ([]time.Time, error)     { return nil, nil }
+func (m *mockStorageWithAlertRules) CreateMetricsPartition(day time.Time) error      { return nil }
+func (m *mockStorageWithAlertRules) DropMetricsPartition(day time.Time) error        { return nil }
+func (m *mockStorageWithAlertRules) PruneDefaultPartition(before time.Time) (int64, error) {
+	return 0, nil
+}
 func (m *mockStorageWithAlertRules) ListServices() ([]string, error)              { return nil, nil }
 func (m *mockStorageWithAlertRules) ListTargets(service string) ([]string, error) { return nil, nil }
 func (m *mockStorageWithAlertRules) GetMetricsCount() (int64, error)              { return 0, nil }
 func (m *mockStorageWithAlertRules) GetLastIngestTime() (time.Time, error)        { return time.Time{}, nil }
 func (m *mockStorageWithAlertRules) GetActiveAlerts() ([]shared.Alert, error)     { return nil, nil }
-func (m *mockStorageWithAlertRules) GetLatestMetrics() ([]shared.Metric, error)   { return nil, nil }
+func (m *mockStorageWithAlertRules) GetLatestMetrics(matchers []LabelMatcher) ([]shared.Metric, error) { return nil, nil }
 func (m *mockStorageWithAlertRules) GetSecurityEvents(limit int) ([]SecurityEvent, error) { return nil, nil }
 func (m *mockStorageWithAlertRules) CreateSecurityEvent(event *SecurityEvent) error { return nil }
 func (m *mockStorageWithAlertRules) GetFailedLoginsByIP(limit int) ([]struct {
@@ -83,6 +106,16 @@ func (m *mockStorageWithAlertRules) GetConfigChanges(limit int) ([]ConfigChange,
 func (m *mockStorageWithAlertRules) RecordConfigChange(change *ConfigChange) error { return nil }
 func (m *mockStorageWithAlertRules) GetVulnerabilities() ([]Vulnerability, error) { return nil, nil }
 func (m *mockStorageWithAlertRules) GetTrafficAnomalies(limit int) (int64, error) { return 0, nil }
+func (m *mockStorageWithAlertRules) AuthenticateAgentToken(tokenHash string) (string, error) { return "", nil }
+func (m *mockStorageWithAlertRules) CreateAgentToken(token *AgentToken, tokenHash string) error { return nil }
+func (m *mockStorageWithAlertRules) ListAgentTokens() ([]AgentToken, error) { return nil, nil }
+func (m *mockStorageWithAlertRules) RevokeAgentToken(id int) error { return nil }
+func (m *mockStorageWithAlertRules) RegisterAgent(agent *Agent) error { return nil }
+func (m *mockStorageWithAlertRules) TouchAgent(agentID string, seenAt time.Time, clockSkewMS, clockOffsetMS *float64) error {
+	return nil
+}
+func (m *mockStorageWithAlertRules) ListAgents() ([]Agent, error)                { return nil, nil }
+func (m *mockStorageWithAlertRules) GetAgent(agentID string) (*Agent, error) { return nil, nil }
 func (m *mockStorageWithAlertRules) Close() error                                 { return nil }
 
 func TestListAlertRules(t *testing.T) {
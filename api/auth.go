@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+const agentTokenPrefix = "argos_"
+
+var (
+	// ingestAuthRequired é definido em main a partir de INGEST_AUTH
+	ingestAuthRequired bool
+	// adminToken protege o gerenciamento de tokens (ADMIN_TOKEN)
+	adminToken string
+
+	errMissingCredentials = errors.New("missing credentials: bearer token or client certificate required")
+	errInvalidToken       = errors.New("invalid or revoked token")
+)
+
+// agentCredentials são as identidades autorizadas pelas credenciais da requisição
+type agentCredentials struct {
+	agentIDs []string
+	source   string
+}
+
+func (c *agentCredentials) allows(agentID string) bool {
+	for _, id := range c.agentIDs {
+		if id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+type AgentToken struct {
+	ID          int        `json:"id"`
+	AgentID     string     `json:"agent_id"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+func generateAgentToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return agentTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// authenticateAgent identifica o agente pelo certificado de cliente verificado
+// (CN e SANs argos://agent/<id>) e/ou pelo bearer token. Se ambos forem apresentados, o agente
+// do token precisa constar no certificado.
+func authenticateAgent(r *http.Request) (*agentCredentials, error) {
+	var creds *agentCredentials
+	if cert := verifiedClientCertificate(r); cert != nil {
+		creds = &agentCredentials{agentIDs: shared.CertificateIdentities(cert), source: "certificate"}
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		if creds == nil {
+			return nil, errMissingCredentials
+		}
+		return creds, nil
+	}
+
+	agentID, err := storage.AuthenticateAgentToken(hashAgentToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if agentID == "" {
+		return nil, errInvalidToken
+	}
+	if creds != nil && !creds.allows(agentID) {
+		return nil, fmt.Errorf("%w: token agent %s not in client certificate", errInvalidToken, agentID)
+	}
+
+	return &agentCredentials{agentIDs: []string{agentID}, source: "token"}, nil
+}
+
+// authenticateIngest autentica as chamadas dos agentes (ingestão, registro e
+// heartbeat) antes de decodificar o corpo. Credenciais apresentadas são
+// sempre verificadas, mesmo com INGEST_AUTH=disabled. Em caso de falha a
+// resposta já foi escrita.
+func authenticateIngest(w http.ResponseWriter, r *http.Request) (*agentCredentials, bool) {
+	creds, err := authenticateAgent(r)
+	switch {
+	case err == errMissingCredentials && !ingestAuthRequired:
+	case errors.Is(err, errMissingCredentials), errors.Is(err, errInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="argos-ingest"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	case err != nil:
+		log.Printf("Failed to authenticate agent: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return creds, true
+}
+
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		http.Error(w, "Token management disabled: ADMIN_TOKEN not set", http.StatusForbidden)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="argos-admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// agentTokensHandler gerencia os tokens de ingestão dos agentes
+func agentTokensHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		listAgentTokensHandler(w, r)
+	case "POST":
+		createAgentTokenHandler(w, r)
+	case "DELETE":
+		revokeAgentTokenHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listAgentTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := storage.ListAgentTokens()
+	if err != nil {
+		log.Printf("Error listing agent tokens: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tokens": tokens,
+		"count":  len(tokens),
+	})
+}
+
+func createAgentTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AgentID     string `json:"agent_id"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.AgentID == "" {
+		http.Error(w, "agent_id is required", http.StatusBadRequest)
+		return
+	}
+
+	token, err := generateAgentToken()
+	if err != nil {
+		log.Printf("Error generating agent token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	record := AgentToken{AgentID: req.AgentID, Description: req.Description}
+	if err := storage.CreateAgentToken(&record, hashAgentToken(token)); err != nil {
+		log.Printf("Error creating agent token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Created agent token %d for agent %s", record.ID, record.AgentID)
+
+	// O token em texto puro só é exibido nesta resposta
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":       token,
+		"agent_token": record,
+	})
+}
+
+func revokeAgentTokenHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 4 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.RevokeAgentToken(id); err != nil {
+		log.Printf("Error revoking agent token: %v", err)
+		if errors.Is(err, errAgentTokenNotFound) {
+			http.Error(w, "Agent token not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Revoked agent token ID: %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
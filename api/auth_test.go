@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+func withIngestAuth(t *testing.T) {
+	ingestAuthRequired = true
+	t.Cleanup(func() { ingestAuthRequired = false })
+}
+
+func ingestRequest(agentID, token string) *http.Request {
+	batch := shared.Batch{
+		AgentID: agentID,
+		Items: []shared.Metric{
+			{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: time.Now()},
+		},
+	}
+	body, _ := json.Marshal(batch)
+	req := httptest.NewRequest("POST", "/ingest", bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestIngestAuthMissingToken(t *testing.T) {
+	withIngestAuth(t)
+	mock := &mockStorage{}
+	storage = mock
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, ingestRequest("agent-01", ""))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+	if len(mock.metrics) != 0 {
+		t.Error("Expected no metrics stored for unauthenticated batch")
+	}
+}
+
+func TestIngestAuthValidToken(t *testing.T) {
+	withIngestAuth(t)
+	mock := &mockStorage{}
+	storage = mock
+
+	token, _ := generateAgentToken()
+	mock.CreateAgentToken(&AgentToken{AgentID: "agent-01"}, hashAgentToken(token))
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, ingestRequest("agent-01", token))
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", w.Code)
+	}
+	if len(mock.metrics) != 1 {
+		t.Errorf("Expected 1 metric stored, got %d", len(mock.metrics))
+	}
+}
+
+func TestIngestAuthMismatchedAgent(t *testing.T) {
+	withIngestAuth(t)
+	mock := &mockStorage{}
+	storage = mock
+
+	token, _ := generateAgentToken()
+	mock.CreateAgentToken(&AgentToken{AgentID: "agent-01"}, hashAgentToken(token))
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, ingestRequest("agent-02", token))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestIngestAuthRevokedToken(t *testing.T) {
+	withIngestAuth(t)
+	mock := &mockStorage{}
+	storage = mock
+
+	token, _ := generateAgentToken()
+	record := AgentToken{AgentID: "agent-01"}
+	mock.CreateAgentToken(&record, hashAgentToken(token))
+	mock.RevokeAgentToken(record.ID)
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, ingestRequest("agent-01", token))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestRevokeAgentTokenNotFound(t *testing.T) {
+	storage = &mockStorage{}
+
+	w := httptest.NewRecorder()
+	revokeAgentTokenHandler(w, httptest.NewRequest("DELETE", "/api/agent-tokens/42", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestAgentTokensRequireAdmin(t *testing.T) {
+	storage = &mockStorage{}
+	adminToken = "secret"
+	defer func() { adminToken = "" }()
+
+	req := httptest.NewRequest("GET", "/api/agent-tokens", nil)
+	w := httptest.NewRecorder()
+	agentTokensHandler(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestCreateAgentToken(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+	adminToken = "secret"
+	defer func() { adminToken = "" }()
+
+	body := bytes.NewReader([]byte(`{"agent_id":"agent-01","description":"datacenter A"}`))
+	req := httptest.NewRequest("POST", "/api/agent-tokens", body)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	agentTokensHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if len(mock.tokens) != 1 {
+		t.Fatalf("Expected 1 token stored, got %d", len(mock.tokens))
+	}
+	if mock.tokens[0].hash == response.Token {
+		t.Error("Token must not be stored in plain text")
+	}
+	if mock.tokens[0].hash != hashAgentToken(response.Token) {
+		t.Error("Stored hash does not match returned token")
+	}
+}
+
+func withClientCert(req *http.Request, cn string, sans ...string) *http.Request {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	for _, san := range sans {
+		if u, err := url.Parse(san); err == nil && u.Scheme != "" {
+			cert.URIs = append(cert.URIs, u)
+		} else {
+			cert.DNSNames = append(cert.DNSNames, san)
+		}
+	}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return req
+}
+
+func TestIngestAuthClientCertificate(t *testing.T) {
+	withIngestAuth(t)
+	mock := &mockStorage{}
+	storage = mock
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, withClientCert(ingestRequest("agent-01", ""), "agent-01"))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 for matching CN, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ingestHandler(w, withClientCert(ingestRequest("agent-02", ""), "gateway", "agent-02.dc1"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for agent_id outside CN/SAN, got %d", w.Code)
+	}
+
+	// SANs DNS não identificam agentes
+	w = httptest.NewRecorder()
+	ingestHandler(w, withClientCert(ingestRequest("agent-02.dc1", ""), "gateway", "agent-02.dc1"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for DNS SAN, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ingestHandler(w, withClientCert(ingestRequest("agent-02", ""), "gateway", "argos://agent/agent-02"))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 for matching URI SAN, got %d", w.Code)
+	}
+}
+
+func TestIngestClientCertificateEnforcedWhenAuthDisabled(t *testing.T) {
+	storage = &mockStorage{}
+
+	w := httptest.NewRecorder()
+	ingestHandler(w, withClientCert(ingestRequest("agent-02", ""), "agent-01"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestServerTLSConfigValidation(t *testing.T) {
+	if _, err := serverTLSConfig("missing.crt", "missing.key", "", ""); err == nil {
+		t.Error("Expected error for missing certificate files")
+	}
+}
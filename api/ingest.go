@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"argos/shared"
+)
+
+// errIngestBufferFull indica que o lote deve ser reenviado mais tarde
+var errIngestBufferFull = errors.New("ingest buffer full")
+
+const (
+	// ingestFlushSamples antecipa a gravação quando há amostras suficientes
+	ingestFlushSamples = 5000
+	// ingestRetryAfter é o Retry-After sugerido quando o buffer está cheio
+	ingestRetryAfter = time.Second
+	// ingestEWMAWeight é o peso da última medida nas médias de latência
+	ingestEWMAWeight = 0.2
+)
+
+type pendingBatch struct {
+	batch    shared.Batch
+	received time.Time
+	done     chan error
+}
+
+// ingestBuffer junta lotes que chegam em paralelo e os grava num único
+// InsertBatches. Cada requisição espera a gravação do seu lote, então o
+// agente só descarta o que já está no banco. Capacity limita as amostras
+// aguardando ou em gravação; acima dela, Submit recusa o lote.
+type ingestBuffer struct {
+	capacity      int
+	flushInterval time.Duration
+
+	start sync.Once
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	pending []*pendingBatch
+	// queued conta as amostras em pending e na gravação em andamento
+	queued      int
+	stats       shared.IngestStats
+	windowStart time.Time
+	windowCount int64
+}
+
+// ingest é configurável por INGEST_BUFFER_SIZE e INGEST_FLUSH_INTERVAL
+var ingest = newIngestBuffer(50000, 100*time.Millisecond)
+
+func newIngestBuffer(capacity int, flushInterval time.Duration) *ingestBuffer {
+	return &ingestBuffer{
+		capacity:      capacity,
+		flushInterval: flushInterval,
+		wake:          make(chan struct{}, 1),
+		windowStart:   time.Now(),
+	}
+}
+
+// Submit enfileira o lote e espera sua gravação
+func (b *ingestBuffer) Submit(ctx context.Context, batch shared.Batch) error {
+	b.start.Do(func() { go b.run() })
+
+	n := len(batch.Items)
+	p := &pendingBatch{batch: batch, received: time.Now(), done: make(chan error, 1)}
+
+	b.mu.Lock()
+	if b.queued+n > b.capacity {
+		b.stats.ThrottledBatches++
+		b.mu.Unlock()
+		return errIngestBufferFull
+	}
+	b.queued += n
+	b.pending = append(b.pending, p)
+	pendingSamples := 0
+	for _, q := range b.pending {
+		pendingSamples += len(q.batch.Items)
+	}
+	b.mu.Unlock()
+
+	if pendingSamples >= ingestFlushSamples {
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Requisição cancelada: retira o lote se ainda não foi gravado. Se a
+	// gravação já começou, o resultado vale, senão o agente reenviaria um
+	// lote já gravado.
+	if b.withdraw(p) {
+		return ctx.Err()
+	}
+	return <-p.done
+}
+
+// withdraw remove p de pending e diz se conseguiu
+func (b *ingestBuffer) withdraw(p *pendingBatch) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, q := range b.pending {
+		if q == p {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			b.queued -= len(p.batch.Items)
+			return true
+		}
+	}
+	return false
+}
+
+func (b *ingestBuffer) run() {
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.wake:
+		case <-ticker.C:
+		}
+		b.flush()
+	}
+}
+
+func (b *ingestBuffer) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	batches := make([]shared.Batch, len(pending))
+	samples := 0
+	for i, p := range pending {
+		batches[i] = p.batch
+		samples += len(p.batch.Items)
+	}
+
+	start := time.Now()
+	errs := make([]error, len(pending))
+	if err := storage.InsertBatches(batches); err != nil && len(pending) > 1 {
+		// Uma amostra recusada pelo banco derruba o COPY inteiro; grava cada
+		// lote sozinho para que só o culpado receba o erro
+		for i := range batches {
+			errs[i] = storage.InsertBatches(batches[i : i+1])
+		}
+	} else {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	now := time.Now()
+
+	b.mu.Lock()
+	b.queued -= samples
+	b.stats.Flushes++
+	b.stats.FlushLatencyMS = ewma(b.stats.FlushLatencyMS, float64(now.Sub(start).Microseconds())/1000)
+	written := 0
+	for i, p := range pending {
+		if errs[i] != nil {
+			b.stats.FailedBatches++
+			continue
+		}
+		n := len(p.batch.Items)
+		written += n
+		b.stats.AcceptedBatches++
+		b.stats.AcceptedSamples += int64(n)
+		b.stats.IngestLatencyMS = ewma(b.stats.IngestLatencyMS, float64(now.Sub(p.received).Microseconds())/1000)
+	}
+	if written > 0 {
+		b.recordThroughput(written, now)
+	}
+	b.mu.Unlock()
+
+	for i, p := range pending {
+		if errs[i] == nil {
+			lateRollups.mark(p.batch.Items, now)
+		}
+		p.done <- errs[i]
+	}
+}
+
+// recordThroughput mede amostras por segundo em janelas de um minuto
+func (b *ingestBuffer) recordThroughput(samples int, now time.Time) {
+	if elapsed := now.Sub(b.windowStart); elapsed >= time.Minute {
+		b.stats.SamplesPerSecond = float64(b.windowCount) / elapsed.Seconds()
+		b.windowStart = now
+		b.windowCount = 0
+	}
+	b.windowCount += int64(samples)
+}
+
+func ewma(current, sample float64) float64 {
+	if current == 0 {
+		return sample
+	}
+	return current + ingestEWMAWeight*(sample-current)
+}
+
+// Stats retorna uma cópia dos contadores, com a ocupação atual
+func (b *ingestBuffer) Stats() shared.IngestStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	stats.QueuedSamples = b.queued
+	stats.BufferCapacity = b.capacity
+	return stats
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+// countingStorage conta as chamadas de InsertBatches
+type countingStorage struct {
+	mockStorage
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *countingStorage) InsertBatches(batches []shared.Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	return m.mockStorage.InsertBatches(batches)
+}
+
+func testBatch(agentID string, n int) shared.Batch {
+	batch := shared.Batch{AgentID: agentID}
+	for i := 0; i < n; i++ {
+		batch.Items = append(batch.Items, shared.Metric{Service: "web", Target: "site", Name: "up", Value: 1, TS: time.Now()})
+	}
+	return batch
+}
+
+func TestIngestBufferCoalesces(t *testing.T) {
+	mock := &countingStorage{}
+	storage = mock
+	buf := newIngestBuffer(1000, 50*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := buf.Submit(context.Background(), testBatch(fmt.Sprintf("agent-%d", i), 5)); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(mock.metrics) != 50 {
+		t.Errorf("Expected 50 metrics stored, got %d", len(mock.metrics))
+	}
+	if mock.calls >= 10 {
+		t.Errorf("Expected batches to be coalesced, got %d inserts", mock.calls)
+	}
+
+	stats := buf.Stats()
+	if stats.AcceptedBatches != 10 || stats.AcceptedSamples != 50 || stats.QueuedSamples != 0 || stats.BufferCapacity != 1000 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.Flushes != int64(mock.calls) {
+		t.Errorf("Expected %d flushes, got %d", mock.calls, stats.Flushes)
+	}
+}
+
+func TestIngestBufferFull(t *testing.T) {
+	storage = &mockStorage{}
+	buf := newIngestBuffer(10, time.Hour)
+
+	// Nada é gravado antes do intervalo, então o primeiro lote ocupa o buffer
+	go buf.Submit(context.Background(), testBatch("agent-a", 8))
+	deadline := time.Now().Add(time.Second)
+	for buf.Stats().QueuedSamples == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := buf.Submit(context.Background(), testBatch("agent-b", 5)); !errors.Is(err, errIngestBufferFull) {
+		t.Fatalf("Expected errIngestBufferFull, got %v", err)
+	}
+	if stats := buf.Stats(); stats.ThrottledBatches != 1 || stats.QueuedSamples != 8 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestIngestBufferFlushError(t *testing.T) {
+	storage = &mockStorage{insertErr: errors.New("connection refused")}
+	buf := newIngestBuffer(100, 10*time.Millisecond)
+
+	if err := buf.Submit(context.Background(), testBatch("agent-a", 3)); err == nil {
+		t.Fatal("Expected flush error")
+	}
+	if stats := buf.Stats(); stats.FailedBatches != 1 || stats.AcceptedSamples != 0 || stats.QueuedSamples != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestIngestBufferCancelledSubmit(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+	buf := newIngestBuffer(100, 50*time.Millisecond)
+
+	// Cancelado antes da gravação: o lote sai do buffer e não é gravado
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := buf.Submit(ctx, testBatch("agent-a", 3)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if len(mock.metrics) != 0 {
+		t.Errorf("Expected withdrawn batch not to be stored, got %d metrics", len(mock.metrics))
+	}
+	if stats := buf.Stats(); stats.QueuedSamples != 0 || stats.Flushes != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+// blockingStorage segura InsertBatches até release ser fechado
+type blockingStorage struct {
+	mockStorage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingStorage) InsertBatches(batches []shared.Batch) error {
+	close(m.started)
+	<-m.release
+	return m.mockStorage.InsertBatches(batches)
+}
+
+func TestIngestBufferCancelledDuringFlush(t *testing.T) {
+	mock := &blockingStorage{started: make(chan struct{}), release: make(chan struct{})}
+	storage = mock
+	buf := newIngestBuffer(100, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- buf.Submit(ctx, testBatch("agent-a", 3)) }()
+
+	// Já em gravação: o cancelamento não pode virar erro de um lote gravado
+	<-mock.started
+	cancel()
+	close(mock.release)
+	if err := <-result; err != nil {
+		t.Fatalf("Expected the flush result, got %v", err)
+	}
+	if len(mock.metrics) != 3 {
+		t.Errorf("Expected 3 metrics stored, got %d", len(mock.metrics))
+	}
+}
+
+// poisonStorage recusa qualquer gravação que inclua um lote de agentID
+type poisonStorage struct {
+	mockStorage
+	agentID string
+}
+
+func (m *poisonStorage) InsertBatches(batches []shared.Batch) error {
+	for _, batch := range batches {
+		if batch.AgentID == m.agentID {
+			return errors.New("invalid input syntax")
+		}
+	}
+	return m.mockStorage.InsertBatches(batches)
+}
+
+func TestIngestBufferIsolatesFailedBatch(t *testing.T) {
+	mock := &poisonStorage{agentID: "agent-bad"}
+	storage = mock
+	buf := newIngestBuffer(1000, 50*time.Millisecond)
+
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, agentID := range []string{"agent-a", "agent-bad", "agent-b"} {
+		wg.Add(1)
+		go func(agentID string) {
+			defer wg.Done()
+			err := buf.Submit(context.Background(), testBatch(agentID, 2))
+			mu.Lock()
+			errs[agentID] = err
+			mu.Unlock()
+		}(agentID)
+	}
+	wg.Wait()
+
+	if errs["agent-a"] != nil || errs["agent-b"] != nil || errs["agent-bad"] == nil {
+		t.Fatalf("Expected only agent-bad to fail, got %v", errs)
+	}
+	if len(mock.metrics) != 4 {
+		t.Errorf("Expected 4 metrics stored, got %d", len(mock.metrics))
+	}
+	if stats := buf.Stats(); stats.AcceptedBatches != 2 || stats.FailedBatches != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestIngestHandlerBackpressure(t *testing.T) {
+	storage = &mockStorage{}
+	previous := ingest
+	defer func() { ingest = previous }()
+
+	post := func(batch shared.Batch) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(batch)
+		req := httptest.NewRequest("POST", "/ingest", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		ingestHandler(w, req)
+		return w
+	}
+
+	ingest = newIngestBuffer(4, time.Hour)
+	if w := post(testBatch("agent-a", 5)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+
+	// Ocupa o buffer com um lote que só seria gravado daqui a uma hora
+	go ingest.Submit(context.Background(), testBatch("agent-a", 3))
+	deadline := time.Now().Add(time.Second)
+	for ingest.Stats().QueuedSamples == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := post(testBatch("agent-b", 2))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// As séries de um lote recusado não ocupam o limite do agente
+	if w := post(testBatch("agent-throttled", 2)); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if n := activeSeries.count()["agent-throttled"]; n != 0 {
+		t.Errorf("Expected no active series for a throttled batch, got %d", n)
+	}
+}
@@ -4,14 +4,30 @@ import (
 	"argos/shared"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"testing"
 	"time"
 )
 
 type mockStorage struct {
 	metrics []shared.Metric
+	tokens  []mockToken
+	agents  map[string]*Agent
+	// lastRange é a última consulta recebida por QueryRange
+	lastRange RangeQuery
+	rollups   []string
+	// insertErr, se definido, é retornado por InsertBatches
+	insertErr error
+	// partitions são os dias com partição criada
+	partitions map[time.Time]bool
+}
+
+type mockToken struct {
+	AgentToken
+	hash string
 }
 
 func (m *mockStorage) InsertMetrics(agentID string, metrics []shared.Metric) error {
@@ -19,7 +35,17 @@ func (m *mockStorage) InsertMetrics(agentID string, metrics []shared.Metric) err
 	return nil
 }
 
-func (m *mockStorage) QueryLatest(name, service, target string) (*shared.Metric, error) {
+func (m *mockStorage) InsertBatches(batches []shared.Batch) error {
+	if m.insertErr != nil {
+		return m.insertErr
+	}
+	for _, batch := range batches {
+		m.metrics = append(m.metrics, batch.Items...)
+	}
+	return nil
+}
+
+func (m *mockStorage) QueryLatest(name, service, target string, matchers []LabelMatcher) (*shared.Metric, error) {
 	for i := len(m.metrics) - 1; i >= 0; i-- {
 		metric := m.metrics[i]
 		if metric.Name == name {
@@ -29,16 +55,107 @@ func (m *mockStorage) QueryLatest(name, service, target string) (*shared.Metric,
 			if target != "" && metric.Target != target {
 				continue
 			}
+			if !matchesAll(matchers, metric.Labels) {
+				continue
+			}
 			return &metric, nil
 		}
 	}
 	return nil, nil
 }
 
-func (m *mockStorage) QueryRange(name, service, target string, start, end time.Time, step string) ([]shared.DataPoint, error) {
-	return []shared.DataPoint{
+// QueryRange devolve um ponto fixo em Data e uma série por conjunto de labels
+// das métricas gravadas que casam com a consulta
+func (m *mockStorage) QueryRange(q RangeQuery) (*RangeResult, error) {
+	m.lastRange = q
+	result := &RangeResult{Data: []shared.DataPoint{
 		{Timestamp: time.Now().Unix(), Value: 45.2},
-	}, nil
+	}}
+
+	index := map[string]int{}
+	for _, metric := range m.metrics {
+		if metric.Name != q.Name || (q.Service != "" && metric.Service != q.Service) ||
+			(q.Target != "" && metric.Target != q.Target) || !matchesAll(q.Matchers, metric.Labels) {
+			continue
+		}
+		key := fmt.Sprintf("%s|%s|%v", metric.Service, metric.Target, metric.Labels)
+		i, ok := index[key]
+		if !ok {
+			i = len(result.Series)
+			index[key] = i
+			result.Series = append(result.Series, shared.Series{Service: metric.Service, Target: metric.Target, Labels: metric.Labels})
+		}
+		result.Series[i].Data = append(result.Series[i].Data, shared.DataPoint{Timestamp: metric.TS.Unix(), Value: metric.Value})
+	}
+	return result, nil
+}
+
+func (m *mockStorage) SelectSeries(q SeriesQuery) ([]shared.Metric, error) {
+	var metrics []shared.Metric
+	seen := map[string]bool{}
+	for _, metric := range m.metrics {
+		if metric.TS.Before(q.Start) || metric.TS.After(q.End) || !matchesAll(q.Matchers, promMetricLabels(metric)) {
+			continue
+		}
+		if !q.Samples {
+			key := promSignature(promMetricLabels(metric))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			metric.Value, metric.TS = 0, time.Time{}
+		}
+		if q.Limit > 0 && len(metrics) == q.Limit {
+			break
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// Rollup registra as chamadas e marca o tier como em dia
+func (m *mockStorage) Rollup(tier rollupTier, upTo time.Time) (time.Time, error) {
+	m.rollups = append(m.rollups, tier.Name+"@"+upTo.UTC().Format(time.RFC3339))
+	return upTo, nil
+}
+
+// RollupRange registra as reagregações de amostras atrasadas
+func (m *mockStorage) RollupRange(tier rollupTier, from, to time.Time) error {
+	m.rollups = append(m.rollups, tier.Name+"@"+from.UTC().Format(time.RFC3339)+"/"+to.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func (m *mockStorage) PruneRollups(tier rollupTier, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockStorage) BackfillMetricsPartitions(since time.Time) error {
+	return nil
+}
+
+func (m *mockStorage) ListMetricsPartitions() ([]time.Time, error) {
+	var days []time.Time
+	for day := range m.partitions {
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func (m *mockStorage) CreateMetricsPartition(day time.Time) error {
+	if m.partitions == nil {
+		m.partitions = map[time.Time]bool{}
+	}
+	m.partitions[day] = true
+	return nil
+}
+
+func (m *mockStorage) DropMetricsPartition(day time.Time) error {
+	delete(m.partitions, day)
+	return nil
+}
+
+func (m *mockStorage) PruneDefaultPartition(before time.Time) (int64, error) {
+	return 0, nil
 }
 
 func (m *mockStorage) ListServices() ([]string, error) {
@@ -84,8 +201,14 @@ func (m *mockStorage) DeleteAlertRule(id int) error {
 	return nil
 }
 
-func (m *mockStorage) GetLatestMetrics() ([]shared.Metric, error) {
-	return m.metrics, nil
+func (m *mockStorage) GetLatestMetrics(matchers []LabelMatcher) ([]shared.Metric, error) {
+	var metrics []shared.Metric
+	for _, metric := range m.metrics {
+		if matchesAll(matchers, metric.Labels) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
 }
 
 // Security methods
@@ -128,6 +251,88 @@ func (m *mockStorage) GetTrafficAnomalies(limit int) (int64, error) {
 	return 0, nil
 }
 
+func (m *mockStorage) AuthenticateAgentToken(tokenHash string) (string, error) {
+	for _, t := range m.tokens {
+		if t.hash == tokenHash && t.RevokedAt == nil {
+			return t.AgentID, nil
+		}
+	}
+	return "", nil
+}
+
+func (m *mockStorage) CreateAgentToken(token *AgentToken, tokenHash string) error {
+	token.ID = len(m.tokens) + 1
+	token.CreatedAt = time.Now()
+	m.tokens = append(m.tokens, mockToken{AgentToken: *token, hash: tokenHash})
+	return nil
+}
+
+func (m *mockStorage) ListAgentTokens() ([]AgentToken, error) {
+	var tokens []AgentToken
+	for _, t := range m.tokens {
+		tokens = append(tokens, t.AgentToken)
+	}
+	return tokens, nil
+}
+
+func (m *mockStorage) RevokeAgentToken(id int) error {
+	for i := range m.tokens {
+		if m.tokens[i].ID == id {
+			now := time.Now()
+			m.tokens[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return errAgentTokenNotFound
+}
+
+func (m *mockStorage) RegisterAgent(agent *Agent) error {
+	if m.agents == nil {
+		m.agents = map[string]*Agent{}
+	}
+	registered := *agent
+	registered.RegisteredAt = time.Now()
+	m.agents[agent.AgentID] = &registered
+	agent.RegisteredAt = registered.RegisteredAt
+	return nil
+}
+
+func (m *mockStorage) TouchAgent(agentID string, seenAt time.Time, clockSkewMS, clockOffsetMS *float64) error {
+	if m.agents == nil {
+		m.agents = map[string]*Agent{}
+	}
+	a, ok := m.agents[agentID]
+	if !ok {
+		a = &Agent{AgentID: agentID, RegisteredAt: seenAt}
+		m.agents[agentID] = a
+	}
+	a.LastSeenAt = seenAt
+	if clockSkewMS != nil {
+		a.ClockSkewMS = clockSkewMS
+	}
+	if clockOffsetMS != nil {
+		a.ClockOffsetMS = clockOffsetMS
+	}
+	return nil
+}
+
+func (m *mockStorage) ListAgents() ([]Agent, error) {
+	var agents []Agent
+	for _, a := range m.agents {
+		agents = append(agents, *a)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].AgentID < agents[j].AgentID })
+	return agents, nil
+}
+
+func (m *mockStorage) GetAgent(agentID string) (*Agent, error) {
+	if a, ok := m.agents[agentID]; ok {
+		copied := *a
+		return &copied, nil
+	}
+	return nil, nil
+}
+
 func (m *mockStorage) Close() error {
 	return nil
 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LabelMatcher filtra séries por um label, como nos seletores do Prometheus.
+// Um label ausente equivale a valor vazio.
+type LabelMatcher struct {
+	Name  string
+	Op    string // =, !=, =~ ou !~
+	Value string
+
+	re *regexp.Regexp
+	// sqlPattern é a regex ancorada no formato do operador ~ do Postgres
+	sqlPattern string
+}
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*$`)
+
+// NewLabelMatcher valida o nome e compila a regex, que precisa casar com o
+// valor inteiro
+func NewLabelMatcher(name, op, value string) (LabelMatcher, error) {
+	m := LabelMatcher{Name: name, Op: op, Value: value}
+	if !labelNamePattern.MatchString(name) {
+		return m, fmt.Errorf("invalid label name %q", name)
+	}
+
+	switch op {
+	case "=", "!=":
+	case "=~", "!~":
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return m, fmt.Errorf("label %s: invalid regex: %v", name, err)
+		}
+		m.re = re
+		if m.sqlPattern, err = postgresPattern(value); err != nil {
+			return m, fmt.Errorf("label %s: invalid regex: %v", name, err)
+		}
+	default:
+		return m, fmt.Errorf("label %s: unknown operator %q", name, op)
+	}
+	return m, nil
+}
+
+// leadingRegexFlags são as flags que o Go e o Postgres entendem igual: i
+// (maiúsculas e minúsculas) e s (. casa com \n, o padrão do Postgres)
+var leadingRegexFlags = regexp.MustCompile(`^\(\?[is]+\)`)
+
+// postgresPattern ancora value para o Postgres. O Postgres só aceita flags
+// no início da regex, então (?i)foo vira (?i)^(?:foo)$; flags em outra
+// posição, (?i:...), outras flags e grupos nomeados são recusados.
+func postgresPattern(value string) (string, error) {
+	flags := leadingRegexFlags.FindString(value)
+	rest := value[len(flags):]
+
+	inClass := false
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if strings.HasPrefix(rest[i:], "[:") {
+				if end := strings.Index(rest[i+2:], ":]"); end >= 0 {
+					i += end + 3
+				}
+			} else if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// ] logo depois de [ ou [^ é literal
+			if strings.HasPrefix(rest[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(rest[i+1:], "]") {
+				i++
+			}
+		case c == '(' && strings.HasPrefix(rest[i+1:], "?") && !strings.HasPrefix(rest[i+2:], ":"):
+			return "", fmt.Errorf("unsupported group at offset %d: only (?:...) groups and leading (?i) or (?s) flags are allowed", len(flags)+i)
+		}
+	}
+	return flags + "^(?:" + rest + ")$", nil
+}
+
+// parseLabelMatcher lê um parâmetro "label" no formato nome<op>valor, por
+// exemplo method=POST, code!~5.. ou url=~"https://.*". Aspas são opcionais.
+func parseLabelMatcher(s string) (LabelMatcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return LabelMatcher{}, fmt.Errorf("invalid label matcher %q: expected name=value", s)
+	}
+
+	name, rest := s[:i], s[i:]
+	var op string
+	for _, candidate := range []string{"=~", "!~", "!=", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return LabelMatcher{}, fmt.Errorf("invalid label matcher %q: unknown operator", s)
+	}
+
+	value := rest[len(op):]
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return NewLabelMatcher(name, op, value)
+}
+
+func parseLabelMatchers(params []string) ([]LabelMatcher, error) {
+	var matchers []LabelMatcher
+	for _, p := range params {
+		m, err := parseLabelMatcher(p)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// Matches avalia o matcher em memória (séries sintéticas e mocks)
+func (m LabelMatcher) Matches(labels map[string]string) bool {
+	v := labels[m.Name]
+	switch m.Op {
+	case "=":
+		return v == m.Value
+	case "!=":
+		return v != m.Value
+	case "=~":
+		return m.re.MatchString(v)
+	case "!~":
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+func matchesAll(matchers []LabelMatcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m LabelMatcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Op, m.Value)
+}
+
+// appendLabelMatchers adiciona os matchers à cláusula WHERE. Igualdade com
+// valor não vazio usa containment (@>), que aproveita o índice GIN.
+func appendLabelMatchers(query string, args []interface{}, matchers []LabelMatcher) (string, []interface{}) {
+	for _, m := range matchers {
+		if m.Op == "=" && m.Value != "" {
+			doc, _ := json.Marshal(map[string]string{m.Name: m.Value})
+			args = append(args, string(doc))
+			query += fmt.Sprintf(" AND labels @> $%d::jsonb", len(args))
+			continue
+		}
+
+		args = append(args, m.Name)
+		query, args = appendMatcherCondition(query, args, fmt.Sprintf("COALESCE(labels->>$%d, '')", len(args)), m)
+	}
+	return query, args
+}
+
+// appendMatcherCondition compara field, uma expressão SQL de texto, com o
+// valor do matcher
+func appendMatcherCondition(query string, args []interface{}, field string, m LabelMatcher) (string, []interface{}) {
+	switch m.Op {
+	case "=", "!=":
+		args = append(args, m.Value)
+		sqlOp := "="
+		if m.Op == "!=" {
+			sqlOp = "<>"
+		}
+		query += fmt.Sprintf(" AND %s %s $%d", field, sqlOp, len(args))
+	case "=~", "!~":
+		args = append(args, m.sqlPattern)
+		sqlOp := "~"
+		if m.Op == "!~" {
+			sqlOp = "!~"
+		}
+		query += fmt.Sprintf(" AND %s %s $%d", field, sqlOp, len(args))
+	}
+	return query, args
+}
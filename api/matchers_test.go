@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLabelMatcher(t *testing.T) {
+	tests := []struct {
+		in    string
+		name  string
+		op    string
+		value string
+	}{
+		{"method=POST", "method", "=", "POST"},
+		{"code!=200", "code", "!=", "200"},
+		{"code=~5..", "code", "=~", "5.."},
+		{`url!~"https://.*"`, "url", "!~", "https://.*"},
+		{"region=", "region", "=", ""},
+	}
+
+	for _, tt := range tests {
+		m, err := parseLabelMatcher(tt.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if m.Name != tt.name || m.Op != tt.op || m.Value != tt.value {
+			t.Errorf("%s: got %s %s %q", tt.in, m.Name, m.Op, m.Value)
+		}
+	}
+
+	for _, in := range []string{"method", "=POST", "1abc=x", "code=~(", "code~x"} {
+		if _, err := parseLabelMatcher(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestLabelMatcherMatches(t *testing.T) {
+	labels := map[string]string{"method": "POST", "code": "503"}
+
+	tests := []struct {
+		matcher string
+		want    bool
+	}{
+		{"method=POST", true},
+		{"method=GET", false},
+		{"method!=GET", true},
+		{"code=~5..", true},
+		{"code=~5", false}, // a regex precisa casar com o valor inteiro
+		{"code!~2..", true},
+		{"region=", true}, // label ausente equivale a vazio
+		{"region!=", false},
+	}
+
+	for _, tt := range tests {
+		m, err := parseLabelMatcher(tt.matcher)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.matcher, err)
+		}
+		if got := m.Matches(labels); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.matcher, tt.want, got)
+		}
+	}
+}
+
+func TestAppendLabelMatchers(t *testing.T) {
+	matchers, err := parseLabelMatchers([]string{"method=POST", "code=~5..", "region!="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, args := appendLabelMatchers("SELECT 1 FROM metrics WHERE name = $1", []interface{}{"http_status"}, matchers)
+
+	for _, want := range []string{
+		"labels @> $2::jsonb",
+		"COALESCE(labels->>$3, '') ~ $4",
+		"COALESCE(labels->>$5, '') <> $6",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %q, got %s", want, query)
+		}
+	}
+
+	if len(args) != 6 || args[1] != `{"method":"POST"}` || args[3] != "^(?:5..)$" {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestPostgresPattern(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"5..", "^(?:5..)$"},
+		{"(?i)post", "(?i)^(?:post)$"},
+		{"(?is)a.b", "(?is)^(?:a.b)$"},
+		{"(?:GET|POST)", "^(?:(?:GET|POST))$"},
+		{`a\(?i\)`, `^(?:a\(?i\))$`},
+		{"[(?]x", "^(?:[(?]x)$"},
+		{"[[:alpha:]](?:x)", "^(?:[[:alpha:]](?:x))$"},
+	}
+	for _, tt := range tests {
+		got, err := postgresPattern(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.in, tt.want, got, err)
+		}
+	}
+
+	for _, in := range []string{"(?m)a", "(?U)a+", "a(?i)b", "(?i:a)", "(?P<x>a)", "[]](?i)a"} {
+		if _, err := postgresPattern(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+
+	// O Go aceita (?i) no meio da regex; o matcher precisa recusar antes do banco
+	if _, err := parseLabelMatcher("method=~GET|(?i)post"); err == nil {
+		t.Error("Expected error for flags after the start")
+	}
+	m, err := parseLabelMatcher("method=~(?i)post")
+	if err != nil || !m.Matches(map[string]string{"method": "POST"}) {
+		t.Errorf("Expected (?i) matcher to match POST, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"migrations/0002_add_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"migrations/0001_add_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+	}
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "add_a" || migrations[1].Version != 2 {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+	if migrations[0].Down != "" || migrations[1].Down != "DROP TABLE b;" {
+		t.Errorf("Unexpected down scripts %+v", migrations)
+	}
+
+	invalid := map[string]fstest.MapFS{
+		"bad name":          {"migrations/add_a.sql": {Data: []byte("x")}},
+		"duplicate version": {"migrations/0001_a.up.sql": {Data: []byte("x")}, "migrations/0001_b.up.sql": {Data: []byte("x")}},
+		"down without up":   {"migrations/0001_a.down.sql": {Data: []byte("x")}},
+	}
+	for name, fsys := range invalid {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected version %d, got %04d_%s", i+1, m.Version, m.Name)
+		}
+	}
+
+	var cleanup string
+	partitioned := false
+	for _, m := range migrations {
+		if strings.Contains(m.Up, "FUNCTION cleanup_old_metrics") {
+			cleanup = m.Up
+		}
+		if strings.Contains(m.Up, "PARTITION BY RANGE (ts)") {
+			partitioned = m.Down != ""
+		}
+	}
+	if !partitioned {
+		t.Error("Expected a reversible migration partitioning metrics")
+	}
+	if !strings.Contains(cleanup, "config_changes WHERE detected_at") || strings.Contains(cleanup, "DELETE FROM metrics") {
+		t.Errorf("Latest cleanup_old_metrics should use detected_at and skip metrics:\n%s", cleanup)
+	}
+}
+
+func TestMigrateCommandUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"sideways"}, {"down", "-steps", "0"}} {
+		var stdout, stderr bytes.Buffer
+		if code := migrateCommand(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("%v: expected usage on stderr", args)
+		}
+	}
+}
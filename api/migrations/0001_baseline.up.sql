@@ -1,3 +1,5 @@
+-- Schema inicial, como era o schema.sql antes das migrations. Idempotente,
+-- para valer também em bancos criados por ele.
 CREATE TABLE IF NOT EXISTS metrics (
     ts TIMESTAMPTZ NOT NULL,
     service TEXT NOT NULL,
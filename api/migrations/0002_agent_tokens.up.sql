@@ -0,0 +1,12 @@
+-- Credenciais dos agentes (apenas o hash SHA-256 do token é armazenado)
+CREATE TABLE IF NOT EXISTS agent_tokens (
+    id SERIAL PRIMARY KEY,
+    agent_id TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_tokens_agent ON agent_tokens (agent_id);
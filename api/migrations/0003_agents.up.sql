@@ -0,0 +1,13 @@
+-- Inventário dos agentes. last_seen_at é atualizado por registro, heartbeat
+-- e ingestão; agent_up é derivado dele pela API.
+CREATE TABLE IF NOT EXISTS agents (
+    agent_id TEXT PRIMARY KEY,
+    version TEXT NOT NULL DEFAULT '',
+    hostname TEXT NOT NULL DEFAULT '',
+    probes JSONB NOT NULL DEFAULT '[]'::jsonb,
+    started_at TIMESTAMPTZ,
+    registered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    clock_skew_ms DOUBLE PRECISION,
+    clock_offset_ms DOUBLE PRECISION
+);
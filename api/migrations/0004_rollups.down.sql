@@ -0,0 +1 @@
+DROP TABLE IF EXISTS metrics_1h, metrics_1m, rollup_state;
@@ -0,0 +1,25 @@
+-- Rollups de metrics mantidos pela API. metrics_1m é agregado de metrics e
+-- metrics_1h de metrics_1m; rollup_state guarda até onde cada tier está
+-- completo.
+CREATE TABLE IF NOT EXISTS metrics_1m (
+    bucket TIMESTAMPTZ NOT NULL,
+    service TEXT NOT NULL,
+    target TEXT NOT NULL,
+    name TEXT NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+    value_min DOUBLE PRECISION NOT NULL,
+    value_max DOUBLE PRECISION NOT NULL,
+    value_avg DOUBLE PRECISION NOT NULL,
+    value_sum DOUBLE PRECISION NOT NULL,
+    value_count BIGINT NOT NULL,
+    PRIMARY KEY (name, service, target, labels, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_metrics_1m_bucket ON metrics_1m (bucket);
+
+CREATE TABLE IF NOT EXISTS metrics_1h (LIKE metrics_1m INCLUDING ALL);
+
+CREATE TABLE IF NOT EXISTS rollup_state (
+    tier TEXT PRIMARY KEY,
+    rolled_up_to TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,12 @@
+-- metrics expira por partição, removida pela API, e config_changes não tem
+-- created_at: a data da mudança é detected_at
+CREATE OR REPLACE FUNCTION cleanup_old_metrics()
+RETURNS void AS $$
+BEGIN
+    DELETE FROM alerts WHERE resolved_at < NOW() - INTERVAL '90 days';
+    DELETE FROM notifications WHERE sent_at < NOW() - INTERVAL '90 days';
+    DELETE FROM security_events WHERE created_at < NOW() - INTERVAL '90 days';
+    DELETE FROM failed_logins WHERE created_at < NOW() - INTERVAL '30 days';
+    DELETE FROM config_changes WHERE detected_at < NOW() - INTERVAL '90 days';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,38 @@
+-- Volta metrics a uma tabela comum, com as amostras das partições e as que o
+-- backfill ainda não tinha movido. Copia tudo sob lock: pare a ingestão antes.
+LOCK TABLE metrics IN ACCESS EXCLUSIVE MODE;
+
+CREATE TABLE IF NOT EXISTS metrics_unpartitioned (
+    ts TIMESTAMPTZ NOT NULL,
+    service TEXT NOT NULL,
+    target TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+    agent_id TEXT
+);
+
+INSERT INTO metrics_unpartitioned (ts, service, target, name, value, labels, agent_id)
+SELECT ts, service, target, name, value, labels, agent_id FROM metrics;
+
+DROP VIEW IF EXISTS latest_metrics;
+DROP TABLE metrics;
+ALTER TABLE metrics_unpartitioned RENAME TO metrics;
+DROP INDEX IF EXISTS idx_metrics_unpartitioned_ts;
+
+CREATE INDEX IF NOT EXISTS idx_metrics_ts ON metrics (ts DESC);
+CREATE INDEX IF NOT EXISTS idx_metrics_by_name ON metrics (name, service, target, ts DESC);
+CREATE INDEX IF NOT EXISTS idx_metrics_by_service ON metrics (service, ts DESC);
+CREATE INDEX IF NOT EXISTS idx_metrics_labels ON metrics USING GIN (labels);
+
+CREATE OR REPLACE VIEW latest_metrics AS
+SELECT DISTINCT ON (service, target, name)
+    ts,
+    service,
+    target,
+    name,
+    value,
+    labels,
+    agent_id
+FROM metrics
+ORDER BY service, target, name, ts DESC;
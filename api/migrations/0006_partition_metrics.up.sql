@@ -0,0 +1,47 @@
+-- Troca metrics, se ainda não particionada, por uma tabela particionada por
+-- dia (UTC); a partição default recebe amostras de dias ainda sem partição.
+-- A tabela antiga fica como metrics_unpartitioned: a API move as amostras
+-- dentro de METRICS_RETENTION para as partições em segundo plano, um dia
+-- por vez, e descarta o resto com ela.
+DO $$
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = 'metrics'::regclass) = 'p' THEN
+        RETURN;
+    END IF;
+
+    LOCK TABLE metrics IN ACCESS EXCLUSIVE MODE;
+    ALTER TABLE metrics RENAME TO metrics_unpartitioned;
+    -- O índice por ts fica para a cópia por dia
+    ALTER INDEX IF EXISTS idx_metrics_ts RENAME TO idx_metrics_unpartitioned_ts;
+    DROP INDEX IF EXISTS idx_metrics_by_name, idx_metrics_by_service, idx_metrics_labels;
+
+    CREATE TABLE metrics (
+        ts TIMESTAMPTZ NOT NULL,
+        service TEXT NOT NULL,
+        target TEXT NOT NULL,
+        name TEXT NOT NULL,
+        value DOUBLE PRECISION NOT NULL,
+        labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+        agent_id TEXT
+    ) PARTITION BY RANGE (ts);
+
+    CREATE TABLE metrics_default PARTITION OF metrics DEFAULT;
+
+    CREATE INDEX idx_metrics_ts ON metrics (ts DESC);
+    CREATE INDEX idx_metrics_by_name ON metrics (name, service, target, ts DESC);
+    CREATE INDEX idx_metrics_by_service ON metrics (service, ts DESC);
+    CREATE INDEX idx_metrics_labels ON metrics USING GIN (labels);
+
+    CREATE OR REPLACE VIEW latest_metrics AS
+    SELECT DISTINCT ON (service, target, name)
+        ts,
+        service,
+        target,
+        name,
+        value,
+        labels,
+        agent_id
+    FROM metrics
+    ORDER BY service, target, name, ts DESC;
+END
+$$;
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"argos/shared"
+)
+
+// partitionManager mantém as partições diárias de metrics: cria as dos
+// próximos dias e remove as que passaram da retenção
+type partitionManager struct {
+	// retention é configurável por METRICS_RETENTION
+	retention time.Duration
+	// ahead é quantos dias além de hoje ficam criados
+	ahead int
+
+	mu     sync.Mutex
+	status shared.PartitionHealth
+}
+
+var partitions = &partitionManager{retention: 30 * 24 * time.Hour, ahead: 3}
+
+func (m *partitionManager) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.manage(time.Now()); err != nil {
+			log.Printf("Partition maintenance failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (m *partitionManager) manage(now time.Time) error {
+	err := m.maintain(now)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status.Retention = formatDays(m.retention)
+	m.status.LastRun = &now
+	m.status.LastError = ""
+	if err != nil {
+		m.status.LastError = err.Error()
+	}
+	return err
+}
+
+func (m *partitionManager) maintain(now time.Time) error {
+	days, err := storage.ListMetricsPartitions()
+	if err != nil {
+		return err
+	}
+	existing := map[time.Time]bool{}
+	for _, day := range days {
+		existing[day] = true
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	for i := 0; i <= m.ahead; i++ {
+		day := today.AddDate(0, 0, i)
+		if existing[day] {
+			continue
+		}
+		if err := storage.CreateMetricsPartition(day); err != nil {
+			return err
+		}
+		log.Printf("Created metrics partition %s", metricsPartitionName(day))
+		existing[day] = true
+	}
+
+	// Uma partição expira quando o dia inteiro está fora da retenção
+	cutoff := now.Add(-m.retention)
+	for day := range existing {
+		if day.AddDate(0, 0, 1).After(cutoff) {
+			continue
+		}
+		if err := storage.DropMetricsPartition(day); err != nil {
+			return err
+		}
+		log.Printf("Dropped expired metrics partition %s", metricsPartitionName(day))
+		delete(existing, day)
+	}
+
+	defaultRows, err := storage.PruneDefaultPartition(cutoff)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = partitionSummary(existing, today)
+	m.status.DefaultRows = defaultRows
+	return nil
+}
+
+func partitionSummary(existing map[time.Time]bool, today time.Time) shared.PartitionHealth {
+	days := make([]time.Time, 0, len(existing))
+	for day := range existing {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	summary := shared.PartitionHealth{Count: len(days)}
+	if len(days) > 0 {
+		summary.Oldest = days[0].Format("2006-01-02")
+		newest := days[len(days)-1]
+		summary.Newest = newest.Format("2006-01-02")
+		if ahead := int(newest.Sub(today) / (24 * time.Hour)); ahead > 0 {
+			summary.DaysAhead = ahead
+		}
+	}
+	return summary
+}
+
+// health retorna o estado da última manutenção e se ele é saudável: sem
+// erro e com a partição de amanhã já criada
+func (m *partitionManager) health() (shared.PartitionHealth, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.status
+	if status.LastRun == nil {
+		return status, true
+	}
+	return status, status.LastError == "" && status.DaysAhead >= 1
+}
+
+func formatDays(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+func day(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func TestPartitionManagerCreatesAndDrops(t *testing.T) {
+	mock := &mockStorage{partitions: map[time.Time]bool{
+		day("2024-03-01"): true, // expirada
+		day("2024-03-31"): true, // ainda tem horas dentro da retenção
+		day("2024-04-30"): true,
+	}}
+	storage = mock
+
+	m := &partitionManager{retention: 30 * 24 * time.Hour, ahead: 3}
+	now := time.Date(2024, 4, 30, 12, 0, 0, 0, time.UTC)
+	if err := m.manage(now); err != nil {
+		t.Fatalf("manage failed: %v", err)
+	}
+
+	for _, d := range []string{"2024-03-31", "2024-04-30", "2024-05-01", "2024-05-02", "2024-05-03"} {
+		if !mock.partitions[day(d)] {
+			t.Errorf("Expected partition for %s", d)
+		}
+	}
+	if mock.partitions[day("2024-03-01")] || len(mock.partitions) != 5 {
+		t.Errorf("Expected expired partition to be dropped, got %v", mock.partitions)
+	}
+
+	status, ok := m.health()
+	if !ok || status.Count != 5 || status.Oldest != "2024-03-31" || status.Newest != "2024-05-03" ||
+		status.DaysAhead != 3 || status.Retention != "30d" {
+		t.Errorf("Unexpected partition health %+v (ok=%v)", status, ok)
+	}
+}
+
+func TestHealthReportsPartitions(t *testing.T) {
+	storage = &mockStorage{metrics: []shared.Metric{{Name: "x", TS: time.Now()}}}
+
+	saved := partitions
+	defer func() { partitions = saved }()
+	partitions = &partitionManager{retention: 7 * 24 * time.Hour, ahead: 0}
+	partitions.manage(time.Now())
+
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("GET", "/health", nil))
+
+	var health shared.HealthResponse
+	json.NewDecoder(w.Body).Decode(&health)
+	if health.Partitions == nil || health.Partitions.Count != 1 || health.Partitions.Retention != "7d" {
+		t.Fatalf("Expected partition health, got %+v", health.Partitions)
+	}
+	// Sem a partição de amanhã, a saúde fica degradada
+	if health.Status != "degraded" {
+		t.Errorf("Expected degraded status without tomorrow's partition, got %s", health.Status)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoints compatíveis com a API HTTP do Prometheus (/api/v1/...), para uso
+// como datasource Prometheus no Grafana. As séries vêm da tabela metrics,
+// com service, target e __name__ como labels.
+
+// promMetadataRange é a janela de /series, /labels e /label/<nome>/values
+// quando start não é informado
+const promMetadataRange = time.Hour
+
+type promResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type promQueryData struct {
+	ResultType string      `json:"resultType"`
+	Result     interface{} `json:"result"`
+}
+
+type promVectorSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  promSample        `json:"value"`
+}
+
+type promMatrixSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values []promSample      `json:"values"`
+}
+
+// MarshalJSON usa o formato [<segundos>, "<valor>"] do Prometheus
+func (s promSample) MarshalJSON() ([]byte, error) {
+	ts := strconv.FormatFloat(float64(s.t)/1000, 'f', -1, 64)
+	return []byte(fmt.Sprintf("[%s,%q]", ts, formatPromValue(s.v))), nil
+}
+
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// promHandler trata CORS, método e formulário, já que o Grafana pode enviar
+// os parâmetros por GET ou POST
+func promHandler(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			writePromError(w, http.StatusMethodNotAllowed, "bad_data", fmt.Errorf("method not allowed"))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writePromError(w, http.StatusBadRequest, "bad_data", err)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func writePromData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promResponse{Status: "success", Data: data})
+}
+
+func writePromError(w http.ResponseWriter, status int, errorType string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(promResponse{Status: "error", ErrorType: errorType, Error: err.Error()})
+}
+
+func writePromStorageError(w http.ResponseWriter, err error) {
+	log.Printf("Prometheus API storage error: %v", err)
+	writePromError(w, http.StatusInternalServerError, "internal", fmt.Errorf("internal server error"))
+}
+
+// parsePromTime aceita segundos Unix (com fração) ou RFC3339
+func parsePromTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMilli(int64(math.Round(secs * 1000))), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+	}
+	return t, nil
+}
+
+// parsePromStep aceita segundos (com fração) ou durações como 15s e 1m
+func parsePromStep(s string) (time.Duration, error) {
+	var step time.Duration
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		step = time.Duration(secs * float64(time.Second))
+	} else if step, err = parsePromDuration(s); err != nil {
+		return 0, fmt.Errorf("cannot parse %q to a valid duration", s)
+	}
+	if step <= 0 {
+		return 0, fmt.Errorf("zero or negative query resolution step widths are not accepted. Try a positive integer")
+	}
+	return step, nil
+}
+
+func promQueryHandler(w http.ResponseWriter, r *http.Request) {
+	expr, err := parsePromQL(r.Form.Get("query"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"query\": %v", err))
+		return
+	}
+	ts, err := parsePromTime(r.Form.Get("time"), time.Now())
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"time\": %v", err))
+		return
+	}
+
+	ev, err := newPromEvaluator(expr, ts, ts)
+	if errors.Is(err, errPromTooManySamples) {
+		writePromError(w, http.StatusUnprocessableEntity, "execution", err)
+		return
+	}
+	if errors.Is(err, errInvalidMatcherRegex) {
+		writePromError(w, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+	if err != nil {
+		writePromStorageError(w, err)
+		return
+	}
+	t := ts.UnixMilli()
+
+	// Um range vector na consulta instantânea retorna as amostras brutas
+	if sel, ok := expr.(*promSelector); ok && sel.rng > 0 {
+		end := t - sel.offset.Milliseconds()
+		result := []promMatrixSeries{}
+		for _, s := range ev.series[sel] {
+			if samples := samplesBetween(s.samples, end-sel.rng.Milliseconds(), end); len(samples) > 0 {
+				result = append(result, promMatrixSeries{Metric: s.labels, Values: samples})
+			}
+		}
+		writePromData(w, promQueryData{ResultType: "matrix", Result: result})
+		return
+	}
+
+	v, err := ev.eval(expr, t)
+	if err != nil {
+		writePromError(w, http.StatusUnprocessableEntity, "execution", err)
+		return
+	}
+
+	switch v := v.(type) {
+	case float64:
+		writePromData(w, promQueryData{ResultType: "scalar", Result: promSample{t: t, v: v}})
+	case promVector:
+		result := make([]promVectorSample, len(v))
+		for i, el := range v {
+			result[i] = promVectorSample{Metric: el.labels, Value: promSample{t: t, v: el.v}}
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return promSignature(result[i].Metric) < promSignature(result[j].Metric)
+		})
+		writePromData(w, promQueryData{ResultType: "vector", Result: result})
+	}
+}
+
+func promQueryRangeHandler(w http.ResponseWriter, r *http.Request) {
+	expr, err := parsePromQL(r.Form.Get("query"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"query\": %v", err))
+		return
+	}
+	if sel, ok := expr.(*promSelector); ok && sel.rng > 0 {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid expression type \"range vector\" for range query, must be scalar or instant vector"))
+		return
+	}
+
+	start, err := parsePromTime(r.Form.Get("start"), time.Time{})
+	if err == nil && start.IsZero() {
+		err = fmt.Errorf("start is required")
+	}
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"start\": %v", err))
+		return
+	}
+	end, err := parsePromTime(r.Form.Get("end"), time.Time{})
+	if err == nil && end.IsZero() {
+		err = fmt.Errorf("end is required")
+	}
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"end\": %v", err))
+		return
+	}
+	if end.Before(start) {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("end timestamp must not be before start time"))
+		return
+	}
+	step, err := parsePromStep(r.Form.Get("step"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"step\": %v", err))
+		return
+	}
+	if end.Sub(start)/step > maxRangePoints {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX)", maxRangePoints))
+		return
+	}
+
+	ev, err := newPromEvaluator(expr, start, end)
+	if errors.Is(err, errPromTooManySamples) {
+		writePromError(w, http.StatusUnprocessableEntity, "execution", err)
+		return
+	}
+	if errors.Is(err, errInvalidMatcherRegex) {
+		writePromError(w, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+	if err != nil {
+		writePromStorageError(w, err)
+		return
+	}
+
+	series := map[string]*promMatrixSeries{}
+	for t := start.UnixMilli(); t <= end.UnixMilli(); t += step.Milliseconds() {
+		v, err := ev.eval(expr, t)
+		if err != nil {
+			writePromError(w, http.StatusUnprocessableEntity, "execution", err)
+			return
+		}
+
+		vec, ok := v.(promVector)
+		if !ok {
+			vec = promVector{{labels: map[string]string{}, v: v.(float64)}}
+		}
+		for _, el := range vec {
+			key := promSignature(el.labels)
+			if series[key] == nil {
+				series[key] = &promMatrixSeries{Metric: el.labels}
+			}
+			series[key].Values = append(series[key].Values, promSample{t: t, v: el.v})
+		}
+	}
+
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]promMatrixSeries, len(keys))
+	for i, key := range keys {
+		result[i] = *series[key]
+	}
+	writePromData(w, promQueryData{ResultType: "matrix", Result: result})
+}
+
+// promMatchedSeries retorna as séries que casam com algum match[] dentro
+// de start e end. Sem match[], retorna todas as séries da janela.
+func promMatchedSeries(r *http.Request) ([]promSeries, int, error) {
+	end, err := parsePromTime(r.Form.Get("end"), time.Now())
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid parameter \"end\": %v", err)
+	}
+	start, err := parsePromTime(r.Form.Get("start"), end.Add(-promMetadataRange))
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid parameter \"start\": %v", err)
+	}
+
+	var selectors [][]LabelMatcher
+	for _, s := range r.Form["match[]"] {
+		expr, err := parsePromQL(s)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid parameter \"match[]\": %v", err)
+		}
+		sel, ok := expr.(*promSelector)
+		if !ok || sel.rng > 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid parameter \"match[]\": %q is not an instant vector selector", s)
+		}
+		selectors = append(selectors, sel.matchers)
+	}
+	if len(selectors) == 0 {
+		selectors = [][]LabelMatcher{nil}
+	}
+
+	var result []promSeries
+	seen := map[string]bool{}
+	for _, matchers := range selectors {
+		series, err := selectPromSeries(SeriesQuery{Matchers: matchers, Start: start, End: end})
+		if errors.Is(err, errInvalidMatcherRegex) {
+			return nil, http.StatusBadRequest, err
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		for _, s := range series {
+			if key := promSignature(s.labels); !seen[key] {
+				seen[key] = true
+				result = append(result, s)
+			}
+		}
+	}
+	return result, http.StatusOK, nil
+}
+
+func writePromMatchError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		writePromStorageError(w, err)
+		return
+	}
+	writePromError(w, status, "bad_data", err)
+}
+
+func promSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	if len(r.Form["match[]"]) == 0 {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("no match[] parameter provided"))
+		return
+	}
+
+	series, status, err := promMatchedSeries(r)
+	if err != nil {
+		writePromMatchError(w, status, err)
+		return
+	}
+
+	result := make([]map[string]string, len(series))
+	for i, s := range series {
+		result[i] = s.labels
+	}
+	writePromData(w, result)
+}
+
+func promLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	series, status, err := promMatchedSeries(r)
+	if err != nil {
+		writePromMatchError(w, status, err)
+		return
+	}
+
+	names := map[string]bool{}
+	for _, s := range series {
+		for name := range s.labels {
+			names[name] = true
+		}
+	}
+	writePromData(w, sortedKeys(names))
+}
+
+// promLabelValuesHandler atende /api/v1/label/<nome>/values
+func promLabelValuesHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/label/"), "/values")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		writePromError(w, http.StatusNotFound, "bad_data", fmt.Errorf("unknown endpoint %s", r.URL.Path))
+		return
+	}
+	if name != "__name__" && !labelNamePattern.MatchString(name) {
+		writePromError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid label name: %q", name))
+		return
+	}
+
+	series, status, err := promMatchedSeries(r)
+	if err != nil {
+		writePromMatchError(w, status, err)
+		return
+	}
+
+	values := map[string]bool{}
+	for _, s := range series {
+		if v, ok := s.labels[name]; ok {
+			values[v] = true
+		}
+	}
+	writePromData(w, sortedKeys(values))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+// promTestMetrics gera um contador por método, subindo 1/s (GET) e 2/s
+// (POST), com amostras a cada 15s na última meia hora
+func promTestMetrics(now time.Time) []shared.Metric {
+	var metrics []shared.Metric
+	for ts := now.Add(-30 * time.Minute); !ts.After(now); ts = ts.Add(15 * time.Second) {
+		elapsed := ts.Sub(now.Add(-30 * time.Minute)).Seconds()
+		metrics = append(metrics,
+			shared.Metric{Service: "web", Target: "api", Name: "http_requests_total", Value: elapsed, Labels: map[string]string{"method": "GET"}, TS: ts},
+			shared.Metric{Service: "web", Target: "api", Name: "http_requests_total", Value: 2 * elapsed, Labels: map[string]string{"method": "POST"}, TS: ts},
+		)
+	}
+	return metrics
+}
+
+type promTestResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+func promRequest(t *testing.T, handler http.HandlerFunc, method, path string, params url.Values) (int, promTestResponse) {
+	var req *http.Request
+	if method == "POST" {
+		req = httptest.NewRequest("POST", path, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest("GET", path+"?"+params.Encode(), nil)
+	}
+	w := httptest.NewRecorder()
+	promHandler(handler)(w, req)
+
+	var resp promTestResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestPromInstantQuery(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	storage = &mockStorage{metrics: promTestMetrics(now)}
+
+	code, resp := promRequest(t, promQueryHandler, "POST", "/api/v1/query", url.Values{
+		"query": {`sum by (method) (rate(http_requests_total{service="web"}[5m]))`},
+		"time":  {fmt.Sprint(now.Unix())},
+	})
+	if code != http.StatusOK || resp.Status != "success" {
+		t.Fatalf("Expected success, got %d %+v", code, resp)
+	}
+
+	var data struct {
+		ResultType string             `json:"resultType"`
+		Result     []promVectorSample `json:"result"`
+	}
+	json.Unmarshal(resp.Data, &data)
+	if data.ResultType != "vector" || !strings.Contains(string(resp.Data), `"method":"POST"`) {
+		t.Fatalf("Unexpected result %s", resp.Data)
+	}
+	if !strings.Contains(string(resp.Data), `"1"]`) || !strings.Contains(string(resp.Data), `"2"]`) {
+		t.Errorf("Expected rates 1 and 2, got %s", resp.Data)
+	}
+}
+
+func TestPromInstantQueryScalarAndErrors(t *testing.T) {
+	storage = &mockStorage{}
+
+	code, resp := promRequest(t, promQueryHandler, "GET", "/api/v1/query", url.Values{"query": {"2 * 3"}, "time": {"1700000000"}})
+	if code != http.StatusOK || string(resp.Data) != `{"resultType":"scalar","result":[1700000000,"6"]}` {
+		t.Errorf("Unexpected scalar response %d %s", code, resp.Data)
+	}
+
+	code, resp = promRequest(t, promQueryHandler, "GET", "/api/v1/query", url.Values{"query": {"rate(x)"}})
+	if code != http.StatusBadRequest || resp.Status != "error" || resp.ErrorType != "bad_data" {
+		t.Errorf("Expected bad_data error, got %d %+v", code, resp)
+	}
+}
+
+func TestPromRangeQuery(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	storage = &mockStorage{metrics: promTestMetrics(now)}
+
+	code, resp := promRequest(t, promQueryRangeHandler, "GET", "/api/v1/query_range", url.Values{
+		"query": {`http_requests_total{method="GET"} * 2`},
+		"start": {now.Add(-10 * time.Minute).Format(time.RFC3339)},
+		"end":   {now.Format(time.RFC3339)},
+		"step":  {"1m"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %+v", code, resp)
+	}
+
+	var data struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		} `json:"result"`
+	}
+	json.Unmarshal(resp.Data, &data)
+	if data.ResultType != "matrix" || len(data.Result) != 1 {
+		t.Fatalf("Expected one matrix series, got %s", resp.Data)
+	}
+	s := data.Result[0]
+	if len(s.Values) != 11 || s.Metric["__name__"] != "" || s.Metric["method"] != "GET" {
+		t.Errorf("Unexpected series %+v", s)
+	}
+	if last := s.Values[len(s.Values)-1][1]; last != "3600" {
+		t.Errorf("Expected last value 3600, got %v", last)
+	}
+
+	code, _ = promRequest(t, promQueryRangeHandler, "GET", "/api/v1/query_range", url.Values{
+		"query": {"x"}, "start": {"0"}, "end": {"86400"}, "step": {"1"},
+	})
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when exceeding max points, got %d", code)
+	}
+}
+
+func TestPromSeriesAndLabels(t *testing.T) {
+	now := time.Now()
+	storage = &mockStorage{metrics: promTestMetrics(now)}
+
+	code, resp := promRequest(t, promSeriesHandler, "GET", "/api/v1/series", url.Values{"match[]": {`http_requests_total{method=~"P.*"}`}})
+	if code != http.StatusOK || string(resp.Data) != `[{"__name__":"http_requests_total","method":"POST","service":"web","target":"api"}]` {
+		t.Errorf("Unexpected series response %d %s", code, resp.Data)
+	}
+
+	code, _ = promRequest(t, promSeriesHandler, "GET", "/api/v1/series", nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without match[], got %d", code)
+	}
+
+	_, resp = promRequest(t, promLabelsHandler, "GET", "/api/v1/labels", nil)
+	if string(resp.Data) != `["__name__","method","service","target"]` {
+		t.Errorf("Unexpected labels %s", resp.Data)
+	}
+
+	_, resp = promRequest(t, promLabelValuesHandler, "GET", "/api/v1/label/method/values", nil)
+	if string(resp.Data) != `["GET","POST"]` {
+		t.Errorf("Unexpected label values %s", resp.Data)
+	}
+
+	code, _ = promRequest(t, promLabelValuesHandler, "GET", "/api/v1/label/1bad/values", nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid label name, got %d", code)
+	}
+}
+
+func TestPromQuerySampleLimit(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	storage = &mockStorage{metrics: promTestMetrics(now)}
+	defer func(max int) { promMaxSamples = max }(promMaxSamples)
+
+	// Cada método tem 41 amostras em 10m, 82 no total
+	params := url.Values{
+		"query": {`max_over_time(http_requests_total[10m])`},
+		"time":  {fmt.Sprint(now.Unix())},
+	}
+	promMaxSamples = 82
+	if code, resp := promRequest(t, promQueryHandler, "GET", "/api/v1/query", params); code != http.StatusOK {
+		t.Fatalf("Expected status 200 within the limit, got %d %+v", code, resp)
+	}
+
+	promMaxSamples = 81
+	code, resp := promRequest(t, promQueryHandler, "GET", "/api/v1/query", params)
+	if code != http.StatusUnprocessableEntity || resp.ErrorType != "execution" || !strings.Contains(resp.Error, "too many samples") {
+		t.Errorf("Expected too many samples error, got %d %+v", code, resp)
+	}
+
+	// O limite soma os seletores da consulta
+	params.Set("query", `max_over_time(http_requests_total{method="GET"}[10m]) + max_over_time(http_requests_total{method="POST"}[10m])`)
+	if code, _ := promRequest(t, promQueryHandler, "GET", "/api/v1/query", params); code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 across selectors, got %d", code)
+	}
+}
+
+func TestSelectPromSeriesSharesLabelsPerSeries(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	labels := map[string]string{"method": "GET"}
+	var metrics []shared.Metric
+	for i := 0; i < 3; i++ {
+		metrics = append(metrics, shared.Metric{Service: "web", Target: "api", Name: "http_requests_total", Value: float64(i), Labels: labels, TS: now.Add(time.Duration(i) * time.Second)})
+	}
+	// A mesma série volta depois de outra: as amostras se juntam pela assinatura
+	metrics = append(metrics,
+		shared.Metric{Service: "web", Target: "api", Name: "http_requests_total", Value: 9, Labels: map[string]string{"method": "POST"}, TS: now},
+		shared.Metric{Service: "web", Target: "api", Name: "http_requests_total", Value: 3, Labels: map[string]string{"method": "GET"}, TS: now.Add(3 * time.Second)},
+	)
+	storage = &mockStorage{metrics: metrics}
+
+	series, err := selectPromSeries(SeriesQuery{
+		Matchers: []LabelMatcher{{Name: "__name__", Op: "=", Value: "http_requests_total"}},
+		Start:    now.Add(-time.Minute),
+		End:      now.Add(time.Minute),
+		Samples:  true,
+	})
+	if err != nil {
+		t.Fatalf("selectPromSeries failed: %v", err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	if series[0].labels["method"] != "GET" || len(series[0].samples) != 4 || len(series[1].samples) != 1 {
+		t.Errorf("Unexpected grouping: %+v", series)
+	}
+}
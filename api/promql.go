@@ -0,0 +1,513 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Subconjunto de PromQL aceito pela API compatível com Prometheus:
+// seletores com matchers, [range] e offset, funções sobre range vectors,
+// agregações com by/without e aritmética (+ - * / %).
+
+type promExpr interface{}
+
+type promNumber struct {
+	value float64
+}
+
+type promSelector struct {
+	name     string
+	matchers []LabelMatcher
+	// rng > 0 torna o seletor um range vector
+	rng    time.Duration
+	offset time.Duration
+}
+
+type promCall struct {
+	fn  string
+	arg *promSelector
+}
+
+type promAggregate struct {
+	op       string
+	grouping []string
+	without  bool
+	expr     promExpr
+}
+
+type promBinary struct {
+	op       string
+	lhs, rhs promExpr
+}
+
+// promRangeFunctions são as funções aceitas, todas sobre um range vector
+var promRangeFunctions = map[string]bool{
+	"rate": true, "irate": true, "increase": true, "delta": true,
+	"avg_over_time": true, "min_over_time": true, "max_over_time": true,
+	"sum_over_time": true, "count_over_time": true, "last_over_time": true,
+}
+
+var promAggregations = map[string]bool{
+	"sum": true, "avg": true, "min": true, "max": true, "count": true,
+}
+
+type promTokenKind int
+
+const (
+	promEOF promTokenKind = iota
+	promIdent
+	promNumberTok
+	promString
+	promDuration
+	promOp
+)
+
+type promToken struct {
+	kind promTokenKind
+	text string
+	pos  int
+}
+
+// lexPromQL quebra a expressão em tokens. Durações só aparecem entre
+// colchetes ou após offset, e por isso são lidas pelo parser.
+func lexPromQL(input string) ([]promToken, error) {
+	var tokens []promToken
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || c == ':' || unicode.IsLetter(c):
+			start := i
+			for i < len(input) && isPromIdentChar(rune(input[i])) {
+				i++
+			}
+			tokens = append(tokens, promToken{promIdent, input[start:i], start})
+		case c == '.' || unicode.IsDigit(c):
+			start := i
+			for i < len(input) && (isPromIdentChar(rune(input[i])) ||
+				((input[i] == '+' || input[i] == '-') && (input[i-1] == 'e' || input[i-1] == 'E'))) {
+				i++
+			}
+			kind := promNumberTok
+			if _, err := strconv.ParseFloat(input[start:i], 64); err != nil {
+				kind = promDuration
+			}
+			tokens = append(tokens, promToken{kind, input[start:i], start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			for i < len(input) && rune(input[i]) != c {
+				if input[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			s, err := unquotePromString(input[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %v", start, err)
+			}
+			tokens = append(tokens, promToken{promString, s, start})
+		default:
+			op := ""
+			for _, candidate := range []string{"=~", "!~", "!=", "=", "+", "-", "*", "/", "%", "(", ")", "{", "}", "[", "]", ","} {
+				if strings.HasPrefix(input[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, promToken{promOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, promToken{promEOF, "", len(input)}), nil
+}
+
+func isPromIdentChar(c rune) bool {
+	return c == '_' || c == ':' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func unquotePromString(s string) (string, error) {
+	if s[0] == '\'' {
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+var promDurationPattern = regexp.MustCompile(`^([0-9]+(ms|[smhdwy]))+$`)
+var promDurationPart = regexp.MustCompile(`([0-9]+)(ms|[smhdwy])`)
+
+var promDurationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parsePromDuration aceita durações no formato do Prometheus, como 5m,
+// 1h30m ou 2d
+func parsePromDuration(s string) (time.Duration, error) {
+	if !promDurationPattern.MatchString(s) {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	for _, part := range promDurationPart.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.ParseInt(part[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %v", s, err)
+		}
+		d += time.Duration(n) * promDurationUnits[part[2]]
+	}
+	return d, nil
+}
+
+type promParser struct {
+	tokens []promToken
+	pos    int
+}
+
+// parsePromQL converte uma expressão no AST avaliado por promEvaluator
+func parsePromQL(input string) (promExpr, error) {
+	tokens, err := lexPromQL(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &promParser{tokens: tokens}
+
+	expr, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != promEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return expr, nil
+}
+
+func (p *promParser) peek() promToken {
+	return p.tokens[p.pos]
+}
+
+func (p *promParser) next() promToken {
+	t := p.tokens[p.pos]
+	if t.kind != promEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *promParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != promOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *promParser) expect(op string) error {
+	if t := p.next(); t.kind != promOp || t.text != op {
+		return fmt.Errorf("expected %q at position %d, got %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *promParser) parseAdditive() (promExpr, error) {
+	lhs, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next().text
+		rhs, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &promBinary{op: op, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *promParser) parseMultiplicative() (promExpr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") {
+		op := p.next().text
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &promBinary{op: op, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *promParser) parseUnary() (promExpr, error) {
+	if p.isOp("+", "-") {
+		op := p.next().text
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return expr, nil
+		}
+		if n, ok := expr.(*promNumber); ok {
+			return &promNumber{value: -n.value}, nil
+		}
+		return &promBinary{op: "*", lhs: &promNumber{value: -1}, rhs: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *promParser) parsePrimary() (promExpr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == promNumberTok:
+		p.next()
+		v, _ := strconv.ParseFloat(t.text, 64)
+		return &promNumber{value: v}, nil
+
+	case t.kind == promOp && t.text == "(":
+		p.next()
+		expr, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+
+	case t.kind == promOp && t.text == "{":
+		return p.parseSelector("")
+
+	case t.kind == promIdent:
+		lower := strings.ToLower(t.text)
+		if lower == "inf" || lower == "nan" {
+			p.next()
+			if lower == "inf" {
+				return &promNumber{value: math.Inf(1)}, nil
+			}
+			return &promNumber{value: math.NaN()}, nil
+		}
+		if promAggregations[t.text] {
+			if next := p.tokens[p.pos+1]; next.kind == promOp && next.text == "(" ||
+				next.kind == promIdent && (next.text == "by" || next.text == "without") {
+				return p.parseAggregate()
+			}
+		}
+		if next := p.tokens[p.pos+1]; next.kind == promOp && next.text == "(" {
+			return p.parseCall()
+		}
+		p.next()
+		return p.parseSelector(t.text)
+	}
+
+	if t.kind == promEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+func (p *promParser) parseCall() (promExpr, error) {
+	name := p.next()
+	if !promRangeFunctions[name.text] {
+		return nil, fmt.Errorf("unknown function %q", name.text)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	arg, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := arg.(*promSelector)
+	if !ok || sel.rng == 0 {
+		return nil, fmt.Errorf("%s: expected a range vector selector such as metric[5m]", name.text)
+	}
+	return &promCall{fn: name.text, arg: sel}, p.expect(")")
+}
+
+func (p *promParser) parseAggregate() (promExpr, error) {
+	agg := &promAggregate{op: p.next().text}
+
+	// O modificador pode vir antes ou depois da expressão
+	parseGrouping := func() error {
+		if t := p.peek(); t.kind == promIdent && (t.text == "by" || t.text == "without") {
+			p.next()
+			agg.without = t.text == "without"
+			labels, err := p.parseLabelList()
+			if err != nil {
+				return err
+			}
+			agg.grouping = labels
+		}
+		return nil
+	}
+
+	if err := parseGrouping(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	agg.expr = expr
+
+	if agg.grouping == nil {
+		if err := parseGrouping(); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *promParser) parseLabelList() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for !p.isOp(")") {
+		t := p.next()
+		if t.kind != promIdent {
+			return nil, fmt.Errorf("expected label name at position %d, got %q", t.pos, t.text)
+		}
+		labels = append(labels, t.text)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	return labels, p.expect(")")
+}
+
+func (p *promParser) parseSelector(name string) (promExpr, error) {
+	sel := &promSelector{name: name}
+	if name != "" {
+		m, _ := NewLabelMatcher("__name__", "=", name)
+		sel.matchers = append(sel.matchers, m)
+	}
+
+	if p.isOp("{") {
+		p.next()
+		for !p.isOp("}") {
+			label := p.next()
+			if label.kind != promIdent {
+				return nil, fmt.Errorf("expected label name at position %d, got %q", label.pos, label.text)
+			}
+			op := p.next()
+			if op.kind != promOp || (op.text != "=" && op.text != "!=" && op.text != "=~" && op.text != "!~") {
+				return nil, fmt.Errorf("expected label matching operator at position %d, got %q", op.pos, op.text)
+			}
+			value := p.next()
+			if value.kind != promString {
+				return nil, fmt.Errorf("expected quoted label value at position %d, got %q", value.pos, value.text)
+			}
+
+			m, err := NewLabelMatcher(label.text, op.text, value.text)
+			if err != nil {
+				return nil, err
+			}
+			if m.Name == "__name__" && name != "" {
+				return nil, fmt.Errorf("metric name %q given twice", name)
+			}
+			sel.matchers = append(sel.matchers, m)
+
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Como no Prometheus, ao menos um matcher precisa excluir o valor vazio
+	nonEmpty := false
+	for _, m := range sel.matchers {
+		if !m.Matches(map[string]string{}) {
+			nonEmpty = true
+		}
+	}
+	if !nonEmpty {
+		return nil, fmt.Errorf("vector selector must contain at least one non-empty matcher")
+	}
+
+	if p.isOp("[") {
+		p.next()
+		d, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("range must be positive")
+		}
+		sel.rng = d
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+
+	if t := p.peek(); t.kind == promIdent && t.text == "offset" {
+		p.next()
+		d, err := p.parseDuration()
+		if err != nil {
+			return nil, err
+		}
+		sel.offset = d
+	}
+	return sel, nil
+}
+
+// parseDuration lê a duração de um range ou offset. Um número puro, como
+// em [300], não é aceito, assim como no Prometheus.
+func (p *promParser) parseDuration() (time.Duration, error) {
+	t := p.next()
+	if t.kind != promDuration {
+		return 0, fmt.Errorf("expected duration at position %d, got %q", t.pos, t.text)
+	}
+	return parsePromDuration(t.text)
+}
+
+// promSelectors retorna os seletores da expressão, para a busca das amostras
+func promSelectors(expr promExpr) []*promSelector {
+	switch e := expr.(type) {
+	case *promSelector:
+		return []*promSelector{e}
+	case *promCall:
+		return []*promSelector{e.arg}
+	case *promAggregate:
+		return promSelectors(e.expr)
+	case *promBinary:
+		return append(promSelectors(e.lhs), promSelectors(e.rhs)...)
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+// promLookback é a janela em que um seletor instantâneo ainda enxerga a
+// última amostra de uma série, como o lookback delta do Prometheus
+const promLookback = 5 * time.Minute
+
+// promMaxSamples limita as amostras que uma consulta carrega, somando todos
+// os seletores. Cada amostra ainda passa por um shared.Metric antes de virar
+// promSample, então o limite é o que cabe com folga em memória.
+// Configurável por PROM_MAX_SAMPLES.
+var promMaxSamples = 500000
+
+// errPromTooManySamples tem o texto do Prometheus para o mesmo limite
+var errPromTooManySamples = errors.New("query processing would load too many samples into memory in query execution")
+
+type promSample struct {
+	t int64 // ms
+	v float64
+}
+
+type promSeries struct {
+	labels  map[string]string
+	samples []promSample
+}
+
+type promElement struct {
+	labels map[string]string
+	v      float64
+}
+
+type promVector []promElement
+
+// promEvaluator avalia uma expressão em instantes de [start, end], com as
+// amostras de cada seletor buscadas uma única vez
+type promEvaluator struct {
+	series map[*promSelector][]promSeries
+}
+
+// newPromEvaluator busca as amostras que expr precisa para ser avaliada
+// entre start e end. Retorna errPromTooManySamples se passar de
+// promMaxSamples; o banco para de ler logo depois do limite.
+func newPromEvaluator(expr promExpr, start, end time.Time) (*promEvaluator, error) {
+	e := &promEvaluator{series: map[*promSelector][]promSeries{}}
+	remaining := promMaxSamples
+	for _, sel := range promSelectors(expr) {
+		window := sel.rng
+		if window == 0 {
+			window = promLookback
+		}
+		series, err := selectPromSeries(SeriesQuery{
+			Matchers: sel.matchers,
+			Start:    start.Add(-sel.offset - window),
+			End:      end.Add(-sel.offset),
+			Samples:  true,
+			Limit:    remaining + 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			remaining -= len(s.samples)
+		}
+		e.series[sel] = series
+	}
+	return e, nil
+}
+
+// selectPromSeries agrupa as linhas de SelectSeries em séries com labels no
+// formato do Prometheus e aplica os matchers que o banco não resolve. Chegar
+// a q.Limit linhas é errPromTooManySamples, já que o resultado estaria cortado.
+func selectPromSeries(q SeriesQuery) ([]promSeries, error) {
+	metrics, err := storage.SelectSeries(q)
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(metrics) >= q.Limit {
+		return nil, errPromTooManySamples
+	}
+
+	// SelectSeries entrega as amostras de uma série seguidas e com o mesmo
+	// mapa de labels; os labels e o índice só são calculados quando a série
+	// muda
+	var series []promSeries
+	index := map[string]int{}
+	i, matched := -1, false
+	for n, m := range metrics {
+		if n == 0 || !sameSeries(m, metrics[n-1]) {
+			labels := promMetricLabels(m)
+			matched = matchesAll(q.Matchers, labels)
+			if !matched {
+				continue
+			}
+			key := promSignature(labels)
+			var ok bool
+			i, ok = index[key]
+			if !ok {
+				i = len(series)
+				index[key] = i
+				series = append(series, promSeries{labels: labels})
+			}
+		}
+		if !matched {
+			continue
+		}
+		if q.Samples {
+			series[i].samples = append(series[i].samples, promSample{t: m.TS.UnixMilli(), v: m.Value})
+		}
+	}
+	for _, s := range series {
+		sort.Slice(s.samples, func(a, b int) bool { return s.samples[a].t < s.samples[b].t })
+	}
+	return series, nil
+}
+
+// sameSeries indica se a e b são da mesma série
+func sameSeries(a, b shared.Metric) bool {
+	if a.Name != b.Name || a.Service != b.Service || a.Target != b.Target {
+		return false
+	}
+	return maps.Equal(a.Labels, b.Labels)
+}
+
+// promMetricLabels monta os labels de uma métrica como a API Prometheus os
+// expõe, sem valores vazios. Um label que colida com __name__, service ou
+// target vira exported_<nome>, como no exporter do agente.
+func promMetricLabels(m shared.Metric) map[string]string {
+	labels := make(map[string]string, len(m.Labels)+3)
+	for k, v := range m.Labels {
+		if _, reserved := seriesColumns[k]; reserved {
+			k = "exported_" + strings.TrimLeft(k, "_")
+		}
+		labels[k] = v
+	}
+	labels["__name__"] = m.Name
+	labels["service"] = m.Service
+	labels["target"] = m.Target
+
+	for k, v := range labels {
+		if v == "" {
+			delete(labels, k)
+		}
+	}
+	return labels
+}
+
+// promSignature identifica um conjunto de labels, ignorando valores vazios
+func promSignature(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func withoutName(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != "__name__" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// eval avalia expr no instante t (ms). O resultado é float64 para escalares
+// ou promVector.
+func (e *promEvaluator) eval(expr promExpr, t int64) (interface{}, error) {
+	switch ex := expr.(type) {
+	case *promNumber:
+		return ex.value, nil
+	case *promSelector:
+		if ex.rng > 0 {
+			return nil, fmt.Errorf("range vector %s must be wrapped in a function such as rate()", ex.name)
+		}
+		return e.instant(ex, t), nil
+	case *promCall:
+		return e.call(ex, t), nil
+	case *promAggregate:
+		v, err := e.eval(ex.expr, t)
+		if err != nil {
+			return nil, err
+		}
+		vec, ok := v.(promVector)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected instant vector, got scalar", ex.op)
+		}
+		return aggregatePromVector(ex, vec), nil
+	case *promBinary:
+		lhs, err := e.eval(ex.lhs, t)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := e.eval(ex.rhs, t)
+		if err != nil {
+			return nil, err
+		}
+		return evalPromBinary(ex.op, lhs, rhs)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+// instant retorna, por série, a última amostra em (t-lookback, t]
+func (e *promEvaluator) instant(sel *promSelector, t int64) promVector {
+	t -= sel.offset.Milliseconds()
+	vec := promVector{}
+	for _, s := range e.series[sel] {
+		samples := samplesBetween(s.samples, t-promLookback.Milliseconds(), t)
+		if len(samples) > 0 {
+			vec = append(vec, promElement{labels: s.labels, v: samples[len(samples)-1].v})
+		}
+	}
+	return vec
+}
+
+// samplesBetween retorna as amostras em (from, to]
+func samplesBetween(samples []promSample, from, to int64) []promSample {
+	lo := sort.Search(len(samples), func(i int) bool { return samples[i].t > from })
+	hi := sort.Search(len(samples), func(i int) bool { return samples[i].t > to })
+	return samples[lo:hi]
+}
+
+func (e *promEvaluator) call(c *promCall, t int64) promVector {
+	end := t - c.arg.offset.Milliseconds()
+	start := end - c.arg.rng.Milliseconds()
+
+	vec := promVector{}
+	for _, s := range e.series[c.arg] {
+		samples := samplesBetween(s.samples, start, end)
+		v, ok := evalRangeFunction(c.fn, samples, start, end)
+		if !ok {
+			continue
+		}
+		labels := s.labels
+		if c.fn != "last_over_time" {
+			labels = withoutName(labels)
+		}
+		vec = append(vec, promElement{labels: labels, v: v})
+	}
+	return vec
+}
+
+func evalRangeFunction(fn string, samples []promSample, start, end int64) (float64, bool) {
+	if len(samples) == 0 {
+		return 0, false
+	}
+
+	switch fn {
+	case "rate":
+		return extrapolatedDelta(samples, start, end, true, true)
+	case "increase":
+		return extrapolatedDelta(samples, start, end, true, false)
+	case "delta":
+		return extrapolatedDelta(samples, start, end, false, false)
+	case "irate":
+		if len(samples) < 2 {
+			return 0, false
+		}
+		last, prev := samples[len(samples)-1], samples[len(samples)-2]
+		if last.t == prev.t {
+			return 0, false
+		}
+		diff := last.v - prev.v
+		if last.v < prev.v {
+			diff = last.v // reset do contador
+		}
+		return diff / (float64(last.t-prev.t) / 1000), true
+	case "count_over_time":
+		return float64(len(samples)), true
+	case "last_over_time":
+		return samples[len(samples)-1].v, true
+	}
+
+	sum, min, max := 0.0, math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		sum += s.v
+		min = math.Min(min, s.v)
+		max = math.Max(max, s.v)
+	}
+	switch fn {
+	case "avg_over_time":
+		return sum / float64(len(samples)), true
+	case "sum_over_time":
+		return sum, true
+	case "min_over_time":
+		return min, true
+	case "max_over_time":
+		return max, true
+	}
+	return 0, false
+}
+
+// extrapolatedDelta segue o cálculo de rate/increase/delta do Prometheus:
+// a variação observada é extrapolada até as bordas da janela, desde que as
+// amostras não estejam longe demais delas. Em contadores, quedas contam
+// como reset.
+func extrapolatedDelta(samples []promSample, start, end int64, isCounter, isRate bool) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	first, last := samples[0], samples[len(samples)-1]
+
+	result := last.v - first.v
+	if isCounter {
+		for i := 1; i < len(samples); i++ {
+			if samples[i].v < samples[i-1].v {
+				result += samples[i-1].v
+			}
+		}
+	}
+
+	durationToStart := float64(first.t-start) / 1000
+	durationToEnd := float64(end-last.t) / 1000
+	sampled := float64(last.t-first.t) / 1000
+	if sampled <= 0 {
+		return 0, false
+	}
+	avgInterval := sampled / float64(len(samples)-1)
+
+	// Um contador não extrapola para antes de zero
+	if isCounter && result > 0 && first.v >= 0 {
+		if toZero := sampled * (first.v / result); toZero < durationToStart {
+			durationToStart = toZero
+		}
+	}
+
+	threshold := avgInterval * 1.1
+	interval := sampled
+	if durationToStart < threshold {
+		interval += durationToStart
+	} else {
+		interval += avgInterval / 2
+	}
+	if durationToEnd < threshold {
+		interval += durationToEnd
+	} else {
+		interval += avgInterval / 2
+	}
+
+	result *= interval / sampled
+	if isRate {
+		result /= float64(end-start) / 1000
+	}
+	return result, true
+}
+
+func aggregatePromVector(agg *promAggregate, vec promVector) promVector {
+	type group struct {
+		labels map[string]string
+		values []float64
+	}
+	groups := map[string]*group{}
+	var order []string
+
+	for _, el := range vec {
+		labels := map[string]string{}
+		if agg.without {
+			labels = withoutName(el.labels)
+			for _, name := range agg.grouping {
+				delete(labels, name)
+			}
+		} else {
+			for _, name := range agg.grouping {
+				if v := el.labels[name]; v != "" {
+					labels[name] = v
+				}
+			}
+		}
+
+		key := promSignature(labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.values = append(g.values, el.v)
+	}
+
+	out := make(promVector, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		var v float64
+		switch agg.op {
+		case "sum", "avg":
+			for _, x := range g.values {
+				v += x
+			}
+			if agg.op == "avg" {
+				v /= float64(len(g.values))
+			}
+		case "min":
+			v = math.Inf(1)
+			for _, x := range g.values {
+				v = math.Min(v, x)
+			}
+		case "max":
+			v = math.Inf(-1)
+			for _, x := range g.values {
+				v = math.Max(v, x)
+			}
+		case "count":
+			v = float64(len(g.values))
+		}
+		out = append(out, promElement{labels: g.labels, v: v})
+	}
+	return out
+}
+
+// evalPromBinary aplica op entre escalares e vetores. Entre dois vetores, os
+// elementos são pareados um a um pelos labels, ignorando __name__.
+func evalPromBinary(op string, lhs, rhs interface{}) (interface{}, error) {
+	switch l := lhs.(type) {
+	case float64:
+		switch r := rhs.(type) {
+		case float64:
+			return applyPromOp(op, l, r), nil
+		case promVector:
+			out := make(promVector, len(r))
+			for i, el := range r {
+				out[i] = promElement{labels: withoutName(el.labels), v: applyPromOp(op, l, el.v)}
+			}
+			return out, nil
+		}
+	case promVector:
+		switch r := rhs.(type) {
+		case float64:
+			out := make(promVector, len(l))
+			for i, el := range l {
+				out[i] = promElement{labels: withoutName(el.labels), v: applyPromOp(op, el.v, r)}
+			}
+			return out, nil
+		case promVector:
+			right := map[string]float64{}
+			for _, el := range r {
+				key := promSignature(withoutName(el.labels))
+				if _, dup := right[key]; dup {
+					return nil, fmt.Errorf("many-to-many matching not allowed: duplicate series on the right-hand side of %q", op)
+				}
+				right[key] = el.v
+			}
+
+			out := promVector{}
+			seen := map[string]bool{}
+			for _, el := range l {
+				labels := withoutName(el.labels)
+				key := promSignature(labels)
+				rv, ok := right[key]
+				if !ok {
+					continue
+				}
+				if seen[key] {
+					return nil, fmt.Errorf("many-to-many matching not allowed: duplicate series on the left-hand side of %q", op)
+				}
+				seen[key] = true
+				out = append(out, promElement{labels: labels, v: applyPromOp(op, el.v, rv)})
+			}
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported operands for %q", op)
+}
+
+func applyPromOp(op string, l, r float64) float64 {
+	switch op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	case "%":
+		return math.Mod(l, r)
+	}
+	return math.NaN()
+}
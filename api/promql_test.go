@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParsePromQL(t *testing.T) {
+	valid := []string{
+		`http_requests`,
+		`http_requests{method="POST", code=~"5.."}`,
+		`{__name__="http_requests", target!='site'}`,
+		`rate(http_requests[5m])`,
+		`rate(http_requests{code!~"2.."}[1h30m] offset 1d)`,
+		`sum by (service) (rate(http_requests[5m]))`,
+		`avg(http_latency_ms) without (target)`,
+		`max_over_time(http_latency_ms[10m]) / 1000`,
+		`-(a + b) * 2 % 3`,
+		`1.5e3`,
+	}
+	for _, q := range valid {
+		if _, err := parsePromQL(q); err != nil {
+			t.Errorf("%s: unexpected error: %v", q, err)
+		}
+	}
+
+	invalid := []string{
+		``,
+		`{}`,
+		`{method=~".*"}`,
+		`rate(http_requests)`,
+		`unknown_fn(http_requests[5m])`,
+		`http_requests{method="POST"`,
+		`http_requests{method=POST}`,
+		`http_requests[5]`,
+		`sum by (service (x)`,
+		`a b`,
+	}
+	for _, q := range invalid {
+		if _, err := parsePromQL(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestParsePromQLPrecedence(t *testing.T) {
+	expr, err := parsePromQL(`1 + 2 * 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &promEvaluator{}
+	v, err := ev.eval(expr, 0)
+	if err != nil || v.(float64) != 7 {
+		t.Errorf("Expected 7, got %v (%v)", v, err)
+	}
+}
+
+func TestParsePromDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30s":   30 * time.Second,
+		"5m":    5 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"2d":    48 * time.Hour,
+		"1w":    7 * 24 * time.Hour,
+		"500ms": 500 * time.Millisecond,
+	}
+	for in, want := range tests {
+		if got, err := parsePromDuration(in); err != nil || got != want {
+			t.Errorf("%s: expected %v, got %v (%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"", "5", "m", "1.5h", "5x"} {
+		if _, err := parsePromDuration(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestExtrapolatedRate(t *testing.T) {
+	// Contador subindo 1/s, amostrado a cada 15s numa janela de 60s
+	var samples []promSample
+	for ts := int64(0); ts <= 60000; ts += 15000 {
+		samples = append(samples, promSample{t: ts, v: 100 + float64(ts)/1000})
+	}
+
+	rate, ok := evalRangeFunction("rate", samples[1:], 0, 60000)
+	if !ok || math.Abs(rate-1) > 1e-9 {
+		t.Errorf("Expected rate 1, got %v", rate)
+	}
+
+	// Reset no meio da janela não gera taxa negativa
+	reset := []promSample{{0, 10}, {15000, 25}, {30000, 5}, {45000, 20}}
+	if increase, _ := evalRangeFunction("increase", reset, -15000, 45000); increase <= 0 {
+		t.Errorf("Expected positive increase across reset, got %v", increase)
+	}
+
+	if irate, _ := evalRangeFunction("irate", samples, 0, 60000); math.Abs(irate-1) > 1e-9 {
+		t.Errorf("Expected irate 1, got %v", irate)
+	}
+}
+
+func TestOverTimeFunctions(t *testing.T) {
+	samples := []promSample{{1000, 4}, {2000, 1}, {3000, 7}}
+	tests := map[string]float64{
+		"avg_over_time":   4,
+		"min_over_time":   1,
+		"max_over_time":   7,
+		"sum_over_time":   12,
+		"count_over_time": 3,
+		"last_over_time":  7,
+	}
+	for fn, want := range tests {
+		if got, ok := evalRangeFunction(fn, samples, 0, 3000); !ok || got != want {
+			t.Errorf("%s: expected %v, got %v", fn, want, got)
+		}
+	}
+}
+
+func TestAggregateAndBinary(t *testing.T) {
+	vec := promVector{
+		{labels: map[string]string{"__name__": "x", "service": "web", "target": "a"}, v: 1},
+		{labels: map[string]string{"__name__": "x", "service": "web", "target": "b"}, v: 3},
+		{labels: map[string]string{"__name__": "x", "service": "db", "target": "c"}, v: 5},
+	}
+
+	sum := aggregatePromVector(&promAggregate{op: "sum", grouping: []string{"service"}}, vec)
+	if len(sum) != 2 || sum[0].labels["service"] != "web" || sum[0].v != 4 || sum[1].v != 5 {
+		t.Errorf("Unexpected sum by (service): %+v", sum)
+	}
+
+	max := aggregatePromVector(&promAggregate{op: "max", grouping: []string{"target"}, without: true}, vec)
+	if len(max) != 2 || max[0].v != 3 || max[0].labels["__name__"] != "" {
+		t.Errorf("Unexpected max without (target): %+v", max)
+	}
+
+	other := promVector{
+		{labels: map[string]string{"__name__": "y", "service": "web", "target": "a"}, v: 2},
+	}
+	v, err := evalPromBinary("/", vec, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := v.(promVector); len(out) != 1 || out[0].v != 0.5 || out[0].labels["target"] != "a" {
+		t.Errorf("Unexpected vector / vector: %+v", out)
+	}
+
+	v, _ = evalPromBinary("*", vec, 2.0)
+	if out := v.(promVector); out[2].v != 10 || out[2].labels["__name__"] != "" {
+		t.Errorf("Unexpected vector * scalar: %+v", out)
+	}
+
+	dup := append(promVector{}, other[0], other[0])
+	if _, err := evalPromBinary("+", vec, dup); err == nil {
+		t.Error("Expected many-to-many matching error")
+	}
+}
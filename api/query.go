@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"argos/shared"
+)
+
+// maxRangePoints limita os pontos de uma série; um step que geraria mais
+// pontos é alargado até caber
+const maxRangePoints = 11000
+
+// parseRangeOptions lê step e agg, comuns a /api/metrics/range e a
+// /api/metrics/query com duration, e escolhe o tier de rollup. Sem step,
+// usa 1m. Os erros nomeiam o parâmetro inválido e o chamador os prefixa
+// com "Invalid" na resposta.
+func parseRangeOptions(r *http.Request, q *RangeQuery) error {
+	if q.End.Before(q.Start) {
+		return errors.New("range: end is before start")
+	}
+
+	matchers, err := parseLabelMatchers(r.URL.Query()["label"])
+	if err != nil {
+		return fmt.Errorf("label matcher: %w", err)
+	}
+	q.Matchers = matchers
+
+	step := time.Minute
+	if s := r.URL.Query().Get("step"); s != "" {
+		step, err = parseStep(s)
+		if err != nil {
+			return fmt.Errorf("step: %w", err)
+		}
+	}
+	q.Step = resolveRangeStep(q.Start, q.End, step)
+
+	q.Agg = r.URL.Query().Get("agg")
+	if q.Agg == "" {
+		q.Agg = "avg"
+	}
+	if _, ok := rangeAggregations[q.Agg]; !ok {
+		names := make([]string, 0, len(rangeAggregations))
+		for name := range rangeAggregations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("agg %q: must be one of %s", q.Agg, strings.Join(names, ", "))
+	}
+
+	q.Tier = chooseRollupTier(*q)
+	return nil
+}
+
+// parseStep aceita durações (10s, 5m, 1h), dias (1d) ou segundos (15)
+func parseStep(s string) (time.Duration, error) {
+	var step time.Duration
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		step = time.Duration(secs * float64(time.Second))
+	} else if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%q: %v", s, err)
+		}
+		step = time.Duration(n) * 24 * time.Hour
+	} else {
+		step, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if step < time.Second {
+		return 0, fmt.Errorf("%q: must be at least 1s", s)
+	}
+	return step, nil
+}
+
+// resolveRangeStep alarga step para que [start, end] não passe de
+// maxRangePoints buckets. O step alargado é arredondado para cima até um
+// múltiplo do bucket do tier de rollup mais grosso que caiba nele (ou de 1s),
+// para que janelas longas possam ler os rollups.
+func resolveRangeStep(start, end time.Time, step time.Duration) time.Duration {
+	span := end.Sub(start)
+	if span/step < maxRangePoints {
+		return step
+	}
+
+	step = span / (maxRangePoints - 1)
+	unit := time.Second
+	for _, tier := range rollupTiers {
+		if tier.Bucket <= step {
+			unit = tier.Bucket
+		}
+	}
+	return (step + unit - 1) / unit * unit
+}
+
+func serveRange(w http.ResponseWriter, q RangeQuery) {
+	result, err := storage.QueryRange(q)
+	if errors.Is(err, errInvalidMatcherRegex) {
+		http.Error(w, fmt.Sprintf("Invalid label matcher: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Query range error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.QueryRangeResponse{
+		Service: q.Service,
+		Target:  q.Target,
+		Name:    q.Name,
+		Step:    q.Step.String(),
+		Agg:     q.Agg,
+		Tier:    q.Tier,
+		Data:    result.Data,
+		Series:  result.Series,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
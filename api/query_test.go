@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"argos/shared"
+
+	"github.com/lib/pq"
+)
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"10s", 10 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"1h", time.Hour},
+		{"1d", 24 * time.Hour},
+		{"15", 15 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := parseStep(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseStep(%q) = %s, %v; want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"100ms", "abc", "xd", "0"} {
+		if _, err := parseStep(bad); err == nil {
+			t.Errorf("parseStep(%q): expected error", bad)
+		}
+	}
+}
+
+func TestResolveRangeStepCapsPoints(t *testing.T) {
+	end := time.Now()
+	start := end.Add(-30 * 24 * time.Hour)
+
+	step := resolveRangeStep(start, end, time.Minute)
+	if points := end.Sub(start) / step; points >= maxRangePoints {
+		t.Errorf("Expected fewer than %d points, got %d (step %s)", maxRangePoints, points, step)
+	}
+	if step%time.Second != 0 {
+		t.Errorf("Expected step in whole seconds, got %s", step)
+	}
+
+	// 30d com o step padrão alarga para um múltiplo de 1m e lê o rollup
+	if step%time.Minute != 0 {
+		t.Errorf("Expected step to be a multiple of 1m, got %s", step)
+	}
+	if tier := chooseRollupTier(RangeQuery{Step: step, Agg: "avg"}); tier != "1m" {
+		t.Errorf("Expected 30d range to use the 1m tier, got %q (step %s)", tier, step)
+	}
+
+	// Janelas de anos alargam para múltiplos de 1h
+	step = resolveRangeStep(end.Add(-2*365*24*time.Hour), end, time.Minute)
+	if tier := chooseRollupTier(RangeQuery{Step: step, Agg: "avg"}); tier != "1h" {
+		t.Errorf("Expected 2y range to use the 1h tier, got %q (step %s)", tier, step)
+	}
+
+	if step := resolveRangeStep(end.Add(-time.Hour), end, 10*time.Second); step != 10*time.Second {
+		t.Errorf("Expected step to be kept when under the cap, got %s", step)
+	}
+}
+
+func TestQueryRangeHandlerStepAndAgg(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	req := httptest.NewRequest("GET", "/api/metrics/range?name=http_latency_ms&start=-1h&step=10s&agg=p95", nil)
+	w := httptest.NewRecorder()
+	queryRangeHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if mock.lastRange.Step != 10*time.Second || mock.lastRange.Agg != "p95" {
+		t.Errorf("Expected step 10s and agg p95, got %+v", mock.lastRange)
+	}
+
+	var response shared.QueryRangeResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Step != "10s" || response.Agg != "p95" {
+		t.Errorf("Expected effective step and agg in response, got %+v", response)
+	}
+}
+
+func TestQueryRangeHandlerInvalidOptions(t *testing.T) {
+	storage = &mockStorage{}
+
+	for query, want := range map[string]string{
+		"name=x&agg=median": "Invalid agg",
+		"name=x&step=5ms":   "Invalid step",
+		"name=x&start=2024-01-02T00:00:00Z&end=2024-01-01T00:00:00Z": "Invalid range",
+		"name=x&label=code=~(":        "Invalid label matcher",
+		"name=x&label=code=~5(?i:xx)": "Invalid label matcher",
+	} {
+		w := httptest.NewRecorder()
+		queryRangeHandler(w, httptest.NewRequest("GET", "/api/metrics/range?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+		if !strings.HasPrefix(w.Body.String(), want) {
+			t.Errorf("%s: expected message starting with %q, got %q", query, want, w.Body.String())
+		}
+	}
+}
+
+// regexErrorStorage recusa a regex como o Postgres faria
+type regexErrorStorage struct {
+	mockStorage
+}
+
+func (m *regexErrorStorage) QueryRange(q RangeQuery) (*RangeResult, error) {
+	return nil, matcherQueryError(&pq.Error{Code: "2201B", Message: "invalid regular expression: invalid escape \\ sequence"})
+}
+
+func TestQueryRangeHandlerDatabaseRegexError(t *testing.T) {
+	storage = &regexErrorStorage{}
+
+	w := httptest.NewRecorder()
+	queryRangeHandler(w, httptest.NewRequest("GET", "/api/metrics/range?name=x&label=code=~5..", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a regex the database rejects, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func labeledMetrics() []shared.Metric {
+	now := time.Now()
+	return []shared.Metric{
+		{Service: "web", Target: "api", Name: "http_requests", Value: 1, Labels: map[string]string{"method": "GET", "code": "200"}, TS: now.Add(-2 * time.Minute)},
+		{Service: "web", Target: "api", Name: "http_requests", Value: 2, Labels: map[string]string{"method": "POST", "code": "503"}, TS: now.Add(-2 * time.Minute)},
+		{Service: "web", Target: "api", Name: "http_requests", Value: 3, Labels: map[string]string{"method": "POST", "code": "500"}, TS: now.Add(-time.Minute)},
+		{Service: "web", Target: "api", Name: "http_requests", Value: 4, Labels: map[string]string{"method": "GET", "code": "200"}, TS: now},
+	}
+}
+
+func TestQueryHandlerLabelMatchers(t *testing.T) {
+	storage = &mockStorage{metrics: labeledMetrics()}
+
+	w := httptest.NewRecorder()
+	queryHandler(w, httptest.NewRequest("GET", "/api/metrics/query?name=http_requests&label=method=POST&label=code!~5..", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	queryHandler(w, httptest.NewRequest("GET", "/api/metrics/query?name=http_requests&label=code=~5..", nil))
+	var m shared.Metric
+	json.NewDecoder(w.Body).Decode(&m)
+	if w.Code != http.StatusOK || m.Value != 3 {
+		t.Errorf("Expected latest 5xx sample (3), got %d %+v", w.Code, m)
+	}
+
+	w = httptest.NewRecorder()
+	queryHandler(w, httptest.NewRequest("GET", "/api/metrics/query?name=http_requests&label=method", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid matcher, got %d", w.Code)
+	}
+}
+
+func TestQueryRangeHandlerGroupsSeries(t *testing.T) {
+	mock := &mockStorage{metrics: labeledMetrics()}
+	storage = mock
+
+	w := httptest.NewRecorder()
+	queryRangeHandler(w, httptest.NewRequest("GET", "/api/metrics/range?name=http_requests&start=-1h&label=method=~GET|POST", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(mock.lastRange.Matchers) != 1 || mock.lastRange.Matchers[0].Op != "=~" {
+		t.Errorf("Expected matcher to reach storage, got %+v", mock.lastRange.Matchers)
+	}
+
+	var response shared.QueryRangeResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.Series) != 3 {
+		t.Fatalf("Expected 3 series, got %d", len(response.Series))
+	}
+	for _, s := range response.Series {
+		if s.Labels["method"] == "GET" && len(s.Data) != 2 {
+			t.Errorf("Expected GET series with 2 points, got %+v", s)
+		}
+	}
+}
+
+func TestLatestMetricsHandlerLabelMatchers(t *testing.T) {
+	storage = &mockStorage{metrics: labeledMetrics()}
+
+	w := httptest.NewRecorder()
+	latestMetricsHandler(w, httptest.NewRequest("GET", "/api/metrics/latest?label=method=POST", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var groups []struct {
+		Target  string             `json:"target"`
+		Metrics map[string]float64 `json:"metrics"`
+	}
+	json.NewDecoder(w.Body).Decode(&groups)
+	if len(groups) != 1 || groups[0].Metrics["http_requests"] != 3 {
+		t.Errorf("Expected only POST samples (last value 3), got %+v", groups)
+	}
+}
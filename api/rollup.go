@@ -0,0 +1,229 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"argos/shared"
+)
+
+// rollupTier é uma tabela de agregados (min, max, avg, sum, count) por
+// bucket, alimentada pelo tier anterior ou, no primeiro, por metrics
+type rollupTier struct {
+	Name   string
+	Table  string
+	Source string
+	Bucket time.Duration
+	// Lateness é quanto antes do watermark cada rodada reagrega, para
+	// incluir amostras que chegaram atrasadas (filas dos agentes). As mais
+	// antigas que isso passam por lateRollups.
+	Lateness  time.Duration
+	Retention time.Duration
+}
+
+// rollupTiers vai do mais fino ao mais grosso. As retenções são
+// configuráveis por ROLLUP_1M_RETENTION e ROLLUP_1H_RETENTION.
+var rollupTiers = []rollupTier{
+	{Name: "1m", Table: "metrics_1m", Source: "metrics", Bucket: time.Minute, Lateness: 10 * time.Minute, Retention: 90 * 24 * time.Hour},
+	{Name: "1h", Table: "metrics_1h", Source: "metrics_1m", Bucket: time.Hour, Lateness: time.Hour, Retention: 2 * 365 * 24 * time.Hour},
+}
+
+// rollupDelay deixa o minuto corrente de fora, enquanto lotes ainda chegam
+const rollupDelay = time.Minute
+
+// rollupAggregations são os valores de agg que podem ser lidos dos rollups,
+// com a expressão que combina os agregados de cada bucket
+var rollupAggregations = map[string]string{
+	"avg":   "SUM(value_sum) / SUM(value_count)",
+	"min":   "MIN(value_min)",
+	"max":   "MAX(value_max)",
+	"sum":   "SUM(value_sum)",
+	"count": "SUM(value_count)::double precision",
+}
+
+func findRollupTier(name string) (rollupTier, bool) {
+	for _, tier := range rollupTiers {
+		if tier.Name == name {
+			return tier, true
+		}
+	}
+	return rollupTier{}, false
+}
+
+// chooseRollupTier retorna o tier mais grosso cujo bucket divide o step, ou
+// "" para ler metrics. Percentis e last só existem na tabela bruta.
+func chooseRollupTier(q RangeQuery) string {
+	if _, ok := rollupAggregations[q.Agg]; !ok {
+		return ""
+	}
+	for i := len(rollupTiers) - 1; i >= 0; i-- {
+		tier := rollupTiers[i]
+		if q.Step >= tier.Bucket && q.Step%tier.Bucket == 0 {
+			return tier.Name
+		}
+	}
+	return ""
+}
+
+func runRollups(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		rollupOnce(time.Now())
+		<-ticker.C
+	}
+}
+
+// timeRange é o intervalo [From, To)
+type timeRange struct {
+	From, To time.Time
+}
+
+// lateBuckets guarda os buckets do primeiro tier que receberam amostras
+// mais antigas que o Lateness dele. A rodada normal não volta tão longe
+// (um agente pode reenviar a fila de até INGEST_MAX_AGE), então rollupOnce
+// os reagrega à parte. Fica em memória e reinicia vazio com a API.
+type lateBuckets struct {
+	mu      sync.Mutex
+	buckets map[time.Time]bool
+}
+
+var lateRollups = &lateBuckets{buckets: make(map[time.Time]bool)}
+
+// mark registra os buckets das amostras gravadas em now que a rodada normal
+// pode já ter deixado para trás
+func (l *lateBuckets) mark(metrics []shared.Metric, now time.Time) {
+	tier := rollupTiers[0]
+	cutoff := now.Add(-tier.Lateness)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range metrics {
+		if m.TS.Before(cutoff) {
+			l.buckets[m.TS.Truncate(tier.Bucket)] = true
+		}
+	}
+}
+
+// take esvazia o conjunto e retorna os buckets como intervalos contíguos
+func (l *lateBuckets) take() []timeRange {
+	l.mu.Lock()
+	buckets := l.buckets
+	l.buckets = make(map[time.Time]bool)
+	l.mu.Unlock()
+
+	ranges := make([]timeRange, 0, len(buckets))
+	for bucket := range buckets {
+		ranges = append(ranges, timeRange{bucket, bucket.Add(rollupTiers[0].Bucket)})
+	}
+	return mergeRanges(ranges)
+}
+
+// restore devolve intervalos que não puderam ser reagregados
+func (l *lateBuckets) restore(ranges []timeRange) {
+	bucket := rollupTiers[0].Bucket
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range ranges {
+		for t := r.From.Truncate(bucket); t.Before(r.To); t = t.Add(bucket) {
+			l.buckets[t] = true
+		}
+	}
+}
+
+// mergeRanges ordena os intervalos e junta os que se tocam
+func mergeRanges(ranges []timeRange) []timeRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From.Before(ranges[j].From) })
+
+	var merged []timeRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && !r.From.After(merged[n-1].To) {
+			if r.To.After(merged[n-1].To) {
+				merged[n-1].To = r.To
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// rerollLate reagrega no tier os intervalos atrasados que já ficaram atrás
+// do watermark; os demais a rodada normal cobre. Retorna os intervalos
+// alinhados aos buckets do tier, que também mudaram no tier seguinte.
+func rerollLate(tier rollupTier, late []timeRange, watermark time.Time) ([]timeRange, error) {
+	aligned := make([]timeRange, 0, len(late))
+	for _, r := range late {
+		to := r.To.Truncate(tier.Bucket)
+		if to.Before(r.To) {
+			to = to.Add(tier.Bucket)
+		}
+		aligned = append(aligned, timeRange{r.From.Truncate(tier.Bucket), to})
+	}
+	aligned = mergeRanges(aligned)
+
+	for _, r := range aligned {
+		if !r.From.Before(watermark) {
+			continue
+		}
+		if r.To.After(watermark) {
+			r.To = watermark
+		}
+		if err := storage.RollupRange(tier, r.From, r.To); err != nil {
+			return nil, err
+		}
+	}
+	return aligned, nil
+}
+
+// rollupOnce atualiza os tiers em ordem, cada um até onde o anterior está
+// completo, reagrega os buckets que receberam amostras atrasadas e apaga o
+// que passou da retenção
+func rollupOnce(now time.Time) {
+	late := lateRollups.take()
+	taken := late
+
+	upTo := now.Add(-rollupDelay)
+	for _, tier := range rollupTiers {
+		upTo = upTo.Truncate(tier.Bucket)
+
+		watermark, err := catchUpRollup(tier, upTo)
+		if err != nil {
+			log.Printf("Rollup %s failed: %v", tier.Name, err)
+			lateRollups.restore(taken)
+			return
+		}
+
+		if len(late) > 0 {
+			if late, err = rerollLate(tier, late, watermark); err != nil {
+				log.Printf("Rollup %s of late samples failed: %v", tier.Name, err)
+				lateRollups.restore(taken)
+				return
+			}
+		}
+
+		deleted, err := storage.PruneRollups(tier, now.Add(-tier.Retention))
+		if err != nil {
+			log.Printf("Rollup %s retention failed: %v", tier.Name, err)
+		} else if deleted > 0 {
+			log.Printf("Rollup %s: deleted %d rows older than %s", tier.Name, deleted, tier.Retention)
+		}
+
+		upTo = watermark
+	}
+}
+
+// catchUpRollup chama Rollup até o tier alcançar upTo, já que cada chamada
+// processa um trecho limitado
+func catchUpRollup(tier rollupTier, upTo time.Time) (time.Time, error) {
+	for {
+		watermark, err := storage.Rollup(tier, upTo)
+		if err != nil || !watermark.Before(upTo) {
+			return watermark, err
+		}
+	}
+}
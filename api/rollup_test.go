@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+func TestChooseRollupTier(t *testing.T) {
+	tests := []struct {
+		step time.Duration
+		agg  string
+		want string
+	}{
+		{10 * time.Second, "avg", ""},
+		{time.Minute, "avg", "1m"},
+		{90 * time.Second, "avg", ""},
+		{5 * time.Minute, "max", "1m"},
+		{time.Hour, "sum", "1h"},
+		{6 * time.Hour, "count", "1h"},
+		{90 * time.Minute, "min", "1m"},
+		{time.Hour, "p95", ""},
+		{time.Hour, "last", ""},
+	}
+
+	for _, tt := range tests {
+		if got := chooseRollupTier(RangeQuery{Step: tt.step, Agg: tt.agg}); got != tt.want {
+			t.Errorf("step %s agg %s: expected tier %q, got %q", tt.step, tt.agg, tt.want, got)
+		}
+	}
+}
+
+func TestRollupOnceChainsTiers(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	rollupOnce(time.Date(2024, 5, 1, 10, 30, 45, 0, time.UTC))
+
+	want := []string{"1m@2024-05-01T10:29:00Z", "1h@2024-05-01T10:00:00Z"}
+	if len(mock.rollups) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, mock.rollups)
+	}
+	for i := range want {
+		if mock.rollups[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, mock.rollups)
+		}
+	}
+}
+
+func TestRollupOnceRerollsLateSamples(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+	defer func() { lateRollups = &lateBuckets{buckets: make(map[time.Time]bool)} }()
+
+	now := time.Date(2024, 5, 1, 10, 30, 45, 0, time.UTC)
+	lateRollups.mark([]shared.Metric{
+		{TS: now.Add(-time.Minute)},                         // a rodada normal ainda cobre
+		{TS: time.Date(2024, 5, 1, 6, 10, 5, 0, time.UTC)},  // fila de um agente que ficou offline
+		{TS: time.Date(2024, 5, 1, 6, 11, 30, 0, time.UTC)}, // bucket vizinho, mesmo intervalo
+		{TS: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
+	}, now)
+
+	rollupOnce(now)
+
+	want := []string{
+		"1m@2024-05-01T10:29:00Z",
+		"1m@2024-05-01T06:10:00Z/2024-05-01T06:12:00Z",
+		"1m@2024-05-01T08:00:00Z/2024-05-01T08:01:00Z",
+		"1h@2024-05-01T10:00:00Z",
+		"1h@2024-05-01T06:00:00Z/2024-05-01T07:00:00Z",
+		"1h@2024-05-01T08:00:00Z/2024-05-01T09:00:00Z",
+	}
+	if strings.Join(mock.rollups, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, mock.rollups)
+	}
+
+	// Já reagregados, não voltam na rodada seguinte
+	mock.rollups = nil
+	rollupOnce(now.Add(time.Minute))
+	if len(mock.rollups) != 2 {
+		t.Errorf("Expected only the regular rollups, got %v", mock.rollups)
+	}
+}
+
+func TestQueryRangeHandlerUsesRollupTier(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	w := httptest.NewRecorder()
+	queryRangeHandler(w, httptest.NewRequest("GET", "/api/metrics/range?name=http_latency_ms&start=-7d&step=1h&agg=max", nil))
+
+	var response shared.QueryRangeResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if mock.lastRange.Tier != "1h" || response.Tier != "1h" {
+		t.Errorf("Expected 1h tier, got query %q response %q", mock.lastRange.Tier, response.Tier)
+	}
+
+	w = httptest.NewRecorder()
+	queryRangeHandler(w, httptest.NewRequest("GET", "/api/metrics/range?name=http_latency_ms&start=-1h&step=1m&agg=p99", nil))
+	if mock.lastRange.Tier != "" {
+		t.Errorf("Expected raw table for percentiles, got tier %q", mock.lastRange.Tier)
+	}
+}
+
+func TestQueryRangeHandlerLongWindowUsesRollupTier(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+
+	// Sem step, 30d alarga o 1m padrão além do limite de pontos
+	w := httptest.NewRecorder()
+	queryRangeHandler(w, httptest.NewRequest("GET", "/api/metrics/range?name=http_latency_ms&start=-30d", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if mock.lastRange.Tier != "1m" {
+		t.Errorf("Expected 1m tier for a 30d range, got %q (step %s)", mock.lastRange.Tier, mock.lastRange.Step)
+	}
+}
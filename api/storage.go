@@ -1,21 +1,37 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"argos/shared"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type StorageInterface interface {
 	InsertMetrics(agentID string, metrics []shared.Metric) error
-	QueryLatest(name, service, target string) (*shared.Metric, error)
-	QueryRange(name, service, target string, start, end time.Time, step string) ([]shared.DataPoint, error)
-	GetLatestMetrics() ([]shared.Metric, error)
+	InsertBatches(batches []shared.Batch) error
+	QueryLatest(name, service, target string, matchers []LabelMatcher) (*shared.Metric, error)
+	QueryRange(q RangeQuery) (*RangeResult, error)
+	GetLatestMetrics(matchers []LabelMatcher) ([]shared.Metric, error)
+	SelectSeries(q SeriesQuery) ([]shared.Metric, error)
+	Rollup(tier rollupTier, upTo time.Time) (time.Time, error)
+	RollupRange(tier rollupTier, from, to time.Time) error
+	PruneRollups(tier rollupTier, before time.Time) (int64, error)
+	// Partition methods
+	BackfillMetricsPartitions(since time.Time) error
+	ListMetricsPartitions() ([]time.Time, error)
+	CreateMetricsPartition(day time.Time) error
+	DropMetricsPartition(day time.Time) error
+	PruneDefaultPartition(before time.Time) (int64, error)
 	ListServices() ([]string, error)
 	ListTargets(service string) ([]string, error)
 	GetMetricsCount() (int64, error)
@@ -39,6 +55,16 @@ type StorageInterface interface {
 	RecordConfigChange(change *ConfigChange) error
 	GetVulnerabilities() ([]Vulnerability, error)
 	GetTrafficAnomalies(limit int) (int64, error)
+	// Agent auth methods
+	AuthenticateAgentToken(tokenHash string) (string, error)
+	CreateAgentToken(token *AgentToken, tokenHash string) error
+	ListAgentTokens() ([]AgentToken, error)
+	RevokeAgentToken(id int) error
+	// Agent inventory methods
+	RegisterAgent(agent *Agent) error
+	TouchAgent(agentID string, seenAt time.Time, clockSkewMS, clockOffsetMS *float64) error
+	ListAgents() ([]Agent, error)
+	GetAgent(agentID string) (*Agent, error)
 	Close() error
 }
 
@@ -73,33 +99,40 @@ func (s *Storage) Close() error {
 }
 
 func (s *Storage) InsertMetrics(agentID string, metrics []shared.Metric) error {
+	return s.InsertBatches([]shared.Batch{{AgentID: agentID, Items: metrics}})
+}
+
+// InsertBatches grava os lotes com um único COPY, numa transação
+func (s *Storage) InsertBatches(batches []shared.Batch) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO metrics (ts, service, target, name, value, labels, agent_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`)
+	stmt, err := tx.Prepare(pq.CopyIn("metrics", "ts", "service", "target", "name", "value", "labels", "agent_id"))
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	for _, m := range metrics {
-		labelsJSON, _ := json.Marshal(m.Labels)
-		_, err := stmt.Exec(m.TS, m.Service, m.Target, m.Name, m.Value, labelsJSON, agentID)
-		if err != nil {
-			return err
+	for _, batch := range batches {
+		for _, m := range batch.Items {
+			// string, pois []byte seria enviado como bytea
+			labelsJSON, _ := json.Marshal(m.Labels)
+			if _, err := stmt.Exec(m.TS, m.Service, m.Target, m.Name, m.Value, string(labelsJSON), batch.AgentID); err != nil {
+				return err
+			}
 		}
 	}
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
 
-func (s *Storage) QueryLatest(name, service, target string) (*shared.Metric, error) {
+func (s *Storage) QueryLatest(name, service, target string, matchers []LabelMatcher) (*shared.Metric, error) {
 	query := `
 		SELECT ts, service, target, name, value, labels
 		FROM metrics
@@ -108,17 +141,14 @@ func (s *Storage) QueryLatest(name, service, target string) (*shared.Metric, err
 	args := []interface{}{name}
 
 	if service != "" {
-		query += " AND service = $2"
 		args = append(args, service)
+		query += fmt.Sprintf(" AND service = $%d", len(args))
 	}
 	if target != "" {
-		if service != "" {
-			query += " AND target = $3"
-		} else {
-			query += " AND target = $2"
-		}
 		args = append(args, target)
+		query += fmt.Sprintf(" AND target = $%d", len(args))
 	}
+	query, args = appendLabelMatchers(query, args, matchers)
 
 	query += " ORDER BY ts DESC LIMIT 1"
 
@@ -132,70 +162,165 @@ func (s *Storage) QueryLatest(name, service, target string) (*shared.Metric, err
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, matcherQueryError(err)
 	}
 
 	json.Unmarshal(labelsJSON, &m.Labels)
 	return &m, nil
 }
 
-func (s *Storage) QueryRange(name, service, target string, start, end time.Time, step string) ([]shared.DataPoint, error) {
+// RangeQuery descreve uma série temporal agregada em buckets de Step
+// alinhados à época Unix
+type RangeQuery struct {
+	Name     string
+	Service  string
+	Target   string
+	Start    time.Time
+	End      time.Time
+	Step     time.Duration
+	Agg      string
+	Matchers []LabelMatcher
+	// Tier é o rollup lido (1m, 1h); vazio lê a tabela bruta
+	Tier string
+}
+
+// RangeResult traz uma série por conjunto distinto de service, target e
+// labels, e em Data a agregação de todas elas juntas
+type RangeResult struct {
+	Data   []shared.DataPoint
+	Series []shared.Series
+}
+
+// rangeAggregations mapeia o parâmetro agg para a expressão SQL do bucket
+var rangeAggregations = map[string]string{
+	"avg":   "AVG(value)",
+	"min":   "MIN(value)",
+	"max":   "MAX(value)",
+	"sum":   "SUM(value)",
+	"count": "COUNT(*)::double precision",
+	"last":  "(array_agg(value ORDER BY ts DESC))[1]",
+	"p50":   "percentile_cont(0.5) WITHIN GROUP (ORDER BY value)",
+	"p95":   "percentile_cont(0.95) WITHIN GROUP (ORDER BY value)",
+	"p99":   "percentile_cont(0.99) WITHIN GROUP (ORDER BY value)",
+}
+
+func (s *Storage) QueryRange(q RangeQuery) (*RangeResult, error) {
+	aggExpr, ok := rangeAggregations[q.Agg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported aggregation %q", q.Agg)
+	}
+	args := []interface{}{q.Name, q.Start, q.End, q.Step.Seconds()}
+
+	from := "metrics"
+	if q.Tier != "" {
+		tier, ok := findRollupTier(q.Tier)
+		if !ok {
+			return nil, fmt.Errorf("unknown rollup tier %q", q.Tier)
+		}
+		if aggExpr, ok = rollupAggregations[q.Agg]; !ok {
+			return nil, fmt.Errorf("aggregation %q is not available from rollups", q.Agg)
+		}
+
+		// Buckets que o job ainda não agregou vêm da tabela bruta
+		args = append(args, tier.Name)
+		watermark := fmt.Sprintf("(SELECT COALESCE(MAX(rolled_up_to), '-infinity') FROM rollup_state WHERE tier = $%d)", len(args))
+		from = `(
+			SELECT bucket AS ts, service, target, name, labels, value_min, value_max, value_sum, value_count
+			FROM ` + tier.Table + `
+			WHERE bucket < ` + watermark + `
+			UNION ALL
+			SELECT ts, service, target, name, labels, value, value, value, 1
+			FROM metrics
+			WHERE ts >= ` + watermark + `
+		) AS samples`
+	}
+
+	// O grouping set (bucket) dá a agregação geral e o outro, as séries por
+	// labels, com uma única leitura da tabela
 	query := `
 		SELECT
-			date_trunc('minute', ts) AS bucket,
-			AVG(value) AS avg_value
-		FROM metrics
+			GROUPING(service, target, labels) = 0 AS per_series,
+			service, target, labels,
+			to_timestamp(floor(extract(epoch FROM ts) / $4::float8) * $4::float8) AS bucket,
+			` + aggExpr + ` AS value
+		FROM ` + from + `
 		WHERE name = $1
 			AND ts >= $2
 			AND ts <= $3
 	`
-	args := []interface{}{name, start, end}
 
-	if service != "" {
-		query += " AND service = $4"
-		args = append(args, service)
+	if q.Service != "" {
+		args = append(args, q.Service)
+		query += fmt.Sprintf(" AND service = $%d", len(args))
 	}
-	if target != "" {
-		if service != "" {
-			query += " AND target = $5"
-		} else {
-			query += " AND target = $4"
-		}
-		args = append(args, target)
+	if q.Target != "" {
+		args = append(args, q.Target)
+		query += fmt.Sprintf(" AND target = $%d", len(args))
 	}
+	query, args = appendLabelMatchers(query, args, q.Matchers)
 
-	query += " GROUP BY bucket ORDER BY bucket ASC"
+	query += `
+		GROUP BY GROUPING SETS ((bucket), (service, target, labels, bucket))
+		ORDER BY per_series, service, target, labels, bucket ASC`
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, matcherQueryError(err)
 	}
 	defer rows.Close()
 
-	var dataPoints []shared.DataPoint
+	result := &RangeResult{}
+	var current *shared.Series
+	var currentKey string
 	for rows.Next() {
+		var perSeries bool
+		var service, target sql.NullString
+		var labelsJSON []byte
 		var ts time.Time
 		var value float64
-		if err := rows.Scan(&ts, &value); err != nil {
+		if err := rows.Scan(&perSeries, &service, &target, &labelsJSON, &ts, &value); err != nil {
 			return nil, err
 		}
-		dataPoints = append(dataPoints, shared.DataPoint{
-			Timestamp: ts.Unix(),
-			Value:     value,
-		})
+		point := shared.DataPoint{Timestamp: ts.Unix(), Value: value}
+
+		if !perSeries {
+			result.Data = append(result.Data, point)
+			continue
+		}
+
+		key := service.String + "\x00" + target.String + "\x00" + string(labelsJSON)
+		if current == nil || key != currentKey {
+			result.Series = append(result.Series, shared.Series{Service: service.String, Target: target.String})
+			current = &result.Series[len(result.Series)-1]
+			currentKey = key
+			json.Unmarshal(labelsJSON, &current.Labels)
+		}
+		current.Data = append(current.Data, point)
 	}
 
-	return dataPoints, nil
+	return result, matcherQueryError(rows.Err())
 }
 
-func (s *Storage) GetLatestMetrics() ([]shared.Metric, error) {
-	rows, err := s.db.Query(`
+// GetLatestMetrics retorna o último valor de cada (service, target, name).
+// Com matchers, considera apenas as amostras que casam com eles.
+func (s *Storage) GetLatestMetrics(matchers []LabelMatcher) ([]shared.Metric, error) {
+	query := `
 		SELECT name, service, target, value, ts, labels
 		FROM latest_metrics
 		ORDER BY service, target, name
-	`)
+	`
+	var args []interface{}
+	if len(matchers) > 0 {
+		query, args = appendLabelMatchers(`
+			SELECT DISTINCT ON (service, target, name) name, service, target, value, ts, labels
+			FROM metrics
+			WHERE true`, nil, matchers)
+		query += " ORDER BY service, target, name, ts DESC"
+	}
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, matcherQueryError(err)
 	}
 	defer rows.Close()
 
@@ -210,7 +335,203 @@ func (s *Storage) GetLatestMetrics() ([]shared.Metric, error) {
 		metrics = append(metrics, m)
 	}
 
-	return metrics, nil
+	return metrics, matcherQueryError(rows.Err())
+}
+
+// rollupChunk limita quantos buckets uma chamada de Rollup processa
+const rollupChunk = 1000
+
+// Rollup agrega tier.Source em tier.Table a partir do watermark (menos
+// Lateness) até no máximo upTo e retorna o novo watermark. Na primeira vez,
+// começa pela amostra mais antiga da origem.
+func (s *Storage) Rollup(tier rollupTier, upTo time.Time) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	var watermark time.Time
+	err = tx.QueryRow(`SELECT rolled_up_to FROM rollup_state WHERE tier = $1 FOR UPDATE`, tier.Name).Scan(&watermark)
+	from := watermark.Add(-tier.Lateness)
+	if err == sql.ErrNoRows {
+		tsColumn := "bucket"
+		if tier.Source == "metrics" {
+			tsColumn = "ts"
+		}
+		var oldest sql.NullTime
+		if err := tx.QueryRow(`SELECT MIN(` + tsColumn + `) FROM ` + tier.Source).Scan(&oldest); err != nil {
+			return time.Time{}, err
+		}
+		if !oldest.Valid {
+			return upTo, nil
+		}
+		watermark = oldest.Time.Truncate(tier.Bucket)
+		from = watermark
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	if !watermark.Before(upTo) {
+		return watermark, nil
+	}
+	to := watermark.Add(rollupChunk * tier.Bucket)
+	if to.After(upTo) {
+		to = upTo
+	}
+
+	if _, err := tx.Exec(rollupQuery(tier), from, to, tier.Bucket.Seconds()); err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO rollup_state (tier, rolled_up_to) VALUES ($1, $2)
+		ON CONFLICT (tier) DO UPDATE SET rolled_up_to = EXCLUDED.rolled_up_to
+	`, tier.Name, to); err != nil {
+		return time.Time{}, err
+	}
+
+	return to, tx.Commit()
+}
+
+// rollupQuery agrega a origem de tier entre $1 e $2 em buckets de $3
+// segundos, sobrescrevendo os buckets que já existem
+func rollupQuery(tier rollupTier) string {
+	query := `
+		INSERT INTO ` + tier.Table + ` (bucket, service, target, name, labels, value_min, value_max, value_avg, value_sum, value_count)
+		SELECT
+			to_timestamp(floor(extract(epoch FROM bucket) / $3::float8) * $3::float8) AS rollup_bucket,
+			service, target, name, labels,
+			MIN(value_min), MAX(value_max), SUM(value_sum) / SUM(value_count), SUM(value_sum), SUM(value_count)
+		FROM ` + tier.Source + `
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY rollup_bucket, service, target, name, labels`
+	if tier.Source == "metrics" {
+		query = `
+		INSERT INTO ` + tier.Table + ` (bucket, service, target, name, labels, value_min, value_max, value_avg, value_sum, value_count)
+		SELECT
+			to_timestamp(floor(extract(epoch FROM ts) / $3::float8) * $3::float8) AS rollup_bucket,
+			service, target, name, labels,
+			MIN(value), MAX(value), AVG(value), SUM(value), COUNT(*)
+		FROM metrics
+		WHERE ts >= $1 AND ts < $2
+		GROUP BY rollup_bucket, service, target, name, labels`
+	}
+	query += `
+		ON CONFLICT (name, service, target, labels, bucket) DO UPDATE SET
+			value_min = EXCLUDED.value_min,
+			value_max = EXCLUDED.value_max,
+			value_avg = EXCLUDED.value_avg,
+			value_sum = EXCLUDED.value_sum,
+			value_count = EXCLUDED.value_count`
+	return query
+}
+
+// RollupRange reagrega os buckets de tier em [from, to) sem mexer no
+// watermark, para amostras que chegaram depois da rodada normal
+func (s *Storage) RollupRange(tier rollupTier, from, to time.Time) error {
+	_, err := s.db.Exec(rollupQuery(tier), from, to, tier.Bucket.Seconds())
+	return err
+}
+
+// PruneRollups apaga os buckets do tier anteriores a before
+func (s *Storage) PruneRollups(tier rollupTier, before time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM `+tier.Table+` WHERE bucket < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// errInvalidMatcherRegex indica uma regex de matcher que o Postgres recusou
+var errInvalidMatcherRegex = errors.New("invalid label matcher regex")
+
+// matcherQueryError troca o erro de regex inválida do Postgres (2201B) por
+// errInvalidMatcherRegex, que os handlers respondem com 400
+func matcherQueryError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "2201B" {
+		return fmt.Errorf("%w: %s", errInvalidMatcherRegex, pqErr.Message)
+	}
+	return err
+}
+
+// SeriesQuery seleciona séries brutas para a API compatível com Prometheus.
+// Matchers em __name__, service e target se referem às colunas; os demais,
+// aos labels.
+type SeriesQuery struct {
+	Matchers []LabelMatcher
+	Start    time.Time
+	End      time.Time
+	// Samples inclui as amostras; sem ele, retorna uma linha por série
+	Samples bool
+	// Limit, se positivo, limita as linhas retornadas
+	Limit int
+}
+
+// seriesColumns são os labels da API Prometheus que vêm de colunas
+var seriesColumns = map[string]string{"__name__": "name", "service": "service", "target": "target"}
+
+// SelectSeries retorna as amostras de [Start, End] ordenadas por série e ts.
+// Com q.Samples false, retorna uma linha por série, com Value e TS zerados.
+// Matchers em labels exported_* não são aplicados aqui; quem chama filtra.
+func (s *Storage) SelectSeries(q SeriesQuery) ([]shared.Metric, error) {
+	query := `SELECT DISTINCT name, service, target, labels`
+	order := " ORDER BY name, service, target, labels"
+	if q.Samples {
+		query = `SELECT name, service, target, labels, value, ts`
+		order += ", ts"
+	}
+	query += `
+		FROM metrics
+		WHERE ts >= $1 AND ts <= $2`
+	args := []interface{}{q.Start, q.End}
+
+	for _, m := range q.Matchers {
+		if column, ok := seriesColumns[m.Name]; ok {
+			query, args = appendMatcherCondition(query, args, column, m)
+		} else if !strings.HasPrefix(m.Name, "exported_") {
+			query, args = appendLabelMatchers(query, args, []LabelMatcher{m})
+		}
+	}
+
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		order += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.Query(query+order, args...)
+	if err != nil {
+		return nil, matcherQueryError(err)
+	}
+	defer rows.Close()
+
+	// As linhas vêm agrupadas por série: as amostras seguidas da mesma série
+	// compartilham strings e o mapa de labels, que não deve ser alterado,
+	// para que a memória cresça com o número de séries e não de amostras
+	var metrics []shared.Metric
+	var prev shared.Metric
+	var prevLabelsJSON []byte
+	for rows.Next() {
+		var m shared.Metric
+		var labelsJSON []byte
+		dest := []interface{}{&m.Name, &m.Service, &m.Target, &labelsJSON}
+		if q.Samples {
+			dest = append(dest, &m.Value, &m.TS)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if len(metrics) > 0 && m.Name == prev.Name && m.Service == prev.Service && m.Target == prev.Target && bytes.Equal(labelsJSON, prevLabelsJSON) {
+			m.Name, m.Service, m.Target, m.Labels = prev.Name, prev.Service, prev.Target, prev.Labels
+		} else {
+			json.Unmarshal(labelsJSON, &m.Labels)
+			prev, prevLabelsJSON = m, labelsJSON
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, matcherQueryError(rows.Err())
 }
 
 func (s *Storage) ListServices() ([]string, error) {
@@ -255,6 +576,151 @@ func (s *Storage) ListTargets(service string) ([]string, error) {
 	return targets, nil
 }
 
+func metricsPartitionName(day time.Time) string {
+	return "metrics_p" + day.UTC().Format("20060102")
+}
+
+// BackfillMetricsPartitions move as amostras desde since de
+// metrics_unpartitioned, a tabela antiga que a migration 0006 deixa de lado,
+// para as partições diárias, um dia por comando, e
+// então descarta a tabela antiga com as amostras mais velhas que since. Cada
+// dia movido sai da tabela antiga, então um backfill interrompido continua
+// de onde parou.
+func (s *Storage) BackfillMetricsPartitions(since time.Time) error {
+	var pending bool
+	if err := s.db.QueryRow(`SELECT to_regclass('metrics_unpartitioned') IS NOT NULL`).Scan(&pending); err != nil {
+		return err
+	}
+	if !pending {
+		return nil
+	}
+
+	var newest sql.NullTime
+	if err := s.db.QueryRow(`SELECT MAX(ts) FROM metrics_unpartitioned`).Scan(&newest); err != nil {
+		return err
+	}
+	if newest.Valid {
+		days, err := s.ListMetricsPartitions()
+		if err != nil {
+			return err
+		}
+		existing := make(map[time.Time]bool, len(days))
+		for _, day := range days {
+			existing[day] = true
+		}
+
+		for day := since.UTC().Truncate(24 * time.Hour); !day.After(newest.Time); day = day.AddDate(0, 0, 1) {
+			if !existing[day] {
+				if err := s.CreateMetricsPartition(day); err != nil && !s.hasMetricsPartition(day) {
+					// Sem a partição as amostras cairiam na default
+					return fmt.Errorf("create partition for %s: %w", day.Format("2006-01-02"), err)
+				}
+			}
+
+			from := day
+			if from.Before(since) {
+				from = since
+			}
+			if _, err := s.db.Exec(`
+				WITH moved AS (
+					DELETE FROM metrics_unpartitioned WHERE ts >= $1 AND ts < $2
+					RETURNING ts, service, target, name, value, labels, agent_id
+				)
+				INSERT INTO metrics (ts, service, target, name, value, labels, agent_id)
+				SELECT * FROM moved
+			`, from, day.AddDate(0, 0, 1)); err != nil {
+				return fmt.Errorf("copy metrics for %s: %w", day.Format("2006-01-02"), err)
+			}
+		}
+	}
+
+	_, err := s.db.Exec(`DROP TABLE IF EXISTS metrics_unpartitioned`)
+	return err
+}
+
+// hasMetricsPartition diz se a partição de day existe, por exemplo criada
+// pelo gerenciador de partições ao mesmo tempo que o backfill
+func (s *Storage) hasMetricsPartition(day time.Time) bool {
+	var exists bool
+	s.db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, metricsPartitionName(day)).Scan(&exists)
+	return exists
+}
+
+// ListMetricsPartitions retorna o dia (UTC) de cada partição diária
+func (s *Storage) ListMetricsPartitions() ([]time.Time, error) {
+	rows, err := s.db.Query(`
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'metrics'::regclass
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if day, err := time.Parse("20060102", strings.TrimPrefix(name, "metrics_p")); err == nil {
+			days = append(days, day)
+		}
+	}
+	return days, rows.Err()
+}
+
+// CreateMetricsPartition cria a partição do dia, movendo para ela as
+// amostras do dia que estejam na partição default
+func (s *Storage) CreateMetricsPartition(day time.Time) error {
+	day = day.UTC()
+	name := metricsPartitionName(day)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`CREATE TABLE ` + name + ` (LIKE metrics INCLUDING DEFAULTS)`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		WITH moved AS (
+			DELETE FROM metrics_default WHERE ts >= $1 AND ts < $2
+			RETURNING ts, service, target, name, value, labels, agent_id
+		)
+		INSERT INTO `+name+` SELECT * FROM moved
+	`, day, day.AddDate(0, 0, 1)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE metrics ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`,
+		name, day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339))); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) DropMetricsPartition(day time.Time) error {
+	_, err := s.db.Exec(`DROP TABLE IF EXISTS ` + metricsPartitionName(day))
+	return err
+}
+
+// PruneDefaultPartition apaga da partição default as amostras anteriores a
+// before e retorna quantas restam nela
+func (s *Storage) PruneDefaultPartition(before time.Time) (int64, error) {
+	if _, err := s.db.Exec(`DELETE FROM metrics_default WHERE ts < $1`, before); err != nil {
+		return 0, err
+	}
+	var remaining int64
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM metrics_default`).Scan(&remaining)
+	return remaining, err
+}
+
 func (s *Storage) GetMetricsCount() (int64, error) {
 	var count int64
 	err := s.db.QueryRow("SELECT COUNT(*) FROM metrics").Scan(&count)
@@ -614,3 +1080,346 @@ func (s *Storage) GetTrafficAnomalies(limit int) (int64, error) {
 	`).Scan(&count)
 	return count, err
 }
+
+// Agent auth methods
+
+// agentTokenTouchInterval limita a frequência com que a autenticação grava
+// last_used_at, que senão seria um UPDATE por lote recebido
+const agentTokenTouchInterval = time.Minute
+
+// AuthenticateAgentToken retorna o agent_id dono do token, ou "" se o token
+// não existir ou estiver revogado. Também atualiza last_used_at, no máximo
+// uma vez por agentTokenTouchInterval.
+func (s *Storage) AuthenticateAgentToken(tokenHash string) (string, error) {
+	var agentID string
+	err := s.db.QueryRow(`
+		WITH token AS (
+			SELECT id, agent_id, last_used_at FROM agent_tokens
+			WHERE token_hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE agent_tokens SET last_used_at = NOW()
+			FROM token
+			WHERE agent_tokens.id = token.id
+				AND (token.last_used_at IS NULL OR token.last_used_at < NOW() - make_interval(secs => $2))
+		)
+		SELECT agent_id FROM token
+	`, tokenHash, agentTokenTouchInterval.Seconds()).Scan(&agentID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return agentID, err
+}
+
+// errAgentTokenNotFound indica um token inexistente ou já revogado
+var errAgentTokenNotFound = errors.New("agent token not found")
+
+func (s *Storage) CreateAgentToken(token *AgentToken, tokenHash string) error {
+	return s.db.QueryRow(`
+		INSERT INTO agent_tokens (agent_id, token_hash, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, token.AgentID, tokenHash, token.Description).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *Storage) ListAgentTokens() ([]AgentToken, error) {
+	rows, err := s.db.Query(`
+		SELECT id, agent_id, description, created_at, last_used_at, revoked_at
+		FROM agent_tokens
+		ORDER BY agent_id, created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []AgentToken
+	for rows.Next() {
+		var t AgentToken
+		if err := rows.Scan(&t.ID, &t.AgentID, &t.Description, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func (s *Storage) RevokeAgentToken(id int) error {
+	result, err := s.db.Exec(`
+		UPDATE agent_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return errAgentTokenNotFound
+	}
+
+	return nil
+}
+
+// Agent inventory methods
+
+// Agent é um agente conhecido pela API, criado no registro ou no primeiro
+// heartbeat/lote recebido
+type Agent struct {
+	AgentID      string              `json:"agent_id"`
+	Version      string              `json:"version"`
+	Hostname     string              `json:"hostname"`
+	Probes       []shared.AgentProbe `json:"probes"`
+	StartedAt    *time.Time          `json:"started_at"`
+	RegisteredAt time.Time           `json:"registered_at"`
+	LastSeenAt   time.Time           `json:"last_seen_at"`
+	// ClockSkewMS é o horário de recebimento menos o TS enviado pelo agente
+	ClockSkewMS *float64 `json:"clock_skew_ms"`
+	// ClockOffsetMS é o offset NTP medido pelo próprio agente
+	ClockOffsetMS *float64 `json:"clock_offset_ms"`
+	Up            bool     `json:"up"`
+}
+
+const agentColumns = `agent_id, version, hostname, probes, started_at, registered_at,
+	last_seen_at, clock_skew_ms, clock_offset_ms`
+
+// RegisterAgent grava o inventário do agente, criando-o se necessário
+func (s *Storage) RegisterAgent(agent *Agent) error {
+	probesJSON, _ := json.Marshal(agent.Probes)
+	return s.db.QueryRow(`
+		INSERT INTO agents (agent_id, version, hostname, probes, started_at, last_seen_at, clock_skew_ms, clock_offset_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (agent_id) DO UPDATE SET
+			version = EXCLUDED.version,
+			hostname = EXCLUDED.hostname,
+			probes = EXCLUDED.probes,
+			started_at = EXCLUDED.started_at,
+			last_seen_at = GREATEST(agents.last_seen_at, EXCLUDED.last_seen_at),
+			clock_skew_ms = COALESCE(EXCLUDED.clock_skew_ms, agents.clock_skew_ms),
+			clock_offset_ms = COALESCE(EXCLUDED.clock_offset_ms, agents.clock_offset_ms)
+		RETURNING registered_at
+	`, agent.AgentID, agent.Version, agent.Hostname, probesJSON, agent.StartedAt,
+		agent.LastSeenAt, agent.ClockSkewMS, agent.ClockOffsetMS).
+		Scan(&agent.RegisteredAt)
+}
+
+// TouchAgent marca o agente como visto em seenAt. Skew e offset nil mantêm
+// os valores anteriores.
+func (s *Storage) TouchAgent(agentID string, seenAt time.Time, clockSkewMS, clockOffsetMS *float64) error {
+	_, err := s.db.Exec(`
+		INSERT INTO agents (agent_id, last_seen_at, clock_skew_ms, clock_offset_ms)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (agent_id) DO UPDATE SET
+			last_seen_at = GREATEST(agents.last_seen_at, EXCLUDED.last_seen_at),
+			clock_skew_ms = COALESCE(EXCLUDED.clock_skew_ms, agents.clock_skew_ms),
+			clock_offset_ms = COALESCE(EXCLUDED.clock_offset_ms, agents.clock_offset_ms)
+	`, agentID, seenAt, clockSkewMS, clockOffsetMS)
+	return err
+}
+
+func (s *Storage) ListAgents() ([]Agent, error) {
+	rows, err := s.db.Query(`SELECT ` + agentColumns + ` FROM agents ORDER BY agent_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []Agent
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, *a)
+	}
+	return agents, rows.Err()
+}
+
+func (s *Storage) GetAgent(agentID string) (*Agent, error) {
+	a, err := scanAgent(s.db.QueryRow(`SELECT `+agentColumns+` FROM agents WHERE agent_id = $1`, agentID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+func scanAgent(row interface{ Scan(dest ...any) error }) (*Agent, error) {
+	var a Agent
+	var probesJSON []byte
+	if err := row.Scan(&a.AgentID, &a.Version, &a.Hostname, &probesJSON, &a.StartedAt,
+		&a.RegisteredAt, &a.LastSeenAt, &a.ClockSkewMS, &a.ClockOffsetMS); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(probesJSON, &a.Probes)
+	return &a, nil
+}
+
+// migrationLockKey identifica o advisory lock das migrations ("argos")
+const migrationLockKey = 0x6172676f73
+
+const migrationsTableDDL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)
+`
+
+// withMigrationLock roda fn numa conexão que segura o advisory lock, para
+// que duas instâncias da API não migrem ao mesmo tempo
+func (s *Storage) withMigrationLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, migrationsTableDDL); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func appliedMigrations(conn *sql.Conn) (map[int]migrationStatus, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]migrationStatus)
+	for rows.Next() {
+		var m migrationStatus
+		var at time.Time
+		if err := rows.Scan(&m.Version, &m.Name, &at); err != nil {
+			return nil, err
+		}
+		m.AppliedAt = &at
+		applied[m.Version] = m
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus lista as migrations conhecidas e as aplicadas no banco,
+// inclusive as de versões mais novas da API
+func (s *Storage) MigrationStatus(migrations []migration) ([]migrationStatus, error) {
+	var status []migrationStatus
+	err := s.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			st := migrationStatus{migration: m}
+			if a, ok := applied[m.Version]; ok {
+				st.AppliedAt = a.AppliedAt
+				delete(applied, m.Version)
+			}
+			status = append(status, st)
+		}
+		for _, a := range applied {
+			status = append(status, a)
+		}
+		sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+		return nil
+	})
+	return status, err
+}
+
+// MigrateUp aplica, em ordem, as migrations pendentes até a versão to (0
+// para todas). Cada uma roda numa transação junto com seu registro em
+// schema_migrations; retorna as que foram aplicadas até um eventual erro.
+func (s *Storage) MigrateUp(migrations []migration, to int) ([]migration, error) {
+	var done []migration
+	err := s.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if to > 0 && m.Version > to {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := runMigration(conn, m.Up, func(tx *sql.Tx) error {
+				_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+				return err
+			}); err != nil {
+				return fmt.Errorf("%04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverte as steps migrations aplicadas mais recentes. Para na
+// primeira sem down ou desconhecida por este binário.
+func (s *Storage) MigrateDown(migrations []migration, steps int) ([]migration, error) {
+	known := make(map[int]migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	var done []migration
+	err := s.withMigrationLock(func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, v := range versions {
+			if len(done) == steps {
+				break
+			}
+			m, ok := known[v]
+			if !ok {
+				return fmt.Errorf("%04d_%s is not known to this binary", v, applied[v].Name)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("%04d_%s is irreversible", m.Version, m.Name)
+			}
+			if err := runMigration(conn, m.Down, func(tx *sql.Tx) error {
+				_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("%04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func runMigration(conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -24,7 +24,8 @@ func setupTestDB(t *testing.T) *Storage {
 	_, err = db.Exec(`
 		DROP TABLE IF EXISTS notifications CASCADE;
 		DROP TABLE IF EXISTS alerts CASCADE;
-		DROP TABLE IF EXISTS metrics CASCADE;
+		DROP TABLE IF EXISTS metrics, metrics_unpartitioned CASCADE;
+		DROP TABLE IF EXISTS metrics_1m, metrics_1h, rollup_state CASCADE;
 	`)
 	if err != nil {
 		t.Fatalf("Failed to clean database: %v", err)
@@ -43,6 +44,25 @@ func setupTestDB(t *testing.T) *Storage {
 		CREATE INDEX idx_metrics_ts ON metrics (ts DESC);
 		CREATE INDEX idx_metrics_by_name ON metrics (name, service, target, ts DESC);
 
+		CREATE TABLE metrics_1m (
+			bucket TIMESTAMPTZ NOT NULL,
+			service TEXT NOT NULL,
+			target TEXT NOT NULL,
+			name TEXT NOT NULL,
+			labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+			value_min DOUBLE PRECISION NOT NULL,
+			value_max DOUBLE PRECISION NOT NULL,
+			value_avg DOUBLE PRECISION NOT NULL,
+			value_sum DOUBLE PRECISION NOT NULL,
+			value_count BIGINT NOT NULL,
+			PRIMARY KEY (name, service, target, labels, bucket)
+		);
+		CREATE TABLE metrics_1h (LIKE metrics_1m INCLUDING ALL);
+		CREATE TABLE rollup_state (
+			tier TEXT PRIMARY KEY,
+			rolled_up_to TIMESTAMPTZ NOT NULL
+		);
+
 		CREATE TABLE alerts (
 			id SERIAL PRIMARY KEY,
 			name TEXT NOT NULL,
@@ -120,7 +140,7 @@ func TestQueryLatest(t *testing.T) {
 
 	storage.InsertMetrics("agent-01", metrics)
 
-	latest, err := storage.QueryLatest("http_latency_ms", "web", "site")
+	latest, err := storage.QueryLatest("http_latency_ms", "web", "site", nil)
 	if err != nil {
 		t.Fatalf("QueryLatest failed: %v", err)
 	}
@@ -137,7 +157,7 @@ func TestQueryLatest(t *testing.T) {
 func TestQueryLatestNotFound(t *testing.T) {
 	storage := setupTestDB(t)
 
-	latest, err := storage.QueryLatest("nonexistent", "web", "site")
+	latest, err := storage.QueryLatest("nonexistent", "web", "site", nil)
 	if err != nil {
 		t.Fatalf("QueryLatest failed: %v", err)
 	}
@@ -163,14 +183,101 @@ func TestQueryRange(t *testing.T) {
 	start := now.Add(-15 * time.Minute)
 	end := now
 
-	dataPoints, err := storage.QueryRange("http_latency_ms", "web", "site", start, end, "1m")
+	result, err := storage.QueryRange(RangeQuery{
+		Name: "http_latency_ms", Service: "web", Target: "site",
+		Start: start, End: end, Step: time.Minute, Agg: "avg",
+	})
 	if err != nil {
 		t.Fatalf("QueryRange failed: %v", err)
 	}
 
-	if len(dataPoints) == 0 {
+	if len(result.Data) == 0 {
 		t.Error("Expected data points, got empty slice")
 	}
+	if len(result.Series) != 1 {
+		t.Errorf("Expected one series, got %d", len(result.Series))
+	}
+}
+
+func TestRollupTieredRange(t *testing.T) {
+	storage := setupTestDB(t)
+
+	now := time.Now().Truncate(time.Hour)
+	var metrics []shared.Metric
+	for ts := now.Add(-3 * time.Hour); ts.Before(now.Add(30 * time.Minute)); ts = ts.Add(10 * time.Second) {
+		metrics = append(metrics, shared.Metric{Service: "web", Target: "site", Name: "http_latency_ms", Value: 10, TS: ts})
+	}
+	if err := storage.InsertMetrics("agent-01", metrics); err != nil {
+		t.Fatalf("InsertMetrics failed: %v", err)
+	}
+
+	watermark, err := storage.Rollup(rollupTiers[0], now)
+	if err != nil || !watermark.Equal(now) {
+		t.Fatalf("Rollup 1m failed: %v (watermark %v)", err, watermark)
+	}
+	if _, err := storage.Rollup(rollupTiers[1], now); err != nil {
+		t.Fatalf("Rollup 1h failed: %v", err)
+	}
+
+	result, err := storage.QueryRange(RangeQuery{
+		Name: "http_latency_ms", Start: now.Add(-3 * time.Hour), End: now.Add(time.Hour),
+		Step: time.Hour, Agg: "count", Tier: "1h",
+	})
+	if err != nil {
+		t.Fatalf("QueryRange failed: %v", err)
+	}
+
+	// Três horas agregadas e a meia hora seguinte lida da tabela bruta
+	if len(result.Data) != 4 || result.Data[0].Value != 360 || result.Data[3].Value != 180 {
+		t.Errorf("Unexpected tiered result %+v", result.Data)
+	}
+}
+
+func TestMetricsPartitioning(t *testing.T) {
+	storage := setupTestDB(t)
+
+	now := time.Now()
+	storage.InsertMetrics("agent-01", []shared.Metric{
+		{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: now.Add(-48 * time.Hour)},
+		{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: now},
+	})
+
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Name == "partition_metrics" {
+			if _, err := storage.db.Exec(m.Up); err != nil {
+				t.Fatalf("Migration %04d_%s failed: %v", m.Version, m.Name, err)
+			}
+		}
+	}
+	if err := storage.BackfillMetricsPartitions(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("BackfillMetricsPartitions failed: %v", err)
+	}
+	// A amostra de 48h atrás, fora da retenção, não é copiada
+	if count, _ := storage.GetMetricsCount(); count != 1 {
+		t.Errorf("Expected 1 metric after migration, got %d", count)
+	}
+
+	tomorrow := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	storage.InsertMetrics("agent-01", []shared.Metric{
+		{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: tomorrow.Add(time.Hour)},
+	})
+	if err := storage.CreateMetricsPartition(tomorrow); err != nil {
+		t.Fatalf("CreateMetricsPartition failed: %v", err)
+	}
+
+	days, err := storage.ListMetricsPartitions()
+	if err != nil || len(days) != 3 {
+		t.Fatalf("Expected 3 daily partitions, got %v (%v)", days, err)
+	}
+
+	remaining, err := storage.PruneDefaultPartition(now.Add(-24 * time.Hour))
+	if err != nil || remaining != 0 {
+		t.Errorf("Expected empty default partition, got %d (%v)", remaining, err)
+	}
 }
 
 func TestListServices(t *testing.T) {
@@ -243,3 +350,47 @@ func TestGetMetricsCount(t *testing.T) {
 	}
 }
 
+
+func TestMigrations(t *testing.T) {
+	storage := setupTestDB(t)
+	if _, err := storage.db.Exec(`DROP TABLE IF EXISTS schema_migrations`); err != nil {
+		t.Fatalf("Failed to clean migrations: %v", err)
+	}
+
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1]
+
+	applied, err := storage.MigrateUp(migrations, 0)
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("Expected %d migrations applied, got %d (%v)", len(migrations), len(applied), err)
+	}
+	if applied, _ := storage.MigrateUp(migrations, 0); len(applied) != 0 {
+		t.Errorf("Expected no pending migrations, got %d", len(applied))
+	}
+
+	// cleanup_old_metrics só roda com as colunas certas
+	if _, err := storage.db.Exec(`SELECT cleanup_old_metrics()`); err != nil {
+		t.Errorf("cleanup_old_metrics failed: %v", err)
+	}
+
+	reverted, err := storage.MigrateDown(migrations, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != latest.Version {
+		t.Fatalf("Expected %04d reverted, got %v (%v)", latest.Version, reverted, err)
+	}
+
+	status, err := storage.MigrationStatus(migrations)
+	if err != nil || len(status) != len(migrations) || status[len(status)-1].AppliedAt != nil {
+		t.Fatalf("Expected latest migration pending, got %+v (%v)", status, err)
+	}
+
+	// A baseline não tem down
+	if _, err := storage.MigrateDown(migrations, len(migrations)); err == nil {
+		t.Error("Expected error reverting the baseline")
+	}
+	if _, err := storage.MigrateUp(migrations, 0); err != nil {
+		t.Fatalf("MigrateUp after down failed: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"argos/shared"
+)
+
+// ingestLimits são configuráveis por INGEST_MAX_BATCH, INGEST_MAX_BODY_BYTES,
+// INGEST_MAX_AGE, INGEST_MAX_FUTURE e INGEST_MAX_SERIES_PER_AGENT
+type ingestLimits struct {
+	MaxBatch     int
+	MaxBodyBytes int64
+	// MaxAge cobre o tempo que um lote pode passar na fila do agente
+	MaxAge    time.Duration
+	MaxFuture time.Duration
+	// MaxSeriesPerAgent limita as séries ativas de cada agent_id
+	MaxSeriesPerAgent int
+}
+
+var limits = ingestLimits{
+	MaxBatch:          10000,
+	MaxBodyBytes:      10 << 20,
+	MaxAge:            24 * time.Hour,
+	MaxFuture:         5 * time.Minute,
+	MaxSeriesPerAgent: 10000,
+}
+
+const (
+	maxNameLength       = 200
+	maxLabelsPerMetric  = 32
+	maxLabelValueLength = 2048
+	// maxIngestErrors limita os erros por item devolvidos numa resposta
+	maxIngestErrors = 100
+	// seriesIdleTimeout é quanto tempo uma série sem amostras ainda conta
+	// no limite do agente
+	seriesIdleTimeout = time.Hour
+)
+
+// Motivos de rejeição, usados nos contadores e nos erros por item
+const (
+	rejectInvalidName    = "invalid_name"
+	rejectInvalidService = "invalid_service"
+	rejectInvalidLabel   = "invalid_label"
+	rejectInvalidValue   = "invalid_value"
+	rejectOutOfWindow    = "out_of_window"
+	rejectSeriesLimit    = "series_limit"
+)
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// validateMetric retorna o motivo e a mensagem se m não puder ser gravada
+func validateMetric(m shared.Metric, now time.Time) (string, error) {
+	if len(m.Name) > maxNameLength || !metricNamePattern.MatchString(m.Name) {
+		return rejectInvalidName, fmt.Errorf("invalid metric name %q", m.Name)
+	}
+	if m.Service == "" {
+		return rejectInvalidService, fmt.Errorf("empty service")
+	}
+	if len(m.Service) > maxNameLength || !utf8.ValidString(m.Service) {
+		return rejectInvalidService, fmt.Errorf("invalid service %q", m.Service)
+	}
+	if len(m.Target) > maxNameLength || !utf8.ValidString(m.Target) {
+		return rejectInvalidService, fmt.Errorf("invalid target %q", m.Target)
+	}
+
+	if len(m.Labels) > maxLabelsPerMetric {
+		return rejectInvalidLabel, fmt.Errorf("%d labels, at most %d allowed", len(m.Labels), maxLabelsPerMetric)
+	}
+	for k, v := range m.Labels {
+		if len(k) > maxNameLength || !labelNamePattern.MatchString(k) {
+			return rejectInvalidLabel, fmt.Errorf("invalid label name %q", k)
+		}
+		if len(v) > maxLabelValueLength || !utf8.ValidString(v) {
+			return rejectInvalidLabel, fmt.Errorf("label %s: invalid or too long value (%d bytes)", k, len(v))
+		}
+	}
+
+	if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+		return rejectInvalidValue, fmt.Errorf("value %v is not finite", m.Value)
+	}
+
+	if m.TS.Before(now.Add(-limits.MaxAge)) || m.TS.After(now.Add(limits.MaxFuture)) {
+		return rejectOutOfWindow, fmt.Errorf("timestamp %s outside the accepted window (-%s, +%s)",
+			m.TS.Format(time.RFC3339), limits.MaxAge, limits.MaxFuture)
+	}
+	return "", nil
+}
+
+// seriesTracker guarda, em memória, as séries vistas por agente e quando
+// receberam a última amostra. Reinicia vazio com a API.
+type seriesTracker struct {
+	mu     sync.Mutex
+	agents map[string]map[string]time.Time
+}
+
+var activeSeries = &seriesTracker{agents: make(map[string]map[string]time.Time)}
+
+// admit registra a série e diz se cabe no limite do agente e se ela é nova.
+// Séries já conhecidas sempre passam; uma nova só entra se houver espaço,
+// depois de esquecer as ociosas.
+func (t *seriesTracker) admit(agentID, signature string, now time.Time) (admitted, added bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	known, ok := t.agents[agentID]
+	if !ok {
+		known = make(map[string]time.Time)
+		t.agents[agentID] = known
+	}
+	if _, ok := known[signature]; ok {
+		known[signature] = now
+		return true, false
+	}
+
+	if len(known) >= limits.MaxSeriesPerAgent {
+		for sig, seen := range known {
+			if now.Sub(seen) > seriesIdleTimeout {
+				delete(known, sig)
+			}
+		}
+		if len(known) >= limits.MaxSeriesPerAgent {
+			return false, false
+		}
+	}
+	known[signature] = now
+	return true, true
+}
+
+// release esquece as séries que admit acrescentou em now para um lote que
+// não foi gravado. Uma série que outro lote usou depois disso fica.
+func (t *seriesTracker) release(agentID string, signatures []string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	known := t.agents[agentID]
+	for _, sig := range signatures {
+		if seen, ok := known[sig]; ok && seen.Equal(now) {
+			delete(known, sig)
+		}
+	}
+}
+
+// count retorna as séries ativas de cada agente
+func (t *seriesTracker) count() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := make(map[string]int, len(t.agents))
+	for agentID, known := range t.agents {
+		counts[agentID] = len(known)
+	}
+	return counts
+}
+
+// rejectedSamples conta as amostras recusadas por motivo
+var rejectedSamples = struct {
+	sync.Mutex
+	byReason map[string]int64
+}{byReason: make(map[string]int64)}
+
+func countRejected(reason string) {
+	rejectedSamples.Lock()
+	rejectedSamples.byReason[reason]++
+	rejectedSamples.Unlock()
+}
+
+func rejectedCounts() map[string]int64 {
+	rejectedSamples.Lock()
+	defer rejectedSamples.Unlock()
+
+	counts := make(map[string]int64, len(rejectedSamples.byReason))
+	for reason, n := range rejectedSamples.byReason {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// filterBatch separa as amostras válidas do lote. Os erros vêm na ordem dos
+// itens, limitados a maxIngestErrors; rejected conta todos. added são as
+// séries novas no limite do agente, a liberar com activeSeries.release se o
+// lote não for gravado.
+func filterBatch(batch shared.Batch, now time.Time) (valid []shared.Metric, errs []shared.IngestItemError, rejected int, added []string) {
+	valid = make([]shared.Metric, 0, len(batch.Items))
+	for i, m := range batch.Items {
+		reason, err := validateMetric(m, now)
+		if err == nil {
+			signature := promSignature(promMetricLabels(m))
+			admitted, isNew := activeSeries.admit(batch.AgentID, signature, now)
+			if !admitted {
+				reason = rejectSeriesLimit
+				err = fmt.Errorf("agent %q reached the limit of %d active series", batch.AgentID, limits.MaxSeriesPerAgent)
+			} else if isNew {
+				added = append(added, signature)
+			}
+		}
+		if err == nil {
+			valid = append(valid, m)
+			continue
+		}
+
+		rejected++
+		countRejected(reason)
+		if len(errs) < maxIngestErrors {
+			errs = append(errs, shared.IngestItemError{Index: i, Name: m.Name, Reason: reason, Error: err.Error()})
+		}
+	}
+	return valid, errs, rejected, added
+}
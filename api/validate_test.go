@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"argos/shared"
+)
+
+func TestValidateMetric(t *testing.T) {
+	now := time.Now()
+	valid := shared.Metric{Service: "web", Target: "site", Name: "http_up", Value: 1, Labels: map[string]string{"method": "GET"}, TS: now}
+	if reason, err := validateMetric(valid, now); err != nil {
+		t.Fatalf("Unexpected rejection %s: %v", reason, err)
+	}
+
+	tests := map[string]struct {
+		mutate func(m *shared.Metric)
+		reason string
+	}{
+		"empty name":       {func(m *shared.Metric) { m.Name = "" }, rejectInvalidName},
+		"name with dash":   {func(m *shared.Metric) { m.Name = "http-up" }, rejectInvalidName},
+		"leading digit":    {func(m *shared.Metric) { m.Name = "1up" }, rejectInvalidName},
+		"empty service":    {func(m *shared.Metric) { m.Service = "" }, rejectInvalidService},
+		"bad label name":   {func(m *shared.Metric) { m.Labels = map[string]string{"has space": "x"} }, rejectInvalidLabel},
+		"long label value": {func(m *shared.Metric) { m.Labels = map[string]string{"id": strings.Repeat("x", maxLabelValueLength+1)} }, rejectInvalidLabel},
+		"NaN":              {func(m *shared.Metric) { m.Value = math.NaN() }, rejectInvalidValue},
+		"Inf":              {func(m *shared.Metric) { m.Value = math.Inf(-1) }, rejectInvalidValue},
+		"zero timestamp":   {func(m *shared.Metric) { m.TS = time.Time{} }, rejectOutOfWindow},
+		"too old":          {func(m *shared.Metric) { m.TS = now.Add(-limits.MaxAge - time.Minute) }, rejectOutOfWindow},
+		"future":           {func(m *shared.Metric) { m.TS = now.Add(time.Hour) }, rejectOutOfWindow},
+	}
+	for name, tc := range tests {
+		m := valid
+		tc.mutate(&m)
+		if reason, err := validateMetric(m, now); err == nil || reason != tc.reason {
+			t.Errorf("%s: expected %s, got %q (%v)", name, tc.reason, reason, err)
+		}
+	}
+}
+
+func TestSeriesTrackerLimit(t *testing.T) {
+	defer func(max int) { limits.MaxSeriesPerAgent = max }(limits.MaxSeriesPerAgent)
+	limits.MaxSeriesPerAgent = 2
+
+	tracker := &seriesTracker{agents: make(map[string]map[string]time.Time)}
+	admit := func(agentID, signature string, now time.Time) bool {
+		admitted, _ := tracker.admit(agentID, signature, now)
+		return admitted
+	}
+	now := time.Now()
+
+	if !admit("a", "s1", now) || !admit("a", "s2", now) {
+		t.Fatal("Expected series within the limit to be admitted")
+	}
+	if admit("a", "s3", now) {
+		t.Error("Expected third series to be rejected")
+	}
+	if !admit("a", "s1", now) {
+		t.Error("Expected known series to keep being admitted")
+	}
+	if !admit("b", "s3", now) {
+		t.Error("Expected limit to be per agent")
+	}
+
+	// s2 ficou ociosa e libera espaço
+	later := now.Add(seriesIdleTimeout + time.Minute)
+	admit("a", "s1", later)
+	if !admit("a", "s3", later) {
+		t.Error("Expected idle series to be evicted")
+	}
+	if counts := tracker.count(); counts["a"] != 2 || counts["b"] != 1 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+}
+
+func TestIngestHandlerValidation(t *testing.T) {
+	mock := &mockStorage{}
+	storage = mock
+	activeSeries = &seriesTracker{agents: make(map[string]map[string]time.Time)}
+
+	now := time.Now()
+	batch := shared.Batch{
+		AgentID: "agent-validate",
+		Items: []shared.Metric{
+			{Service: "web", Target: "site", Name: "http_up", Value: 1, TS: now},
+			{Service: "", Target: "site", Name: "http_up", Value: 1, TS: now},
+			{Service: "web", Target: "site", Name: "http_latency_ms", Value: 12, TS: now.Add(48 * time.Hour)},
+		},
+	}
+
+	post := func(body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/ingest", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		ingestHandler(w, req)
+		return w
+	}
+
+	body, _ := json.Marshal(batch)
+	w := post(body)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body)
+	}
+
+	var resp shared.IngestResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Count != 1 || resp.Rejected != 2 || len(resp.Errors) != 2 {
+		t.Fatalf("Unexpected response %+v", resp)
+	}
+	if resp.Errors[0].Index != 1 || resp.Errors[0].Reason != rejectInvalidService || resp.Errors[1].Reason != rejectOutOfWindow {
+		t.Errorf("Unexpected item errors %+v", resp.Errors)
+	}
+	if len(mock.metrics) != 1 {
+		t.Errorf("Expected 1 metric stored, got %d", len(mock.metrics))
+	}
+
+	// Lote sem nenhuma amostra válida: 2xx para o agente não reenviar
+	batch.Items = batch.Items[1:]
+	body, _ = json.Marshal(batch)
+	if w := post(body); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"rejected"`) {
+		t.Errorf("Expected 200 rejected, got %d: %s", w.Code, w.Body)
+	}
+
+	if counts := rejectedCounts(); counts[rejectInvalidService] < 2 || counts[rejectOutOfWindow] < 2 {
+		t.Errorf("Unexpected rejected counters %v", counts)
+	}
+}
+
+func TestIngestHandlerLimits(t *testing.T) {
+	storage = &mockStorage{}
+	defer func(l ingestLimits) { limits = l }(limits)
+
+	batch := shared.Batch{AgentID: "agent-limits"}
+	for i := 0; i < 3; i++ {
+		batch.Items = append(batch.Items, shared.Metric{Service: "web", Name: "up", Value: 1, TS: time.Now()})
+	}
+	body, _ := json.Marshal(batch)
+
+	limits.MaxBatch = 2
+	w := httptest.NewRecorder()
+	ingestHandler(w, httptest.NewRequest("POST", "/ingest", bytes.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for batch size, got %d", w.Code)
+	}
+
+	limits.MaxBatch = 100
+	limits.MaxBodyBytes = 64
+	w = httptest.NewRecorder()
+	ingestHandler(w, httptest.NewRequest("POST", "/ingest", bytes.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for body size, got %d", w.Code)
+	}
+}
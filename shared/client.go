@@ -5,12 +5,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+// StatusError é a resposta não-2xx da API. RetryAfter vem do cabeçalho
+// Retry-After, quando presente em segundos.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d", e.StatusCode)
+}
+
+// Permanent indica que a API recusou o conteúdo e reenviá-lo não adianta
+func (e *StatusError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
 type Pusher struct {
 	Endpoint string
 	Client   *http.Client
+	// Token é enviado como "Authorization: Bearer" quando definido
+	Token string
 }
 
 func NewPusher(endpoint string) *Pusher {
@@ -20,23 +44,80 @@ func NewPusher(endpoint string) *Pusher {
 	}
 } 
 
+// UseTLS configura o cliente para apresentar o certificado do reloader e,
+// se houver CA configurado, validar o servidor apenas contra ele.
+func (p *Pusher) UseTLS(reloader *CertReloader, serverName string) {
+	p.Client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: reloader.ClientConfig(serverName),
+	}
+}
+
 func (p *Pusher) Push(agentID string, metrics []Metric) error {
 	batch := Batch{
 		AgentID: agentID,
 		Items:   metrics,
+		SentAt:  time.Now(),
+	}
+
+	if err := p.post(p.Endpoint, batch); err != nil {
+		return fmt.Errorf("ingest failed: %w", err)
+	}
+	return nil
+}
+
+// RecordSecurityEvent envia o evento para /api/security/record-event na
+// mesma API do endpoint de ingestão
+func (p *Pusher) RecordSecurityEvent(event SecurityEvent) error {
+	if err := p.postAPI("/api/security/record-event", event); err != nil {
+		return fmt.Errorf("record security event failed: %w", err)
 	}
+	return nil
+}
+
+// Register envia o inventário do agente para /api/agents/register
+func (p *Pusher) Register(hb AgentHeartbeat) error {
+	if err := p.postAPI("/api/agents/register", hb); err != nil {
+		return fmt.Errorf("register failed: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat informa à API que o agente continua vivo
+func (p *Pusher) Heartbeat(hb AgentHeartbeat) error {
+	if err := p.postAPI("/api/agents/heartbeat", hb); err != nil {
+		return fmt.Errorf("heartbeat failed: %w", err)
+	}
+	return nil
+}
+
+// postAPI envia para path na mesma API do endpoint de ingestão
+func (p *Pusher) postAPI(path string, body any) error {
+	u, err := url.Parse(p.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	u.Path = path
+	u.RawQuery = ""
+
+	return p.post(u.String(), body)
+}
 
-	buf, err := json.Marshal(batch)
+func (p *Pusher) post(endpoint string, body any) error {
+	buf, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("marshal error: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", p.Endpoint, bytes.NewReader(buf))
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(buf))
 	if err != nil {
 		return fmt.Errorf("request creation error: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
 
 	resp, err := p.Client.Do(req)
 	if err != nil {
@@ -45,7 +126,11 @@ func (p *Pusher) Push(agentID string, metrics []Metric) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("ingest failed with status %d", resp.StatusCode)
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			statusErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return statusErr
 	}
 
 	return nil
@@ -14,6 +14,40 @@ type Metric struct {
 type Batch struct {
 	AgentID string   `json:"agent_id"`
 	Items   []Metric `json:"items"`
+	// SentAt é o relógio do agente no envio, usado para estimar o skew; os
+	// TS das amostras podem ter esperado na fila
+	SentAt time.Time `json:"sent_at,omitempty"`
+}
+
+// SecurityEvent é o corpo aceito por /api/security/record-event
+type SecurityEvent struct {
+	Type        string                 `json:"type"`
+	Severity    string                 `json:"severity"`
+	Description string                 `json:"description"`
+	Service     string                 `json:"service,omitempty"`
+	Target      string                 `json:"target,omitempty"`
+	IPAddress   string                 `json:"ip_address,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// AgentHeartbeat é o corpo aceito por /api/agents/register e
+// /api/agents/heartbeat. No heartbeat, campos vazios mantêm o valor
+// registrado.
+type AgentHeartbeat struct {
+	AgentID   string       `json:"agent_id"`
+	Version   string       `json:"version,omitempty"`
+	Hostname  string       `json:"hostname,omitempty"`
+	Probes    []AgentProbe `json:"probes,omitempty"`
+	StartedAt time.Time    `json:"started_at,omitempty"`
+	// TS é o relógio do agente no envio, usado para estimar o skew
+	TS time.Time `json:"ts"`
+	// ClockOffsetMS é o offset medido pelo próprio agente via NTP
+	ClockOffsetMS *float64 `json:"clock_offset_ms,omitempty"`
+}
+
+type AgentProbe struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
 }
 
 type QueryRequest struct {
@@ -37,10 +71,24 @@ type DataPoint struct {
 }
 
 type QueryRangeResponse struct {
-	Service string      `json:"service"`
-	Target  string      `json:"target"`
-	Name    string      `json:"name"`
-	Data    []DataPoint `json:"data"`
+	Service string `json:"service"`
+	Target  string `json:"target"`
+	Name    string `json:"name"`
+	// Step é o step efetivo, que pode ser maior que o pedido
+	Step string `json:"step,omitempty"`
+	Agg  string `json:"agg,omitempty"`
+	// Tier é o rollup usado (1m, 1h); vazio indica a tabela bruta
+	Tier string      `json:"tier,omitempty"`
+	Data []DataPoint `json:"data"`
+	// Series separa Data por conjunto distinto de labels
+	Series []Series `json:"series,omitempty"`
+}
+
+type Series struct {
+	Service string            `json:"service"`
+	Target  string            `json:"target"`
+	Labels  map[string]string `json:"labels"`
+	Data    []DataPoint       `json:"data"`
 }
 
 type Alert struct {
@@ -55,9 +103,63 @@ type Alert struct {
 }
 
 type HealthResponse struct {
-	Status       string    `json:"status"`
-	Uptime       string    `json:"uptime"`
-	MetricsCount int64     `json:"metrics_count"`
-	LastIngest   time.Time `json:"last_ingest"`
-	Version      string    `json:"version"`
+	Status       string           `json:"status"`
+	Uptime       string           `json:"uptime"`
+	MetricsCount int64            `json:"metrics_count"`
+	LastIngest   time.Time        `json:"last_ingest"`
+	Version      string           `json:"version"`
+	Partitions   *PartitionHealth `json:"partitions,omitempty"`
+	Ingest       *IngestStats     `json:"ingest,omitempty"`
+}
+
+// IngestStats descreve o buffer de ingestão da API. As latências são
+// médias móveis em ms: FlushLatencyMS mede a gravação no banco e
+// IngestLatencyMS, da chegada do lote até sua gravação.
+type IngestStats struct {
+	QueuedSamples    int     `json:"queued_samples"`
+	BufferCapacity   int     `json:"buffer_capacity"`
+	AcceptedSamples  int64   `json:"accepted_samples_total"`
+	AcceptedBatches  int64   `json:"accepted_batches_total"`
+	ThrottledBatches int64   `json:"throttled_batches_total"`
+	FailedBatches    int64   `json:"failed_batches_total"`
+	Flushes          int64   `json:"flushes_total"`
+	SamplesPerSecond float64 `json:"samples_per_second"`
+	FlushLatencyMS   float64 `json:"flush_latency_ms"`
+	IngestLatencyMS  float64 `json:"ingest_latency_ms"`
+	// RejectedSamples conta as amostras recusadas na validação, por motivo
+	RejectedSamples map[string]int64 `json:"rejected_samples_total,omitempty"`
+	// ActiveSeries são as séries ativas de cada agent_id
+	ActiveSeries map[string]int `json:"active_series,omitempty"`
+}
+
+// IngestResponse é a resposta de /ingest. Amostras inválidas são descartadas
+// sem recusar o lote; Errors detalha as primeiras delas. Um lote sem nenhuma
+// amostra válida tem Status "rejected", ainda com status HTTP 200.
+type IngestResponse struct {
+	Status   string            `json:"status"`
+	Count    int               `json:"count"`
+	AgentID  string            `json:"agent_id"`
+	Rejected int               `json:"rejected,omitempty"`
+	Errors   []IngestItemError `json:"errors,omitempty"`
+}
+
+// IngestItemError aponta o item do lote recusado e o motivo
+type IngestItemError struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+}
+
+// PartitionHealth resume as partições diárias de metrics. Oldest e Newest
+// são dias no formato 2006-01-02; DaysAhead conta os dias futuros já criados.
+type PartitionHealth struct {
+	Count       int        `json:"count"`
+	Oldest      string     `json:"oldest,omitempty"`
+	Newest      string     `json:"newest,omitempty"`
+	DaysAhead   int        `json:"days_ahead"`
+	DefaultRows int64      `json:"default_rows"`
+	Retention   string     `json:"retention"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
 }
@@ -0,0 +1,208 @@
+package shared
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertReloader mantém um certificado (e opcionalmente um bundle de CAs)
+// carregado a partir de arquivos, recarregando-os quando o mtime muda.
+// A verificação é feita no máximo uma vez por CheckInterval, durante o handshake.
+type CertReloader struct {
+	CertFile      string
+	KeyFile       string
+	CAFile        string
+	CheckInterval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  [3]time.Time
+	lastCheck time.Time
+}
+
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		CAFile:        caFile,
+		CheckInterval: 5 * time.Second,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) files() [3]string {
+	return [3]string{r.CertFile, r.KeyFile, r.CAFile}
+}
+
+func (r *CertReloader) currentModTimes() [3]time.Time {
+	var times [3]time.Time
+	for i, f := range r.files() {
+		if f == "" {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil {
+			times[i] = info.ModTime()
+		}
+	}
+	return times
+}
+
+func (r *CertReloader) load() error {
+	modTimes := r.currentModTimes()
+
+	var cert *tls.Certificate
+	if r.CertFile != "" || r.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load key pair: %w", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.CAFile != "" {
+		pem, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return fmt.Errorf("read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.CAFile)
+		}
+	}
+
+	r.cert = cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *CertReloader) maybeReload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < r.CheckInterval {
+		return
+	}
+	r.lastCheck = time.Now()
+
+	if r.currentModTimes() == r.modTimes {
+		return
+	}
+
+	// Em caso de erro (ex: arquivo sendo reescrito) mantém o material anterior
+	if err := r.load(); err != nil {
+		log.Printf("TLS reload failed, keeping previous certificates: %v", err)
+		return
+	}
+	log.Printf("TLS certificates reloaded from %s", r.CertFile)
+}
+
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.maybeReload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert
+}
+
+func (r *CertReloader) CAPool() *x509.CertPool {
+	r.maybeReload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pool
+}
+
+// ServerConfig retorna um tls.Config para servidores. Com CAFile definido,
+// certificados de cliente são verificados contra ele conforme clientAuth.
+func (r *CertReloader) ServerConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert := r.Certificate()
+			if cert == nil {
+				return nil, errors.New("no server certificate configured")
+			}
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    r.CAPool(),
+				ClientAuth:   clientAuth,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig retorna um tls.Config para clientes. O certificado de cliente
+// é apresentado se configurado; com CAFile definido, o servidor só é aceito
+// se sua cadeia for válida contra esse CA (pinning), ignorando o trust store do sistema.
+func (r *CertReloader) ClientConfig(serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := r.Certificate(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+	}
+
+	if r.CAFile == "" {
+		return cfg
+	}
+
+	// RootCAs é fixo no tls.Config; para acompanhar recargas do CA a
+	// verificação é feita manualmente em VerifyConnection.
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server presented no certificate")
+		}
+
+		opts := x509.VerifyOptions{
+			Roots:         r.CAPool(),
+			DNSName:       cs.ServerName,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+	return cfg
+}
+
+// AgentURIPrefix identifica, num SAN URI, o agent_id do certificado:
+// argos://agent/<agent_id>
+const AgentURIPrefix = "argos://agent/"
+
+// CertificateIdentities retorna os agent_ids de um certificado: o CN e os
+// SANs URI argos://agent/<id>. SANs DNS ficam de fora, já que um mesmo
+// certificado costuma listar vários nomes de host que não são agentes.
+func CertificateIdentities(cert *x509.Certificate) []string {
+	var ids []string
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	for _, u := range cert.URIs {
+		if id, ok := strings.CutPrefix(u.String(), AgentURIPrefix); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
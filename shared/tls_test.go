@@ -0,0 +1,181 @@
+package shared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "argos-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue grava um par cert/key assinado pelo CA em dir e retorna os caminhos
+func (ca *testCA) issue(t *testing.T, dir, name, cn string, serial int64) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("issue cert: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certPath, keyPath
+}
+
+func TestCertReloaderReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPath, keyPath := ca.issue(t, dir, "server", "first", 10)
+
+	r, err := NewCertReloader(certPath, keyPath, "")
+	if err != nil {
+		t.Fatalf("NewCertReloader: %v", err)
+	}
+	r.CheckInterval = 0
+
+	leaf, _ := x509.ParseCertificate(r.Certificate().Certificate[0])
+	if leaf.Subject.CommonName != "first" {
+		t.Fatalf("Expected CN first, got %s", leaf.Subject.CommonName)
+	}
+
+	ca.issue(t, dir, "server", "second", 11)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+
+	leaf, _ = x509.ParseCertificate(r.Certificate().Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("Expected reloaded CN second, got %s", leaf.Subject.CommonName)
+	}
+}
+
+func TestPusherMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caPath, ca.pem, 0o600)
+
+	serverCert, serverKey := ca.issue(t, dir, "server", "argos-api", 20)
+	clientCert, clientKey := ca.issue(t, dir, "client", "agent-01", 21)
+
+	serverReloader, err := NewCertReloader(serverCert, serverKey, caPath)
+	if err != nil {
+		t.Fatalf("server reloader: %v", err)
+	}
+
+	var gotCN string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotCN = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		var batch Batch
+		json.NewDecoder(r.Body).Decode(&batch)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = serverReloader.ServerConfig(tls.RequireAndVerifyClientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	clientReloader, err := NewCertReloader(clientCert, clientKey, caPath)
+	if err != nil {
+		t.Fatalf("client reloader: %v", err)
+	}
+
+	pusher := NewPusher(server.URL + "/ingest")
+	pusher.UseTLS(clientReloader, "localhost")
+
+	if err := pusher.Push("agent-01", []Metric{{Name: "up", Value: 1}}); err != nil {
+		t.Fatalf("Push over mTLS failed: %v", err)
+	}
+	if gotCN != "agent-01" {
+		t.Errorf("Expected server to see client CN agent-01, got %q", gotCN)
+	}
+
+	// Sem certificado de cliente o handshake deve falhar
+	anonymous, _ := NewCertReloader("", "", caPath)
+	pusher.UseTLS(anonymous, "localhost")
+	if err := pusher.Push("agent-01", []Metric{{Name: "up", Value: 1}}); err == nil {
+		t.Error("Expected push without client certificate to fail")
+	}
+}
+
+func TestPusherRejectsUnpinnedServer(t *testing.T) {
+	dir := t.TempDir()
+	pinned := newTestCA(t)
+	caPath := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caPath, pinned.pem, 0o600)
+
+	// httptest usa seu próprio certificado, não emitido pelo CA fixado
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	reloader, _ := NewCertReloader("", "", caPath)
+	pusher := NewPusher(server.URL)
+	pusher.UseTLS(reloader, "")
+
+	if err := pusher.Push("agent-01", []Metric{{Name: "up", Value: 1}}); err == nil {
+		t.Error("Expected push to a server outside the pinned CA to fail")
+	}
+}
+
+func TestCertificateIdentities(t *testing.T) {
+	agentURI, _ := url.Parse("argos://agent/agent-02")
+	otherURI, _ := url.Parse("spiffe://example.org/agent-03")
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "agent-01"},
+		DNSNames: []string{"agent-04.dc1"},
+		URIs:     []*url.URL{agentURI, otherURI},
+	}
+
+	ids := CertificateIdentities(cert)
+	if len(ids) != 2 || ids[0] != "agent-01" || ids[1] != "agent-02" {
+		t.Errorf("Expected CN and argos URI SAN only, got %v", ids)
+	}
+}